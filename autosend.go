@@ -0,0 +1,170 @@
+package zlgcan
+
+import (
+	"fmt"
+	"sort"
+	"time"
+	"unsafe"
+)
+
+// NewAutoSend builds a hardware auto-send entry sending msg every interval. The device counts
+// in whole milliseconds, so intervals are truncated and anything below 1 ms is rejected.
+func NewAutoSend(index uint16, interval time.Duration, msg ZCAN_Transmit_Data) (ZCAN_AUTO_TRANSMIT_OBJ, error) {
+	ms, err := autoSendInterval(interval)
+	return ZCAN_AUTO_TRANSMIT_OBJ{Enable: 1, Index: index, Interval: ms, Obj: msg}, err
+}
+
+// NewAutoSendFD builds a hardware auto-send entry for a CANFD frame.
+func NewAutoSendFD(index uint16, interval time.Duration, msg ZCAN_TransmitFD_Data) (ZCANFD_AUTO_TRANSMIT_OBJ, error) {
+	ms, err := autoSendInterval(interval)
+	return ZCANFD_AUTO_TRANSMIT_OBJ{Enable: 1, Index: index, Interval: ms, Obj: msg}, err
+}
+
+func autoSendInterval(interval time.Duration) (uint32, error) {
+	if interval < time.Millisecond {
+		return 0, fmt.Errorf("%w: %v", ErrAutoSendInterval, interval)
+	}
+	return uint32(interval / time.Millisecond), nil
+}
+
+// autoSendEntry holds one registered slot; exactly one of can and fd is set.
+type autoSendEntry struct {
+	can *ZCAN_AUTO_TRANSMIT_OBJ
+	fd  *ZCANFD_AUTO_TRANSMIT_OBJ
+}
+
+// check rejects enabled entries without an interval, which the device would send back to back.
+func (e autoSendEntry) check() error {
+	var enable uint16
+	var interval uint32
+	if e.fd != nil {
+		enable, interval = e.fd.Enable, e.fd.Interval
+	} else {
+		enable, interval = e.can.Enable, e.can.Interval
+	}
+	if enable != 0 && interval == 0 {
+		return fmt.Errorf("%w: 0 ms", ErrAutoSendInterval)
+	}
+	return nil
+}
+
+func (ch *Channel) checkAutoSendSlot(index uint16) error {
+	entry, ok := ch.zc.device(ch.Device())
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrUnknownDevice, ch.Device())
+	}
+	spec, ok := GetDeviceSpec(entry.deviceType)
+	if !ok || spec.AutoSendSlots == 0 {
+		return fmt.Errorf("%w: device type 0x%x", ErrAutoSendUnsupported, entry.deviceType)
+	}
+	if index >= spec.AutoSendSlots {
+		return fmt.Errorf("%w: %d, %s has %d slots", ErrAutoSendSlot, index, spec.Name, spec.AutoSendSlots)
+	}
+	return nil
+}
+
+// writeAutoSend hands one entry to the device. It takes effect with StartAutoSend.
+func (ch *Channel) writeAutoSend(e autoSendEntry) error {
+	return ch.zc.withProperty(ch.Device(), func(ip *ZCAN_IProperty) error {
+		var ret uint
+		var path string
+		if e.fd != nil {
+			path = fmt.Sprintf("%d/auto_send_canfd", ch.index)
+			ret = ch.zc.drv.SetValuePtr(ip, path, unsafe.Pointer(e.fd))
+		} else {
+			path = fmt.Sprintf("%d/auto_send", ch.index)
+			ret = ch.zc.drv.SetValuePtr(ip, path, unsafe.Pointer(e.can))
+		}
+		if ret != ZCAN_STATUS_OK {
+			return fmt.Errorf("error setting %s: %d", path, ret)
+		}
+		return nil
+	})
+}
+
+func (ch *Channel) setAutoSend(index uint16, e autoSendEntry) error {
+	if err := ch.checkAutoSendSlot(index); err != nil {
+		return err
+	}
+	if err := e.check(); err != nil {
+		return err
+	}
+	if err := ch.writeAutoSend(e); err != nil {
+		return err
+	}
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if ch.autoSend == nil {
+		ch.autoSend = make(map[uint16]autoSendEntry)
+	}
+	ch.autoSend[index] = e
+	return nil
+}
+
+// SetAutoSend registers obj in slot obj.Index, replacing an existing entry.
+func (ch *Channel) SetAutoSend(obj ZCAN_AUTO_TRANSMIT_OBJ) error {
+	return ch.setAutoSend(obj.Index, autoSendEntry{can: &obj})
+}
+
+// SetAutoSendFD registers a CANFD obj in slot obj.Index, replacing an existing entry.
+func (ch *Channel) SetAutoSendFD(obj ZCANFD_AUTO_TRANSMIT_OBJ) error {
+	return ch.setAutoSend(obj.Index, autoSendEntry{fd: &obj})
+}
+
+// StartAutoSend applies the registered entries; enabled entries start sending.
+func (ch *Channel) StartAutoSend() error {
+	if err := ch.zc.setProperties(ch.Device(), []property{{fmt.Sprintf("%d/apply_auto_send", ch.index), "0"}}); err != nil {
+		return err
+	}
+	ch.mu.Lock()
+	ch.autoSendStarted = true
+	ch.mu.Unlock()
+	return nil
+}
+
+// StopAutoSend disables the entry in slot index and applies the table again.
+func (ch *Channel) StopAutoSend(index uint16) error {
+	ch.mu.Lock()
+	e, ok := ch.autoSend[index]
+	ch.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %d not registered", ErrAutoSendSlot, index)
+	}
+	if e.fd != nil {
+		obj := *e.fd
+		obj.Enable = 0
+		e = autoSendEntry{fd: &obj}
+	} else {
+		obj := *e.can
+		obj.Enable = 0
+		e = autoSendEntry{can: &obj}
+	}
+	if err := ch.setAutoSend(index, e); err != nil {
+		return err
+	}
+	return ch.StartAutoSend()
+}
+
+// ClearAutoSend stops and removes all entries of the channel.
+func (ch *Channel) ClearAutoSend() error {
+	if err := ch.zc.setProperties(ch.Device(), []property{{fmt.Sprintf("%d/clear_auto_send", ch.index), "0"}}); err != nil {
+		return err
+	}
+	ch.mu.Lock()
+	ch.autoSend = nil
+	ch.autoSendStarted = false
+	ch.mu.Unlock()
+	return nil
+}
+
+// AutoSendIndexes returns the registered slots in ascending order.
+func (ch *Channel) AutoSendIndexes() []uint16 {
+	ch.mu.Lock()
+	indexes := make([]uint16, 0, len(ch.autoSend))
+	for index := range ch.autoSend {
+		indexes = append(indexes, index)
+	}
+	ch.mu.Unlock()
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+	return indexes
+}
@@ -0,0 +1,138 @@
+package zlgcan
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// Test that auto-send slots are checked against the device spec before reaching the driver
+func TestAutoSendSlots(t *testing.T) {
+	ch := &Channel{zc: newTestZCAN(1, ZCAN_USBCANFD_200U, 2), device: 1}
+	obj, err := NewAutoSend(100, 10*time.Millisecond, ZCAN_Transmit_Data{})
+	if err != nil || obj.Interval != 10 || obj.Enable != 1 {
+		t.Fatalf("NewAutoSend: unexpected entry %+v, %v", obj, err)
+	}
+	if err := ch.SetAutoSend(obj); !errors.Is(err, ErrAutoSendSlot) {
+		t.Fatalf("Slot 100 on USBCANFD-200U: expected ErrAutoSendSlot, got %v", err)
+	}
+	if err := ch.StopAutoSend(3); !errors.Is(err, ErrAutoSendSlot) {
+		t.Fatalf("Stopping an unregistered slot: expected ErrAutoSendSlot, got %v", err)
+	}
+
+	ch = &Channel{zc: newTestZCAN(1, ZCAN_USBCAN2, 2), device: 1}
+	fd, _ := NewAutoSendFD(0, time.Second, ZCAN_TransmitFD_Data{})
+	if err := ch.SetAutoSendFD(fd); !errors.Is(err, ErrAutoSendUnsupported) {
+		t.Fatalf("Auto-send on USBCAN-II: expected ErrAutoSendUnsupported, got %v", err)
+	}
+}
+
+// Test that intervals below the 1 ms resolution are rejected
+func TestAutoSendInterval(t *testing.T) {
+	if _, err := NewAutoSend(0, 999*time.Microsecond, ZCAN_Transmit_Data{}); !errors.Is(err, ErrAutoSendInterval) {
+		t.Fatalf("Expected ErrAutoSendInterval, got %v", err)
+	}
+	if _, err := NewAutoSendFD(0, 0, ZCAN_TransmitFD_Data{}); !errors.Is(err, ErrAutoSendInterval) {
+		t.Fatalf("Expected ErrAutoSendInterval, got %v", err)
+	}
+	if obj, err := NewAutoSendFD(0, 1500*time.Microsecond, ZCAN_TransmitFD_Data{}); err != nil || obj.Interval != 1 {
+		t.Fatalf("Expected 1 ms, got %+v, %v", obj, err)
+	}
+	ch := &Channel{zc: newTestZCAN(1, ZCAN_USBCANFD_200U, 2), device: 1}
+	if err := ch.SetAutoSend(ZCAN_AUTO_TRANSMIT_OBJ{Enable: 1}); !errors.Is(err, ErrAutoSendInterval) {
+		t.Fatalf("Expected ErrAutoSendInterval for an enabled entry without interval, got %v", err)
+	}
+}
+
+// autoSendRecorder is a simulator keeping a copy of every auto-send entry set.
+type autoSendRecorder struct {
+	*Simulator
+	mu      sync.Mutex
+	paths   []string
+	entries []any
+}
+
+func (r *autoSendRecorder) SetValuePtr(iproperty *ZCAN_IProperty, path string, value unsafe.Pointer) uint {
+	r.mu.Lock()
+	r.paths = append(r.paths, path)
+	if strings.HasSuffix(path, "/auto_send_canfd") {
+		r.entries = append(r.entries, *(*ZCANFD_AUTO_TRANSMIT_OBJ)(value))
+	} else {
+		r.entries = append(r.entries, *(*ZCAN_AUTO_TRANSMIT_OBJ)(value))
+	}
+	r.mu.Unlock()
+	return r.Simulator.SetValuePtr(iproperty, path, value)
+}
+
+// Test the auto-send properties written to a simulated device
+func TestAutoSendSimulated(t *testing.T) {
+	sim := NewSimulator()
+	if err := sim.AddDevice(ZCAN_USBCANFD_200U, 0, "SIM0000"); err != nil {
+		t.Fatalf("AddDevice failed: %v", err)
+	}
+	rec := &autoSendRecorder{Simulator: sim}
+	zc := newZCAN(rec, nil)
+	handle := zc.OpenDevice(ZCAN_USBCANFD_200U, 0, 0)
+	ch, err := zc.OpenChannel(handle, 1)
+	if err != nil {
+		t.Fatalf("OpenChannel failed: %v", err)
+	}
+
+	msg := ZCAN_Transmit_Data{}
+	msg.Frame.GenerateID(0x123, 0, 0, 0)
+	obj, _ := NewAutoSend(2, 100*time.Millisecond, msg)
+	if err := ch.SetAutoSend(obj); err != nil {
+		t.Fatalf("SetAutoSend failed: %v", err)
+	}
+	fd, _ := NewAutoSendFD(0, 20*time.Millisecond, newFDTransmit(0x456, ZCAN_TX_NORMAL))
+	if err := ch.SetAutoSendFD(fd); err != nil {
+		t.Fatalf("SetAutoSendFD failed: %v", err)
+	}
+	if err := ch.StartAutoSend(); err != nil {
+		t.Fatalf("StartAutoSend failed: %v", err)
+	}
+	if v, ok := sim.Property(ZCAN_USBCANFD_200U, 0, "1/apply_auto_send"); !ok || v != "0" {
+		t.Fatalf("Expected 1/apply_auto_send to be set, got %q", v)
+	}
+	if got := ch.AutoSendIndexes(); len(got) != 2 || got[0] != 0 || got[1] != 2 {
+		t.Fatalf("Expected slots 0 and 2, got %v", got)
+	}
+	ch.mu.Lock()
+	started := ch.autoSendStarted
+	ch.mu.Unlock()
+	if !started {
+		t.Fatalf("Expected the auto-send table started")
+	}
+
+	if err := ch.StopAutoSend(2); err != nil {
+		t.Fatalf("StopAutoSend failed: %v", err)
+	}
+	rec.mu.Lock()
+	paths, entries := rec.paths, rec.entries
+	rec.mu.Unlock()
+	if len(paths) != 3 || paths[0] != "1/auto_send" || paths[1] != "1/auto_send_canfd" || paths[2] != "1/auto_send" {
+		t.Fatalf("Unexpected auto-send paths %v", paths)
+	}
+	if e := entries[0].(ZCAN_AUTO_TRANSMIT_OBJ); e.Enable != 1 || e.Index != 2 || e.Interval != 100 || e.Obj.Frame.GetFrameID() != 0x123 {
+		t.Fatalf("Unexpected CAN entry %+v", e)
+	}
+	if e := entries[1].(ZCANFD_AUTO_TRANSMIT_OBJ); e.Enable != 1 || e.Index != 0 || e.Interval != 20 || e.Obj.Frame.GetFrameID() != 0x456 {
+		t.Fatalf("Unexpected CANFD entry %+v", e)
+	}
+	if e := entries[2].(ZCAN_AUTO_TRANSMIT_OBJ); e.Enable != 0 || e.Index != 2 {
+		t.Fatalf("Expected slot 2 disabled, got %+v", e)
+	}
+
+	if err := ch.ClearAutoSend(); err != nil {
+		t.Fatalf("ClearAutoSend failed: %v", err)
+	}
+	if _, ok := sim.Property(ZCAN_USBCANFD_200U, 0, "1/clear_auto_send"); !ok {
+		t.Fatalf("Expected 1/clear_auto_send to be set")
+	}
+	if got := ch.AutoSendIndexes(); len(got) != 0 {
+		t.Fatalf("Expected no slots after clearing, got %v", got)
+	}
+}
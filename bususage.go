@@ -0,0 +1,258 @@
+package zlgcan
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BusUsageSample is the bus load of a channel over one time window.
+type BusUsageSample struct {
+	Channel    uint8
+	Percent    float64
+	FrameCount uint32
+	Begin      uint64 // timestamp of the window start, in the device timestamp unit (us)
+	End        uint64
+	Estimated  bool // computed from observed frames instead of reported by the device
+}
+
+// Window returns the length of the sampled window.
+func (s BusUsageSample) Window() time.Duration {
+	return time.Duration(s.End-s.Begin) * time.Microsecond
+}
+
+// Sample converts a bus usage report of the device.
+func (e BusUsageEvent) Sample() BusUsageSample {
+	return BusUsageSample{
+		Channel:    e.Chnl,
+		Percent:    float64(e.BusUsage) / 100,
+		FrameCount: e.FrameCount,
+		Begin:      e.TimestampBegin,
+		End:        e.TimestampEnd,
+	}
+}
+
+// EnableBusUsage makes the device report the bus usage of the channel every period.
+// Reports arrive as BusUsageEvent through ReceiveData, see EnableMergedReceive.
+func (ch *Channel) EnableBusUsage(period time.Duration) error {
+	entry, ok := ch.zc.device(ch.Device())
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrUnknownDevice, ch.Device())
+	}
+	if spec, ok := GetDeviceSpec(entry.deviceType); !ok || !spec.BusUsage {
+		return fmt.Errorf("%w: device type 0x%x", ErrBusUsageUnsupported, entry.deviceType)
+	}
+	return ch.zc.setProperties(ch.Device(), []property{
+		{fmt.Sprintf("%d/set_bus_usage_period", ch.index), fmt.Sprint(max(period/time.Millisecond, 1))},
+		{fmt.Sprintf("%d/set_bus_usage_enable", ch.index), "1"},
+	})
+}
+
+// DisableBusUsage stops the bus usage reports of the channel.
+func (ch *Channel) DisableBusUsage() error {
+	return ch.zc.setProperties(ch.Device(), []property{{fmt.Sprintf("%d/set_bus_usage_enable", ch.index), "0"}})
+}
+
+// BusLoadEstimator estimates the bus usage from received frames on devices that cannot
+// report it. Frame lengths are computed from the CAN/CANFD frame format; bit stuffing is
+// counted as half of the worst case. Windows without frames are reported as 0%.
+type BusLoadEstimator struct {
+	channel     uint8
+	bitrate     float64
+	dataBitrate float64
+	window      uint64 // us
+
+	mu     sync.Mutex
+	begin  uint64
+	busy   float64 // us
+	frames uint32
+	seen   bool
+
+	// Device clock as of the last frame, to close windows while the bus is idle.
+	clockTs   uint64
+	clockAt   time.Time
+	synthetic bool // no frame seen since Start, windows count from Start
+	pending   []BusUsageSample
+
+	running bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewBusLoadEstimator creates an estimator producing one sample per window.
+// dataBitrate is only used for CANFD frames with BRS set.
+func NewBusLoadEstimator(channel uint8, bitrate, dataBitrate uint32, window time.Duration) *BusLoadEstimator {
+	if dataBitrate == 0 {
+		dataBitrate = bitrate
+	}
+	return &BusLoadEstimator{
+		channel:     channel,
+		bitrate:     float64(bitrate),
+		dataBitrate: float64(dataBitrate),
+		window:      uint64(max(window/time.Microsecond, 1)),
+	}
+}
+
+// canFrameBits returns the length of a classic CAN frame in bits.
+func canFrameBits(extended, rtr bool, dlc int) float64 {
+	// SOF, ID, RTR, IDE, r0, DLC, CRC 15, CRC delimiter; extended adds SRR, IDE and 18 ID bits.
+	stuffed := 1 + 11 + 1 + 1 + 1 + 4 + 15
+	if extended {
+		stuffed += 20
+	}
+	if !rtr {
+		stuffed += 8 * min(dlc, 8)
+	}
+	// CRC delimiter, ACK slot and delimiter, EOF and intermission.
+	fixed := 1 + 2 + 7 + 3
+	return float64(stuffed) + float64(stuffed-1)/4/2 + float64(fixed)
+}
+
+var canfdLengths = [16]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 12, 16, 20, 24, 32, 48, 64}
+
+// canfdFrameBits returns the bits of a CANFD frame sent at the nominal and at the data bitrate.
+func canfdFrameBits(extended bool, length int) (nominal, data float64) {
+	// SOF, ID, RRS, IDE, FDF, res, BRS.
+	arbitration := 1 + 11 + 1 + 1 + 1 + 1 + 1
+	if extended {
+		arbitration += 19
+	}
+	crc, payload := 17, length
+	for _, l := range canfdLengths {
+		if l >= length {
+			payload = l
+			break
+		}
+	}
+	if payload > 16 {
+		crc = 21
+	}
+	// ESI, DLC, data, stuff count with parity and its fixed stuff bit, CRC with fixed stuff bits.
+	data = float64(1 + 4 + 8*payload + 5 + crc + (crc+3)/4)
+	data += float64(5+8*payload) / 4 / 2
+	nominal = float64(arbitration) + float64(arbitration-1)/4/2
+	// CRC delimiter, ACK slot and delimiter, EOF and intermission.
+	nominal += 1 + 2 + 7 + 3
+	return nominal, data
+}
+
+func (e *BusLoadEstimator) observe(timestamp uint64, busy float64) []BusUsageSample {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.seen || e.synthetic {
+		e.begin, e.seen, e.synthetic = timestamp, true, false
+		e.busy, e.frames = 0, 0
+	}
+	e.clockTs, e.clockAt = timestamp, time.Now()
+	samples := e.advance(timestamp)
+	e.busy += busy
+	e.frames++
+	if e.running {
+		e.pending = append(e.pending, samples...)
+		return nil
+	}
+	return samples
+}
+
+// advance closes every window ending at or before timestamp.
+func (e *BusLoadEstimator) advance(timestamp uint64) []BusUsageSample {
+	var samples []BusUsageSample
+	for timestamp >= e.begin+e.window {
+		samples = append(samples, e.flush(e.begin+e.window))
+		e.begin += e.window
+	}
+	return samples
+}
+
+func (e *BusLoadEstimator) flush(end uint64) BusUsageSample {
+	sample := BusUsageSample{
+		Channel:    e.channel,
+		Percent:    min(100*e.busy/float64(end-e.begin), 100),
+		FrameCount: e.frames,
+		Begin:      e.begin,
+		End:        end,
+		Estimated:  true,
+	}
+	e.busy, e.frames = 0, 0
+	return sample
+}
+
+// ObserveCAN accounts a classic CAN frame received at timestamp (us). The samples of the
+// windows finished before the frame are returned, unless the estimator was started.
+func (e *BusLoadEstimator) ObserveCAN(frame *ZCAN_CAN_FRAME, timestamp uint64) []BusUsageSample {
+	bits := canFrameBits(frame.GetFrameEFF() == 1, frame.GetFrameRTR() == 1, int(frame.Dlc))
+	return e.observe(timestamp, bits/e.bitrate*1e6)
+}
+
+// ObserveCANFD accounts a CANFD frame received at timestamp (us).
+func (e *BusLoadEstimator) ObserveCANFD(frame *ZCAN_CANFD_FRAME, timestamp uint64) []BusUsageSample {
+	nominal, data := canfdFrameBits(frame.GetFrameEFF() == 1, int(frame.Len))
+	dataRate := e.bitrate
+	if frame.GetFrameBRS() == 1 {
+		dataRate = e.dataBitrate
+	}
+	return e.observe(timestamp, (nominal/e.bitrate+data/dataRate)*1e6)
+}
+
+// tick closes the windows that ended by now on the device clock.
+func (e *BusLoadEstimator) tick(now time.Time) []BusUsageSample {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	samples := append(e.pending, e.advance(e.clockTs+uint64(now.Sub(e.clockAt)/time.Microsecond))...)
+	e.pending = nil
+	return samples
+}
+
+// Start sends the sample of every window to out once the window ended, including windows
+// in which no frame was observed. The device clock is followed from the timestamps of the
+// observed frames; until the first frame the windows count from Start.
+func (e *BusLoadEstimator) Start(out chan<- BusUsageSample) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.running {
+		return
+	}
+	e.running = true
+	if !e.seen {
+		e.seen, e.synthetic = true, true
+		e.begin, e.clockTs, e.clockAt = 0, 0, time.Now()
+	}
+	e.stop = make(chan struct{})
+	e.done = make(chan struct{})
+	go e.run(out, e.stop, e.done)
+}
+
+// Stop halts the samples started with Start and waits for the goroutine to exit.
+func (e *BusLoadEstimator) Stop() {
+	e.mu.Lock()
+	if !e.running {
+		e.mu.Unlock()
+		return
+	}
+	e.running = false
+	e.pending = nil
+	close(e.stop)
+	done := e.done
+	e.mu.Unlock()
+	<-done
+}
+
+func (e *BusLoadEstimator) run(out chan<- BusUsageSample, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(time.Duration(e.window) * time.Microsecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			for _, sample := range e.tick(now) {
+				select {
+				case out <- sample:
+				case <-stop:
+					return
+				}
+			}
+		}
+	}
+}
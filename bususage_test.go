@@ -0,0 +1,113 @@
+package zlgcan
+
+import (
+	"testing"
+	"time"
+)
+
+// Test the software bus load estimate against a hand computed load
+func TestBusLoadEstimator(t *testing.T) {
+	est := NewBusLoadEstimator(0, 500000, 2000000, 10*time.Millisecond)
+
+	var frame ZCAN_CAN_FRAME
+	frame.GenerateID(0x100, 0, 0, 0)
+	frame.Dlc = 8
+	// A standard 8 byte frame is about 123 bits, 246us at 500kbps; one per ms is ~25% load.
+	for ts := uint64(0); ts < 10000; ts += 1000 {
+		if samples := est.ObserveCAN(&frame, ts); len(samples) != 0 {
+			t.Fatalf("Sample completed early at %d", ts)
+		}
+	}
+	samples := est.ObserveCAN(&frame, 10000)
+	if len(samples) != 1 {
+		t.Fatalf("Expected a sample after one window, got %d", len(samples))
+	}
+	sample := samples[0]
+	if sample.FrameCount != 10 || sample.Window() != 10*time.Millisecond || !sample.Estimated {
+		t.Fatalf("Unexpected sample: %+v", sample)
+	}
+	if sample.Percent < 23 || sample.Percent > 27 {
+		t.Fatalf("Expected ~25%% load, got %.2f%%", sample.Percent)
+	}
+
+	// An idle gap is reported as idle windows.
+	samples = est.ObserveCAN(&frame, 45000)
+	if len(samples) != 3 || samples[0].FrameCount != 1 || samples[0].Begin != 10000 {
+		t.Fatalf("Unexpected samples after idle gap: %+v", samples)
+	}
+	for _, sample := range samples[1:] {
+		if sample.FrameCount != 0 || sample.Percent != 0 || sample.Window() != 10*time.Millisecond {
+			t.Fatalf("Expected an idle window, got %+v", sample)
+		}
+	}
+	if samples[2].End != 40000 || est.begin != 40000 {
+		t.Fatalf("Expected the next window to start at 40000, got %d", est.begin)
+	}
+}
+
+// Test that a started estimator reports idle windows without any frame
+func TestBusLoadEstimatorStart(t *testing.T) {
+	est := NewBusLoadEstimator(0, 500000, 0, 10*time.Millisecond)
+	out := make(chan BusUsageSample, 16)
+	est.Start(out)
+	defer est.Stop()
+
+	next := func() BusUsageSample {
+		t.Helper()
+		select {
+		case sample := <-out:
+			return sample
+		case <-time.After(time.Second):
+			t.Fatalf("Expected a sample")
+		}
+		return BusUsageSample{}
+	}
+	if sample := next(); sample.Percent != 0 || sample.FrameCount != 0 || sample.Window() != 10*time.Millisecond {
+		t.Fatalf("Expected an idle sample, got %+v", sample)
+	}
+
+	// Frames move the estimator onto the device clock; their window is sent by the ticker.
+	var frame ZCAN_CAN_FRAME
+	frame.Dlc = 8
+	if samples := est.ObserveCAN(&frame, 5000000); samples != nil {
+		t.Fatalf("A started estimator should send samples to out, got %+v", samples)
+	}
+	for {
+		sample := next()
+		if sample.Begin < 5000000 {
+			continue // windows from before the first frame
+		}
+		if sample.Begin != 5000000 || sample.FrameCount != 1 || sample.Percent == 0 {
+			t.Fatalf("Expected the window of the frame, got %+v", sample)
+		}
+		break
+	}
+	if sample := next(); sample.Begin != 5010000 || sample.FrameCount != 0 {
+		t.Fatalf("Expected the idle window after the frame, got %+v", sample)
+	}
+	est.Stop()
+	est.Stop()
+}
+
+// Test that BRS frames are cheaper than the same frame at the nominal rate
+func TestBusLoadEstimatorFD(t *testing.T) {
+	var frame ZCAN_CANFD_FRAME
+	frame.Len = 64
+	slow := NewBusLoadEstimator(0, 500000, 2000000, time.Second)
+	slow.ObserveCANFD(&frame, 0)
+	frame.GenerateFlags(1, 0, 0)
+	fast := NewBusLoadEstimator(0, 500000, 2000000, time.Second)
+	fast.ObserveCANFD(&frame, 0)
+	if fast.busy >= slow.busy/2 {
+		t.Fatalf("BRS frame should take well under half the time: %.1fus vs %.1fus", fast.busy, slow.busy)
+	}
+}
+
+// Test for device reported bus usage
+func TestBusUsageEventSample(t *testing.T) {
+	event := BusUsageEvent{Chnl: 1, ZCAN_BUS_USAGE: ZCAN_BUS_USAGE{TimestampBegin: 1000, TimestampEnd: 501000, BusUsage: 1234, FrameCount: 99}}
+	sample := event.Sample()
+	if sample.Percent != 12.34 || sample.Window() != 500*time.Millisecond || sample.Estimated {
+		t.Fatalf("Unexpected sample: %+v", sample)
+	}
+}
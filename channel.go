@@ -0,0 +1,425 @@
+package zlgcan
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	defaultBitrate     = 500000
+	defaultDataBitrate = 2000000
+	defaultSamplePoint = 0.8
+	defaultCANFDClock  = 60000000
+)
+
+// Filter accepts frames whose ID lies in [Start, End].
+type Filter struct {
+	Extended bool
+	Start    uint32
+	End      uint32
+}
+
+type channelOptions struct {
+	canType         uint32
+	canTypeSet      bool
+	bitrate         uint32
+	samplePoint     float64
+	dataBitrate     uint32
+	dataSamplePoint float64
+	clock           uint32
+	termination     *bool
+	mode            ChannelMode
+	nonISO          bool
+	filters         []Filter
+	accCode         uint32
+	accMask         uint32
+	accSet          bool
+	queueSend       bool
+	errorFrames     chan<- ErrorFrame
+	receive         ReceiveStrategy
+}
+
+// ChannelOption configures a channel opened by OpenChannel.
+type ChannelOption func(*channelOptions)
+
+// WithCanType selects ZCAN_TYPE_CAN or ZCAN_TYPE_CANFD. Defaults to CANFD on devices that support it;
+// those devices only take ZCAN_TYPE_CANFD and still send classic frames with Transmit.
+func WithCanType(canType uint32) ChannelOption {
+	return func(o *channelOptions) {
+		o.canType = canType
+		o.canTypeSet = true
+	}
+}
+
+// WithBitrate sets the nominal (arbitration) bitrate and sample point, e.g. 500000, 0.8.
+func WithBitrate(bitrate uint32, samplePoint float64) ChannelOption {
+	return func(o *channelOptions) {
+		o.bitrate = bitrate
+		o.samplePoint = samplePoint
+	}
+}
+
+// WithDataBitrate sets the CANFD data phase bitrate and sample point.
+func WithDataBitrate(bitrate uint32, samplePoint float64) ChannelOption {
+	return func(o *channelOptions) {
+		o.dataBitrate = bitrate
+		o.dataSamplePoint = samplePoint
+	}
+}
+
+// WithClock overrides the controller clock of CANFD devices (60 MHz by default).
+func WithClock(hz uint32) ChannelOption {
+	return func(o *channelOptions) {
+		o.clock = hz
+	}
+}
+
+// WithTermination switches the internal 120 Ohm termination resistor.
+func WithTermination(enable bool) ChannelOption {
+	return func(o *channelOptions) {
+		o.termination = &enable
+	}
+}
+
+// WithMode sets the channel working mode. The mode is checked against the device spec.
+func WithMode(mode ChannelMode) ChannelOption {
+	return func(o *channelOptions) {
+		o.mode = mode
+	}
+}
+
+// WithListenOnly opens the channel without acknowledging frames on the bus.
+func WithListenOnly() ChannelOption {
+	return WithMode(ZCAN_MODE_LISTEN_ONLY)
+}
+
+// WithLoopback opens the channel in internal loopback (self test) mode.
+func WithLoopback() ChannelOption {
+	return WithMode(ZCAN_MODE_LOOPBACK)
+}
+
+// WithNonISOCANFD selects Bosch (non-ISO) CANFD instead of ISO 11898-1:2015.
+func WithNonISOCANFD() ChannelOption {
+	return func(o *channelOptions) {
+		o.nonISO = true
+	}
+}
+
+// WithFilter adds an ID range filter. Range filters are set through the device properties.
+func WithFilter(f Filter) ChannelOption {
+	return func(o *channelOptions) {
+		o.filters = append(o.filters, f)
+	}
+}
+
+// WithAcceptanceFilter sets the AccCode/AccMask of SJA1000 based classic CAN devices.
+func WithAcceptanceFilter(accCode, accMask uint32) ChannelOption {
+	return func(o *channelOptions) {
+		o.accCode = accCode
+		o.accMask = accMask
+		o.accSet = true
+	}
+}
+
+// WithQueueSend enables queue send mode, see Channel.EnableQueueSend.
+func WithQueueSend() ChannelOption {
+	return func(o *channelOptions) {
+		o.queueSend = true
+	}
+}
+
+// WithErrorFrames routes received error frames to errs instead of returning them from
+// Channel.Receive and Channel.ReceiveFD. Receiving does not wait for errs: error frames
+// that do not fit are dropped and counted, see Channel.ErrorFramesDropped.
+func WithErrorFrames(errs chan<- ErrorFrame) ChannelOption {
+	return func(o *channelOptions) {
+		o.errorFrames = errs
+	}
+}
+
+// Channel is a CAN channel initialized and started by OpenChannel. Its methods may be
+// called from several goroutines; the handles change when the channel is brought up again
+// by a Supervisor or Watchdog, so they should be fetched through Handle and Device.
+type Channel struct {
+	zc    *ZCAN
+	index uint
+	opts  channelOptions
+
+	// startMu serializes bring-ups, which Supervisor and Watchdog may run at the same time.
+	startMu sync.Mutex
+
+	mu              sync.Mutex
+	device          int
+	handle          int
+	autoSend        map[uint16]autoSendEntry
+	autoSendStarted bool
+
+	errorFramesDropped atomic.Uint64
+}
+
+func (ch *Channel) Handle() int {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.handle
+}
+
+func (ch *Channel) Device() int {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.device
+}
+
+// moveTo moves the channel to a reopened device and brings it up there, see Watchdog.
+func (ch *Channel) moveTo(deviceHandle int) error {
+	ch.startMu.Lock()
+	defer ch.startMu.Unlock()
+	ch.mu.Lock()
+	ch.device = deviceHandle
+	ch.mu.Unlock()
+	return ch.startLocked()
+}
+
+func (ch *Channel) Index() uint {
+	return ch.index
+}
+
+func (ch *Channel) CanType() uint32 {
+	return ch.opts.canType
+}
+
+func (ch *Channel) Mode() ChannelMode {
+	return ch.opts.mode
+}
+
+// Close resets the channel.
+func (ch *Channel) Close() error {
+	if ret := ch.zc.ResetCAN(ch.Handle()); ret != ZCAN_STATUS_OK {
+		ch.zc.logStatus("ZCAN_ResetCAN", ret, slog.Int("handle", ch.Handle()), slog.Uint64("channel", uint64(ch.index)))
+		return fmt.Errorf("error calling ZCAN_ResetCAN: %d", ret)
+	}
+	return nil
+}
+
+type property struct {
+	path  string
+	value string
+}
+
+// setProperties applies props in order through the device IProperty.
+func (zc *ZCAN) setProperties(deviceHandle int, props []property) error {
+	if len(props) == 0 {
+		return nil
+	}
+	return zc.withProperty(deviceHandle, func(ip *ZCAN_IProperty) error {
+		for _, p := range props {
+			if ret := zc.drv.SetValue(ip, p.path, p.value); ret != ZCAN_STATUS_OK {
+				return fmt.Errorf("error setting %s=%s: %d", p.path, p.value, ret)
+			}
+		}
+		return nil
+	})
+}
+
+// OpenChannel sets up, initializes and starts channel canIndex of deviceHandle.
+// Properties that must precede ZCAN_InitCAN (clock, CANFD standard) are set first, then the
+// channel is initialized, termination and filters are applied and the channel is started.
+// The channel is reset again if any step after initialization fails.
+func (zc *ZCAN) OpenChannel(deviceHandle int, canIndex uint, opts ...ChannelOption) (*Channel, error) {
+	ch, err := zc.newChannel(deviceHandle, canIndex, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := ch.start(); err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// newChannel resolves opts against the defaults and the device spec.
+func (zc *ZCAN) newChannel(deviceHandle int, canIndex uint, opts []ChannelOption) (*Channel, error) {
+	entry, ok := zc.device(deviceHandle)
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownDevice, deviceHandle)
+	}
+	spec, _ := GetDeviceSpec(entry.deviceType)
+
+	o := channelOptions{
+		bitrate:         defaultBitrate,
+		samplePoint:     defaultSamplePoint,
+		dataBitrate:     defaultDataBitrate,
+		dataSamplePoint: defaultSamplePoint,
+		clock:           defaultCANFDClock,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if !o.canTypeSet {
+		o.canType = ZCAN_TYPE_CAN
+		if spec.CANFD {
+			o.canType = ZCAN_TYPE_CANFD
+		}
+	}
+
+	return &Channel{zc: zc, device: deviceHandle, index: canIndex, opts: o}, nil
+}
+
+// start runs the whole bring-up sequence with the channel options.
+func (ch *Channel) start() error {
+	ch.startMu.Lock()
+	defer ch.startMu.Unlock()
+	return ch.startLocked()
+}
+
+// restart resets the channel and runs the bring-up sequence again, see Supervisor.
+func (ch *Channel) restart() error {
+	ch.startMu.Lock()
+	defer ch.startMu.Unlock()
+	ch.zc.ResetCAN(ch.Handle())
+	return ch.startLocked()
+}
+
+func (ch *Channel) startLocked() (err error) {
+	defer func() {
+		if err != nil {
+			ch.logStartError("OpenChannel", err)
+		}
+	}()
+	handle, err := ch.init()
+	if err != nil {
+		return err
+	}
+	return ch.startCAN(handle)
+}
+
+// logStartError logs err of a failed bring-up called by function.
+func (ch *Channel) logStartError(function string, err error) {
+	ch.zc.log(slog.LevelError, "starting channel failed", append(ch.zc.deviceAttrs(ch.Device()),
+		slog.String("function", function), slog.Uint64("channel", uint64(ch.index)), slog.Any("error", err))...)
+}
+
+// init runs the bring-up sequence up to ZCAN_StartCAN and returns the channel handle. The
+// channel is reset again if a step after ZCAN_InitCAN fails.
+func (ch *Channel) init() (int, error) {
+	zc, o := ch.zc, &ch.opts
+	device := ch.Device()
+	entry, ok := zc.device(device)
+	if !ok {
+		return 0, fmt.Errorf("%w: %d", ErrUnknownDevice, device)
+	}
+	spec, _ := GetDeviceSpec(entry.deviceType)
+	if err := zc.validateChannel(device, ch.index, o.canType, o.mode); err != nil {
+		return 0, err
+	}
+	if spec.CANFD && o.canType != ZCAN_TYPE_CANFD {
+		return 0, fmt.Errorf("%w: %d, %s channels are initialized as ZCAN_TYPE_CANFD", ErrInvalidCanType, o.canType, spec.Name)
+	}
+	// EnableQueueSend may switch the mode while a supervisor brings the channel up again.
+	ch.mu.Lock()
+	queueSend := o.queueSend
+	ch.mu.Unlock()
+	if queueSend && !spec.QueueSend {
+		return 0, fmt.Errorf("%w: device type 0x%x", ErrQueueSendUnsupported, entry.deviceType)
+	}
+
+	var handle int
+	if spec.CANFD {
+		initCfg := ZCAN_CANFD_CHANNEL_INIT_CONFIG{CanType: o.canType}
+		initCfg.Config.Mode = o.mode
+		initCfg.Config.AccCode = o.accCode
+		initCfg.Config.AccMask = o.accMask
+		abit, err := calcBitTiming(o.clock, o.bitrate, o.samplePoint, canfdNominalLimits)
+		if err != nil {
+			return 0, fmt.Errorf("nominal bitrate: %w", err)
+		}
+		dbit, err := calcBitTiming(o.clock, o.dataBitrate, o.dataSamplePoint, canfdDataLimits)
+		if err != nil {
+			return 0, fmt.Errorf("data bitrate: %w", err)
+		}
+		initCfg.Config.AbitTiming = canfdTimingWord(abit)
+		initCfg.Config.DbitTiming = canfdTimingWord(dbit)
+
+		standard := "0"
+		if o.nonISO {
+			standard = "1"
+		}
+		err = zc.setProperties(device, []property{
+			{fmt.Sprintf("%d/clock", ch.index), fmt.Sprint(o.clock)},
+			{fmt.Sprintf("%d/canfd_standard", ch.index), standard},
+		})
+		if err != nil {
+			return 0, err
+		}
+		if handle, err = zc.InitCANFDChecked(device, ch.index, &initCfg); err != nil {
+			return 0, err
+		}
+	} else {
+		initCfg := ZCAN_NORMAL_CHANNEL_INIT_CONFIG{CanType: o.canType}
+		initCfg.Config.Mode = o.mode
+		initCfg.Config.AccCode = o.accCode
+		initCfg.Config.AccMask = o.accMask
+		if !o.accSet {
+			initCfg.Config.AccMask = 0xFFFFFFFF
+		}
+		bt, err := calcBitTiming(sja1000Clock, o.bitrate, o.samplePoint, sja1000Limits)
+		if err != nil {
+			return 0, fmt.Errorf("bitrate: %w", err)
+		}
+		initCfg.Config.Timing0, initCfg.Config.Timing1 = sja1000Timing(bt)
+		if handle, err = zc.InitCANChecked(device, ch.index, &initCfg); err != nil {
+			return 0, err
+		}
+	}
+	ch.mu.Lock()
+	ch.handle = handle
+	ch.mu.Unlock()
+
+	var props []property
+	if o.termination != nil {
+		value := "0"
+		if *o.termination {
+			value = "1"
+		}
+		props = append(props, property{fmt.Sprintf("%d/initenal_resistance", ch.index), value})
+	}
+	if queueSend {
+		props = append(props, property{fmt.Sprintf("%d/set_send_mode", ch.index), "1"})
+	}
+	if len(o.filters) > 0 {
+		props = append(props, property{fmt.Sprintf("%d/filter_clear", ch.index), "0"})
+		for _, f := range o.filters {
+			filterMode := "0"
+			if f.Extended {
+				filterMode = "1"
+			}
+			props = append(props,
+				property{fmt.Sprintf("%d/filter_mode", ch.index), filterMode},
+				property{fmt.Sprintf("%d/filter_start", ch.index), fmt.Sprintf("0x%x", f.Start)},
+				property{fmt.Sprintf("%d/filter_end", ch.index), fmt.Sprintf("0x%x", f.End)},
+			)
+		}
+		props = append(props, property{fmt.Sprintf("%d/filter_ack", ch.index), "0"})
+	}
+	if err := zc.setProperties(device, props); err != nil {
+		zc.ResetCAN(handle)
+		return 0, err
+	}
+
+	return handle, nil
+}
+
+// startCAN starts the channel initialized with handle, resetting it if that fails.
+func (ch *Channel) startCAN(handle int) error {
+	if ret := ch.zc.StartCAN(handle); ret != ZCAN_STATUS_OK {
+		ch.zc.ResetCAN(handle)
+		return fmt.Errorf("error calling ZCAN_StartCAN on channel %d: %d", ch.index, ret)
+	}
+	ch.logStarted(handle)
+	return nil
+}
+
+func (ch *Channel) logStarted(handle int) {
+	ch.zc.log(slog.LevelDebug, "channel started", append(ch.zc.deviceAttrs(ch.Device()),
+		slog.Int("channel_handle", handle), slog.Uint64("channel", uint64(ch.index)))...)
+}
@@ -0,0 +1,209 @@
+package zlgcan
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnknownDevice        = errors.New("device handle was not opened by this ZCAN")
+	ErrChannelOutOfRange    = errors.New("channel index out of range")
+	ErrCANFDUnsupported     = errors.New("device does not support CANFD")
+	ErrInvalidCanType       = errors.New("invalid can type")
+	ErrDeviceNotFound       = errors.New("device not found")
+	ErrDeviceBusy           = errors.New("device already open")
+	ErrModeUnsupported      = errors.New("channel mode not supported")
+	ErrAutoSendUnsupported  = errors.New("device has no hardware auto-send")
+	ErrAutoSendSlot         = errors.New("auto-send index out of range")
+	ErrAutoSendInterval     = errors.New("auto-send interval below 1 ms")
+	ErrQueueSendUnsupported = errors.New("device has no queue send mode")
+	ErrBusUsageUnsupported  = errors.New("device cannot report bus usage")
+)
+
+// DeviceSpec describes the fixed capabilities of a ZLG device type.
+type DeviceSpec struct {
+	Name       string
+	Channels   uint8
+	CANFD      bool
+	ListenOnly bool
+	Loopback   bool
+	// AutoSendSlots is the number of hardware auto-send entries per channel, 0 if unsupported.
+	AutoSendSlots uint16
+	QueueSend     bool
+	BusUsage      bool
+}
+
+// SupportsMode reports whether the device can run a channel in mode.
+func (spec DeviceSpec) SupportsMode(mode ChannelMode) bool {
+	switch mode {
+	case ZCAN_MODE_NORMAL:
+		return true
+	case ZCAN_MODE_LISTEN_ONLY:
+		return spec.ListenOnly
+	case ZCAN_MODE_LOOPBACK:
+		return spec.Loopback
+	}
+	return false
+}
+
+var deviceSpecs = map[int]DeviceSpec{
+	ZCAN_PCI5121:              {Name: "PCI-5121", Channels: 2, ListenOnly: true, Loopback: true},
+	ZCAN_PCI9810:              {Name: "PCI-9810", Channels: 1, ListenOnly: true, Loopback: true},
+	ZCAN_USBCAN1:              {Name: "USBCAN-I", Channels: 1, ListenOnly: true, Loopback: true},
+	ZCAN_USBCAN2:              {Name: "USBCAN-II", Channels: 2, ListenOnly: true, Loopback: true},
+	ZCAN_PCI9820:              {Name: "PCI-9820", Channels: 2, ListenOnly: true, Loopback: true},
+	ZCAN_PCI5110:              {Name: "PCI-5110", Channels: 1, ListenOnly: true, Loopback: true},
+	ZCAN_PCI9840:              {Name: "PCI-9840", Channels: 4, ListenOnly: true, Loopback: true},
+	ZCAN_PCI9820I:             {Name: "PCI-9820I", Channels: 2, ListenOnly: true, Loopback: true},
+	ZCAN_PCIE_9220:            {Name: "PCIe-9220", Channels: 2, ListenOnly: true, Loopback: true},
+	ZCAN_PCI5010U:             {Name: "PCI-5010-U", Channels: 1, ListenOnly: true, Loopback: true},
+	ZCAN_USBCAN_E_U:           {Name: "USBCAN-E-U", Channels: 1, ListenOnly: true, Loopback: true},
+	ZCAN_USBCAN_2E_U:          {Name: "USBCAN-2E-U", Channels: 2, ListenOnly: true, Loopback: true},
+	ZCAN_PCI5020U:             {Name: "PCI-5020-U", Channels: 2, ListenOnly: true, Loopback: true},
+	ZCAN_PCIE9221:             {Name: "PCIe-9221", Channels: 2, ListenOnly: true, Loopback: true},
+	ZCAN_PCIe9120:             {Name: "PCIe-9120", Channels: 2, ListenOnly: true, Loopback: true},
+	ZCAN_PCIe9110:             {Name: "PCIe-9110", Channels: 1, ListenOnly: true, Loopback: true},
+	ZCAN_PCIe9140:             {Name: "PCIe-9140", Channels: 4, ListenOnly: true, Loopback: true},
+	ZCAN_USBCAN_4E_U:          {Name: "USBCAN-4E-U", Channels: 4, ListenOnly: true, Loopback: true},
+	ZCAN_CANDTU_200UR:         {Name: "CANDTU-200UR", Channels: 2, ListenOnly: true},
+	ZCAN_USBCAN_8E_U:          {Name: "USBCAN-8E-U", Channels: 8, ListenOnly: true, Loopback: true},
+	ZCAN_CANDTU_100UR:         {Name: "CANDTU-100UR", Channels: 1, ListenOnly: true},
+	ZCAN_PCIE_CANFD_100U:      {Name: "PCIE-CANFD-100U", Channels: 1, CANFD: true, ListenOnly: true, AutoSendSlots: 100, QueueSend: true, BusUsage: true},
+	ZCAN_PCIE_CANFD_200U:      {Name: "PCIE-CANFD-200U", Channels: 2, CANFD: true, ListenOnly: true, AutoSendSlots: 100, QueueSend: true, BusUsage: true},
+	ZCAN_PCIE_CANFD_400U:      {Name: "PCIE-CANFD-400U", Channels: 4, CANFD: true, ListenOnly: true, AutoSendSlots: 100, QueueSend: true, BusUsage: true},
+	ZCAN_USBCANFD_200U:        {Name: "USBCANFD-200U", Channels: 2, CANFD: true, ListenOnly: true, AutoSendSlots: 100, QueueSend: true, BusUsage: true},
+	ZCAN_USBCANFD_100U:        {Name: "USBCANFD-100U", Channels: 1, CANFD: true, ListenOnly: true, AutoSendSlots: 100, QueueSend: true, BusUsage: true},
+	ZCAN_USBCANFD_MINI:        {Name: "USBCANFD-MINI", Channels: 1, CANFD: true, ListenOnly: true, AutoSendSlots: 100, QueueSend: true, BusUsage: true},
+	ZCAN_CANFDCOM_100IE:       {Name: "CANFDCOM-100IE", Channels: 1, CANFD: true, ListenOnly: true},
+	ZCAN_CANFDNET_TCP:         {Name: "CANFDNET-200U-TCP", Channels: 2, CANFD: true, ListenOnly: true, QueueSend: true, BusUsage: true},
+	ZCAN_CANFDNET_UDP:         {Name: "CANFDNET-200U-UDP", Channels: 2, CANFD: true, ListenOnly: true, QueueSend: true, BusUsage: true},
+	ZCAN_CANFDWIFI_TCP:        {Name: "CANFDWIFI-100U-TCP", Channels: 1, CANFD: true, ListenOnly: true},
+	ZCAN_CANFDWIFI_UDP:        {Name: "CANFDWIFI-100U-UDP", Channels: 1, CANFD: true, ListenOnly: true},
+	ZCAN_CANFDNET_400U_TCP:    {Name: "CANFDNET-400U-TCP", Channels: 4, CANFD: true, ListenOnly: true, QueueSend: true, BusUsage: true},
+	ZCAN_CANFDNET_400U_UDP:    {Name: "CANFDNET-400U-UDP", Channels: 4, CANFD: true, ListenOnly: true, QueueSend: true, BusUsage: true},
+	ZCAN_CANFDBLUE_200U:       {Name: "CANFDBLUE-200U", Channels: 2, CANFD: true, ListenOnly: true},
+	ZCAN_CANFDNET_100U_TCP:    {Name: "CANFDNET-100U-TCP", Channels: 1, CANFD: true, ListenOnly: true, QueueSend: true, BusUsage: true},
+	ZCAN_CANFDNET_100U_UDP:    {Name: "CANFDNET-100U-UDP", Channels: 1, CANFD: true, ListenOnly: true, QueueSend: true, BusUsage: true},
+	ZCAN_CANFDNET_800U_TCP:    {Name: "CANFDNET-800U-TCP", Channels: 8, CANFD: true, ListenOnly: true, QueueSend: true, BusUsage: true},
+	ZCAN_CANFDNET_800U_UDP:    {Name: "CANFDNET-800U-UDP", Channels: 8, CANFD: true, ListenOnly: true, QueueSend: true, BusUsage: true},
+	ZCAN_USBCANFD_800U:        {Name: "USBCANFD-800U", Channels: 8, CANFD: true, ListenOnly: true, AutoSendSlots: 100, QueueSend: true, BusUsage: true},
+	ZCAN_PCIE_CANFD_100U_EX:   {Name: "PCIE-CANFD-100U-EX", Channels: 1, CANFD: true, ListenOnly: true, AutoSendSlots: 100, QueueSend: true, BusUsage: true},
+	ZCAN_PCIE_CANFD_400U_EX:   {Name: "PCIE-CANFD-400U-EX", Channels: 4, CANFD: true, ListenOnly: true, AutoSendSlots: 100, QueueSend: true, BusUsage: true},
+	ZCAN_PCIE_CANFD_200U_MINI: {Name: "PCIE-CANFD-200U-MINI", Channels: 2, CANFD: true, ListenOnly: true, AutoSendSlots: 100, QueueSend: true, BusUsage: true},
+	ZCAN_PCIE_CANFD_200U_M2:   {Name: "PCIE-CANFD-200U-M2", Channels: 2, CANFD: true, ListenOnly: true, AutoSendSlots: 100, QueueSend: true, BusUsage: true},
+	ZCAN_CANFDDTU_400_TCP:     {Name: "CANFDDTU-400-TCP", Channels: 4, CANFD: true, ListenOnly: true},
+	ZCAN_CANFDDTU_400_UDP:     {Name: "CANFDDTU-400-UDP", Channels: 4, CANFD: true, ListenOnly: true},
+	ZCAN_CANFDWIFI_200U_TCP:   {Name: "CANFDWIFI-200U-TCP", Channels: 2, CANFD: true, ListenOnly: true},
+	ZCAN_CANFDWIFI_200U_UDP:   {Name: "CANFDWIFI-200U-UDP", Channels: 2, CANFD: true, ListenOnly: true},
+}
+
+// GetDeviceSpec returns the known capabilities of deviceType.
+func GetDeviceSpec(deviceType int) (DeviceSpec, bool) {
+	spec, ok := deviceSpecs[deviceType]
+	return spec, ok
+}
+
+type deviceEntry struct {
+	deviceType  int
+	deviceIndex int
+	info        *ZCAN_DEVICE_INFO
+}
+
+func (zc *ZCAN) trackDevice(deviceHandle int, deviceType int, deviceIndex int) {
+	zc.mu.Lock()
+	defer zc.mu.Unlock()
+	if zc.devices == nil {
+		zc.devices = make(map[int]*deviceEntry)
+	}
+	zc.devices[deviceHandle] = &deviceEntry{deviceType: deviceType, deviceIndex: deviceIndex}
+}
+
+func (zc *ZCAN) untrackDevice(deviceHandle int) {
+	zc.mu.Lock()
+	defer zc.mu.Unlock()
+	delete(zc.devices, deviceHandle)
+}
+
+func (zc *ZCAN) device(deviceHandle int) (*deviceEntry, bool) {
+	zc.mu.Lock()
+	defer zc.mu.Unlock()
+	entry, ok := zc.devices[deviceHandle]
+	return entry, ok
+}
+
+// DeviceType returns the device type deviceHandle was opened with.
+func (zc *ZCAN) DeviceType(deviceHandle int) (int, bool) {
+	entry, ok := zc.device(deviceHandle)
+	if !ok {
+		return 0, false
+	}
+	return entry.deviceType, true
+}
+
+// deviceInfo returns the cached ZCAN_DEVICE_INFO of deviceHandle, querying the driver once.
+func (zc *ZCAN) deviceInfo(entry *deviceEntry, deviceHandle int) *ZCAN_DEVICE_INFO {
+	zc.mu.Lock()
+	info := entry.info
+	zc.mu.Unlock()
+	if info != nil {
+		return info
+	}
+	info = zc.GetDeviceInf(deviceHandle)
+	if info == nil {
+		return nil
+	}
+	zc.mu.Lock()
+	entry.info = info
+	zc.mu.Unlock()
+	return info
+}
+
+// ValidateCANConfig checks that initConfig can be applied to channel canIndex of deviceHandle.
+func (zc *ZCAN) ValidateCANConfig(deviceHandle int, canIndex uint, initConfig *ZCAN_NORMAL_CHANNEL_INIT_CONFIG) error {
+	if initConfig.CanType != ZCAN_TYPE_CAN {
+		return fmt.Errorf("%w: %d, ZCAN_NORMAL_CHANNEL_INIT_CONFIG only carries ZCAN_TYPE_CAN settings", ErrInvalidCanType, initConfig.CanType)
+	}
+	return zc.validateChannel(deviceHandle, canIndex, initConfig.CanType, initConfig.Config.Mode)
+}
+
+// ValidateCANFDConfig checks that initConfig can be applied to channel canIndex of deviceHandle.
+func (zc *ZCAN) ValidateCANFDConfig(deviceHandle int, canIndex uint, initConfig *ZCAN_CANFD_CHANNEL_INIT_CONFIG) error {
+	if initConfig.CanType != ZCAN_TYPE_CANFD {
+		return fmt.Errorf("%w: %d, ZCAN_CANFD_CHANNEL_INIT_CONFIG only carries ZCAN_TYPE_CANFD settings", ErrInvalidCanType, initConfig.CanType)
+	}
+	return zc.validateChannel(deviceHandle, canIndex, initConfig.CanType, initConfig.Config.Mode)
+}
+
+func (zc *ZCAN) validateChannel(deviceHandle int, canIndex uint, canType uint32, mode ChannelMode) error {
+	if canType != ZCAN_TYPE_CAN && canType != ZCAN_TYPE_CANFD {
+		return fmt.Errorf("%w: %d", ErrInvalidCanType, canType)
+	}
+	if mode > ZCAN_MODE_LOOPBACK {
+		return fmt.Errorf("%w: %s", ErrModeUnsupported, mode)
+	}
+	entry, ok := zc.device(deviceHandle)
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrUnknownDevice, deviceHandle)
+	}
+
+	name := fmt.Sprintf("device type 0x%x", entry.deviceType)
+	if spec, ok := GetDeviceSpec(entry.deviceType); ok {
+		name = spec.Name
+		if canIndex >= uint(spec.Channels) {
+			return fmt.Errorf("%w: channel %d, %s has %d channel(s)", ErrChannelOutOfRange, canIndex, name, spec.Channels)
+		}
+		if canType == ZCAN_TYPE_CANFD && !spec.CANFD {
+			return fmt.Errorf("%w: %s is a classic CAN device", ErrCANFDUnsupported, name)
+		}
+		if !spec.SupportsMode(mode) {
+			return fmt.Errorf("%w: %s on %s", ErrModeUnsupported, mode, name)
+		}
+	}
+
+	if info := zc.deviceInfo(entry, deviceHandle); info != nil && info.CanNum() > 0 {
+		if canIndex >= uint(info.CanNum()) {
+			return fmt.Errorf("%w: channel %d, %s reports %d channel(s)", ErrChannelOutOfRange, canIndex, name, info.CanNum())
+		}
+	}
+	return nil
+}
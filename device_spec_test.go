@@ -0,0 +1,108 @@
+package zlgcan
+
+import (
+	"errors"
+	"testing"
+)
+
+func newTestZCAN(handle int, deviceType int, canNum uint8) *ZCAN {
+	zc := &ZCAN{}
+	zc.trackDevice(handle, deviceType, 0)
+	entry, _ := zc.device(handle)
+	entry.info = &ZCAN_DEVICE_INFO{can_Num: canNum}
+	return zc
+}
+
+// Test for config validation against the device spec
+func TestValidateChannelConfig(t *testing.T) {
+	zc := newTestZCAN(1, ZCAN_USBCAN2, 2)
+
+	fdCfg := ZCAN_CANFD_CHANNEL_INIT_CONFIG{CanType: ZCAN_TYPE_CANFD}
+	if err := zc.ValidateCANFDConfig(1, 0, &fdCfg); !errors.Is(err, ErrCANFDUnsupported) {
+		t.Fatalf("CANFD on USBCAN-II: expected ErrCANFDUnsupported, got %v", err)
+	}
+
+	canCfg := ZCAN_NORMAL_CHANNEL_INIT_CONFIG{CanType: ZCAN_TYPE_CAN}
+	if err := zc.ValidateCANConfig(1, 1, &canCfg); err != nil {
+		t.Fatalf("Channel 1 on USBCAN-II should be valid: %v", err)
+	}
+	if err := zc.ValidateCANConfig(1, 3, &canCfg); !errors.Is(err, ErrChannelOutOfRange) {
+		t.Fatalf("Channel 3 on USBCAN-II: expected ErrChannelOutOfRange, got %v", err)
+	}
+	if err := zc.ValidateCANConfig(2, 0, &canCfg); !errors.Is(err, ErrUnknownDevice) {
+		t.Fatalf("Unknown handle: expected ErrUnknownDevice, got %v", err)
+	}
+
+	canCfg.CanType = ZCAN_TYPE_CANFD
+	if err := zc.ValidateCANConfig(1, 0, &canCfg); !errors.Is(err, ErrInvalidCanType) {
+		t.Fatalf("CANFD type in normal config: expected ErrInvalidCanType, got %v", err)
+	}
+
+	zc = newTestZCAN(1, ZCAN_USBCANFD_200U, 2)
+	fdCfg.CanType = ZCAN_TYPE_CAN
+	if err := zc.ValidateCANFDConfig(1, 0, &fdCfg); !errors.Is(err, ErrInvalidCanType) {
+		t.Fatalf("CAN type in CANFD config: expected ErrInvalidCanType, got %v", err)
+	}
+}
+
+// Test that CanNum reported by the device narrows the spec
+func TestValidateChannelCanNum(t *testing.T) {
+	zc := newTestZCAN(1, ZCAN_USBCANFD_200U, 1)
+
+	fdCfg := ZCAN_CANFD_CHANNEL_INIT_CONFIG{CanType: ZCAN_TYPE_CANFD}
+	if err := zc.ValidateCANFDConfig(1, 0, &fdCfg); err != nil {
+		t.Fatalf("Channel 0 should be valid: %v", err)
+	}
+	if err := zc.ValidateCANFDConfig(1, 1, &fdCfg); !errors.Is(err, ErrChannelOutOfRange) {
+		t.Fatalf("Channel 1 with CanNum 1: expected ErrChannelOutOfRange, got %v", err)
+	}
+}
+
+// Test for channel mode validation
+func TestValidateChannelMode(t *testing.T) {
+	zc := newTestZCAN(1, ZCAN_USBCANFD_200U, 2)
+
+	fdCfg := ZCAN_CANFD_CHANNEL_INIT_CONFIG{CanType: ZCAN_TYPE_CANFD}
+	fdCfg.Config.Mode = ZCAN_MODE_LISTEN_ONLY
+	if err := zc.ValidateCANFDConfig(1, 0, &fdCfg); err != nil {
+		t.Fatalf("Listen-only on USBCANFD-200U should be valid: %v", err)
+	}
+	fdCfg.Config.Mode = ZCAN_MODE_LOOPBACK
+	if err := zc.ValidateCANFDConfig(1, 0, &fdCfg); !errors.Is(err, ErrModeUnsupported) {
+		t.Fatalf("Loopback on USBCANFD-200U: expected ErrModeUnsupported, got %v", err)
+	}
+
+	zc = newTestZCAN(1, ZCAN_USBCAN2, 2)
+	canCfg := ZCAN_NORMAL_CHANNEL_INIT_CONFIG{CanType: ZCAN_TYPE_CAN}
+	canCfg.Config.Mode = ZCAN_MODE_LOOPBACK
+	if err := zc.ValidateCANConfig(1, 0, &canCfg); err != nil {
+		t.Fatalf("Loopback on USBCAN-II should be valid: %v", err)
+	}
+	canCfg.Config.Mode = 7
+	if err := zc.ValidateCANConfig(1, 0, &canCfg); !errors.Is(err, ErrModeUnsupported) {
+		t.Fatalf("Mode 7: expected ErrModeUnsupported, got %v", err)
+	}
+}
+
+// Test that the checked init variants return the validation error
+func TestInitCANChecked(t *testing.T) {
+	zc := newTestZCAN(1, ZCAN_USBCAN2, 2)
+
+	fdCfg := ZCAN_CANFD_CHANNEL_INIT_CONFIG{CanType: ZCAN_TYPE_CANFD}
+	if handle, err := zc.InitCANFDChecked(1, 0, &fdCfg); handle != INVALID_CHANNEL_HANDLE || !errors.Is(err, ErrCANFDUnsupported) {
+		t.Fatalf("CANFD on USBCAN-II: expected ErrCANFDUnsupported, got %d, %v", handle, err)
+	}
+	canCfg := ZCAN_NORMAL_CHANNEL_INIT_CONFIG{CanType: ZCAN_TYPE_CAN}
+	if handle, err := zc.InitCANChecked(1, 3, &canCfg); handle != INVALID_CHANNEL_HANDLE || !errors.Is(err, ErrChannelOutOfRange) {
+		t.Fatalf("Channel 3 on USBCAN-II: expected ErrChannelOutOfRange, got %d, %v", handle, err)
+	}
+	if handle := zc.InitCAN(1, 3, &canCfg); handle != INVALID_CHANNEL_HANDLE {
+		t.Fatalf("Expected InitCAN to fail, got %d", handle)
+	}
+
+	sim := NewSimulator()
+	zc, dev := newSimulatedDevice(t, sim, ZCAN_USBCANFD_200U, 0)
+	if handle, err := zc.InitCANFDChecked(dev, 1, &fdCfg); handle == INVALID_CHANNEL_HANDLE || err != nil {
+		t.Fatalf("InitCANFDChecked failed: %d, %v", handle, err)
+	}
+}
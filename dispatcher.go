@@ -0,0 +1,274 @@
+package zlgcan
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// RxFrame is a frame delivered by a Dispatcher. Exactly one of CAN and FD is set.
+type RxFrame struct {
+	CAN *ZCAN_Receive_Data
+	FD  *ZCAN_ReceiveFD_Data
+}
+
+// ID returns the frame ID without the ERR/RTR/EFF flags.
+func (f RxFrame) ID() uint32 {
+	if f.FD != nil {
+		return f.FD.Frame.GetFrameID()
+	}
+	return f.CAN.Frame.GetFrameID()
+}
+
+// Timestamp returns the receive timestamp in microseconds.
+func (f RxFrame) Timestamp() uint64 {
+	if f.FD != nil {
+		return f.FD.Timestamp
+	}
+	return f.CAN.Timestamp
+}
+
+// DropPolicy decides what happens when a subscriber's buffer is full.
+type DropPolicy uint8
+
+const (
+	DropBlock  DropPolicy = iota // wait for the subscriber, holding up all others
+	DropOldest                   // discard the oldest buffered frame
+	DropNewest                   // discard the incoming frame
+)
+
+// IDFilter matches frame IDs either by mask (ID&Mask == Code&Mask) or, when Range is set,
+// by the range [Start, End].
+type IDFilter struct {
+	Code  uint32
+	Mask  uint32
+	Range bool
+	Start uint32
+	End   uint32
+}
+
+func (f IDFilter) match(id uint32) bool {
+	if f.Range {
+		return id >= f.Start && id <= f.End
+	}
+	return id&f.Mask == f.Code&f.Mask
+}
+
+type subscribeOptions struct {
+	filters []IDFilter
+	buffer  int
+	policy  DropPolicy
+}
+
+// SubscribeOption configures a Subscription.
+type SubscribeOption func(*subscribeOptions)
+
+// WithIDMask accepts frames whose ID matches code in the bits set in mask.
+func WithIDMask(code, mask uint32) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.filters = append(o.filters, IDFilter{Code: code, Mask: mask})
+	}
+}
+
+// WithIDRange accepts frames whose ID lies in [start, end]; none if end is below start.
+func WithIDRange(start, end uint32) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.filters = append(o.filters, IDFilter{Range: true, Start: start, End: end})
+	}
+}
+
+// WithBuffer sets how many frames wait for the subscriber before the drop policy applies.
+func WithBuffer(n int) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.buffer = n
+	}
+}
+
+// WithDropPolicy sets what happens when the buffer is full. Defaults to DropNewest.
+func WithDropPolicy(policy DropPolicy) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.policy = policy
+	}
+}
+
+// Subscription receives the frames of a Dispatcher that pass its filters. Without filters
+// all frames pass; several filters are combined with OR.
+type Subscription struct {
+	d       *Dispatcher
+	opts    subscribeOptions
+	frames  chan RxFrame
+	closing chan struct{}
+	once    sync.Once
+
+	mu      sync.Mutex // held while delivering, Close waits for it
+	closed  bool
+	dropped atomic.Uint64
+}
+
+// Frames returns the channel frames are delivered on. It is closed by Close.
+func (s *Subscription) Frames() <-chan RxFrame {
+	return s.frames
+}
+
+// Dropped returns the number of frames lost because the buffer was full.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close unsubscribes and closes the frame channel.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		close(s.closing)
+		s.d.unsubscribe(s)
+		s.mu.Lock()
+		s.closed = true
+		close(s.frames)
+		s.mu.Unlock()
+	})
+}
+
+func (s *Subscription) match(id uint32) bool {
+	if len(s.opts.filters) == 0 {
+		return true
+	}
+	for _, f := range s.opts.filters {
+		if f.match(id) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Subscription) deliver(f RxFrame, stop <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.frames <- f:
+		return
+	default:
+	}
+	switch s.opts.policy {
+	case DropBlock:
+		select {
+		case s.frames <- f:
+		case <-s.closing:
+			s.dropped.Add(1)
+		case <-stop:
+			s.dropped.Add(1)
+		}
+	case DropOldest:
+		for {
+			select {
+			case <-s.frames:
+				s.dropped.Add(1)
+			default:
+			}
+			select {
+			case s.frames <- f:
+				return
+			default:
+			}
+		}
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// Dispatcher reads a channel once and fans the frames out to any number of subscribers.
+// It waits for frames following the channel's ReceiveStrategy. Only the dispatcher may
+// receive on the channel while it runs.
+type Dispatcher struct {
+	ch *Channel
+
+	mu      sync.Mutex
+	subs    []*Subscription
+	running bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewDispatcher creates a stopped dispatcher for ch.
+func NewDispatcher(ch *Channel) *Dispatcher {
+	return &Dispatcher{ch: ch}
+}
+
+// Subscribe adds a subscriber. Frames received before it subscribed are not replayed.
+func (d *Dispatcher) Subscribe(opts ...SubscribeOption) *Subscription {
+	o := subscribeOptions{buffer: 1024, policy: DropNewest}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.buffer = max(o.buffer, 1)
+	s := &Subscription{d: d, opts: o, frames: make(chan RxFrame, o.buffer), closing: make(chan struct{})}
+	d.mu.Lock()
+	d.subs = append(d.subs, s)
+	d.mu.Unlock()
+	return s
+}
+
+func (d *Dispatcher) unsubscribe(s *Subscription) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, sub := range d.subs {
+		if sub == s {
+			d.subs = append(d.subs[:i:i], d.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// Start begins reading the channel.
+func (d *Dispatcher) Start() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.running {
+		return
+	}
+	d.running = true
+	d.stop = make(chan struct{})
+	d.done = make(chan struct{})
+	go d.run(d.stop, d.done)
+}
+
+// Stop halts reading and waits for the dispatcher goroutine to exit. Subscriptions stay
+// open and receive frames again after the next Start.
+func (d *Dispatcher) Stop() {
+	d.mu.Lock()
+	if !d.running {
+		d.mu.Unlock()
+		return
+	}
+	d.running = false
+	close(d.stop)
+	done := d.done
+	d.mu.Unlock()
+	<-done
+}
+
+func (d *Dispatcher) dispatch(f RxFrame, stop <-chan struct{}) {
+	d.mu.Lock()
+	subs := d.subs
+	d.mu.Unlock()
+	id := f.ID()
+	for _, s := range subs {
+		if s.match(id) {
+			s.deliver(f, stop)
+		}
+	}
+}
+
+func (d *Dispatcher) run(stop, done chan struct{}) {
+	defer close(done)
+	r := NewReceiver(d.ch)
+	for {
+		frames, ok := r.Next(stop)
+		if !ok {
+			return
+		}
+		for _, f := range frames {
+			d.dispatch(f, stop)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package zlgcan
+
+import (
+	"testing"
+	"time"
+)
+
+// Test ID filter matching
+func TestIDFilter(t *testing.T) {
+	mask := IDFilter{Code: 0x120, Mask: 0x7F0}
+	if !mask.match(0x12F) || mask.match(0x130) {
+		t.Fatalf("Mask filter matched wrong IDs")
+	}
+	rng := IDFilter{Range: true, Start: 0x200, End: 0x2FF}
+	if !rng.match(0x200) || !rng.match(0x2FF) || rng.match(0x300) || rng.match(0x1FF) {
+		t.Fatalf("Range filter matched wrong IDs")
+	}
+	var o subscribeOptions
+	WithIDRange(0, 0)(&o)
+	if !o.filters[0].match(0) || o.filters[0].match(1) || o.filters[0].match(2) {
+		t.Fatalf("Range [0, 0] matched wrong IDs")
+	}
+	WithIDRange(0x10, 0x0F)(&o)
+	if o.filters[1].match(0x0F) || o.filters[1].match(0x10) {
+		t.Fatalf("An empty range matched")
+	}
+	// A mask filter with a zero mask accepts everything, even though End is zero.
+	if !(IDFilter{}).match(0x123) {
+		t.Fatalf("Zero mask filter should match all IDs")
+	}
+}
+
+func receiveFrames(t *testing.T, sub *Subscription, n int) []uint32 {
+	t.Helper()
+	var ids []uint32
+	for len(ids) < n {
+		select {
+		case f := <-sub.Frames():
+			ids = append(ids, f.ID())
+		case <-time.After(time.Second):
+			t.Fatalf("Expected %d frames, got %d", n, len(ids))
+		}
+	}
+	return ids
+}
+
+// Test fanning frames out to filtered subscribers with different drop policies
+func TestDispatcher(t *testing.T) {
+	sim := NewSimulator()
+	zc, handle := newSimulatedDevice(t, sim, ZCAN_USBCANFD_200U, 0)
+	tx, err := zc.OpenChannel(handle, 0)
+	if err != nil {
+		t.Fatalf("OpenChannel 0 failed: %v", err)
+	}
+	rx, err := zc.OpenChannel(handle, 1)
+	if err != nil {
+		t.Fatalf("OpenChannel 1 failed: %v", err)
+	}
+
+	d := NewDispatcher(rx)
+	low := d.Subscribe(WithIDMask(0x100, 0x7F0), WithIDRange(0x300, 0x301))
+	newest := d.Subscribe(WithBuffer(2), WithDropPolicy(DropNewest))
+	oldest := d.Subscribe(WithBuffer(2), WithDropPolicy(DropOldest))
+	// Subscribers are served in order, so all frames reaching the last one means the
+	// others have seen them too.
+	all := d.Subscribe()
+	d.Start()
+	defer d.Stop()
+
+	ids := []uint32{0x100, 0x105, 0x200, 0x300, 0x400}
+	for _, id := range ids {
+		msg := []ZCAN_TransmitFD_Data{newFDTransmit(id, ZCAN_TX_NORMAL)}
+		if sent := zc.TransmitFD(tx.Handle(), msg, 1); sent != 1 {
+			t.Fatalf("Transmit of 0x%X failed", id)
+		}
+	}
+
+	if got := receiveFrames(t, all, len(ids)); got[4] != 0x400 {
+		t.Fatalf("Expected all frames in order, got %X", got)
+	}
+	got := receiveFrames(t, low, 3)
+	if got[0] != 0x100 || got[1] != 0x105 || got[2] != 0x300 {
+		t.Fatalf("Expected the filtered frames, got %X", got)
+	}
+	if got := receiveFrames(t, newest, 2); got[0] != 0x100 || got[1] != 0x105 || newest.Dropped() != 3 {
+		t.Fatalf("DropNewest kept %X and dropped %d", got, newest.Dropped())
+	}
+	if got := receiveFrames(t, oldest, 2); got[0] != 0x300 || got[1] != 0x400 || oldest.Dropped() != 3 {
+		t.Fatalf("DropOldest kept %X and dropped %d", got, oldest.Dropped())
+	}
+	if all.Dropped() != 0 || low.Dropped() != 0 {
+		t.Fatalf("Unexpected drops")
+	}
+
+	// A blocking subscriber holds up the others until it is closed.
+	block := d.Subscribe(WithBuffer(1), WithDropPolicy(DropBlock))
+	msgs := []ZCAN_TransmitFD_Data{
+		newFDTransmit(0x500, ZCAN_TX_NORMAL),
+		newFDTransmit(0x501, ZCAN_TX_NORMAL),
+		newFDTransmit(0x502, ZCAN_TX_NORMAL),
+	}
+	zc.TransmitFD(tx.Handle(), msgs, 3)
+	receiveFrames(t, all, 2)
+	select {
+	case f := <-all.Frames():
+		t.Fatalf("Frame 0x%X delivered past a blocked subscriber", f.ID())
+	case <-time.After(20 * time.Millisecond):
+	}
+	block.Close()
+	if got := receiveFrames(t, all, 1); got[0] != 0x502 {
+		t.Fatalf("Expected 0x502 after closing the blocking subscriber, got %X", got)
+	}
+	if f, ok := <-block.Frames(); !ok || f.ID() != 0x500 {
+		t.Fatalf("Expected the buffered frame before the channel closes")
+	}
+	if block.Dropped() != 1 {
+		t.Fatalf("Expected the blocked frame counted as dropped, got %d", block.Dropped())
+	}
+	if _, ok := <-block.Frames(); ok {
+		t.Fatalf("Expected the frame channel closed")
+	}
+}
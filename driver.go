@@ -0,0 +1,90 @@
+package zlgcan
+
+import (
+	"log/slog"
+	"sync"
+	"unsafe"
+)
+
+// driver is the vendor library behind a ZCAN: the zlgcan DLL on Windows or the Simulator.
+// Methods mirror the ZCAN_* functions one to one and return their raw results; the ZCAN
+// methods add locking, validation and conversion on top.
+type driver interface {
+	OpenDevice(deviceType int, deviceIndex int, reserved int) int
+	CloseDevice(deviceHandle int) int
+	GetDeviceInf(deviceHandle int, info *ZCAN_DEVICE_INFO) uint
+	IsDeviceOnLine(deviceHandle int) int
+
+	InitCAN(deviceHandle int, canIndex uint, initConfig *ZCAN_NORMAL_CHANNEL_INIT_CONFIG) int
+	InitCANFD(deviceHandle int, canIndex uint, initConfig *ZCAN_CANFD_CHANNEL_INIT_CONFIG) int
+	StartCAN(channelHandle int) uint
+	ResetCAN(channelHandle int) uint
+	ClearBuffer(channelHandle int) uint
+	ReadChannelErrInfo(channelHandle int, errInfo *ZCAN_CHANNEL_ERR_INFO) uint
+	ReadChannelStatus(channelHandle int, status *ZCAN_CHANNEL_STATUS) uint
+
+	GetReceiveNum(channelHandle int, canType uint) uint
+	Transmit(channelHandle int, msgs []ZCAN_Transmit_Data, len uint) uint
+	Receive(channelHandle int, msgs []ZCAN_Receive_Data, waitTime int) uint
+	TransmitFD(channelHandle int, msgs []ZCAN_TransmitFD_Data, len uint) uint
+	ReceiveFD(channelHandle int, msgs []ZCAN_ReceiveFD_Data, waitTime int) uint
+	TransmitData(deviceHandle int, objs []ZCAN_DATA_OBJ, len uint) uint
+	ReceiveData(deviceHandle int, objs []ZCAN_DATA_OBJ, waitTime int) uint
+
+	GetIProperty(deviceHandle int) (*ZCAN_IProperty, error)
+	SetValue(iproperty *ZCAN_IProperty, path, value string) uint
+	SetValuePtr(iproperty *ZCAN_IProperty, path string, value unsafe.Pointer) uint
+	GetValue(iproperty *ZCAN_IProperty, path string) string
+	ReleaseIProperty(iproperty *ZCAN_IProperty) uint
+
+	// Close unloads the library.
+	Close() error
+}
+
+// channelLocks serializes the calls the vendor library does not allow concurrently on one
+// channel. Receiving and sending are independent of each other.
+type channelLocks struct {
+	rx sync.Mutex // Receive, ReceiveFD, GetReceiveNum, ClearBuffer, ResetCAN
+	tx sync.Mutex // Transmit, TransmitFD
+}
+
+func (zc *ZCAN) channelLocks(channelHandle int) *channelLocks {
+	zc.mu.Lock()
+	defer zc.mu.Unlock()
+	if zc.chLocks == nil {
+		zc.chLocks = make(map[int]*channelLocks)
+	}
+	locks, ok := zc.chLocks[channelHandle]
+	if !ok {
+		locks = &channelLocks{}
+		zc.chLocks[channelHandle] = locks
+	}
+	return locks
+}
+
+// knownIProperty reports whether iproperty was obtained through zc.GetIProperty and is
+// not released yet; other pointers must not reach the driver. zc.propMu must be held.
+func (zc *ZCAN) knownIProperty(function string, iproperty *ZCAN_IProperty) bool {
+	zc.mu.Lock()
+	_, ok := zc.iproperties[iproperty]
+	zc.mu.Unlock()
+	if !ok {
+		zc.log(slog.LevelError, "unknown or released property interface", slog.String("function", function))
+	}
+	return ok
+}
+
+// withProperty runs fn with the IProperty of deviceHandle while holding the property lock.
+func (zc *ZCAN) withProperty(deviceHandle int, fn func(ip *ZCAN_IProperty) error) error {
+	zc.propMu.Lock()
+	defer zc.propMu.Unlock()
+	ip, err := zc.getIProperty(deviceHandle)
+	if err != nil {
+		return err
+	}
+	if ip == nil {
+		return errNoIProperty
+	}
+	defer zc.releaseIProperty(ip)
+	return fn(ip)
+}
@@ -0,0 +1,196 @@
+//go:build windows || (linux && cgo)
+
+package zlgcan
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+// dllDriver calls into the vendor library: zlgcan.dll on Windows, a shared object with the
+// same exports on Linux. Arguments are passed as machine words; UINT results are cut to 32
+// bits since the upper half of the return register is undefined.
+type dllDriver struct {
+	lib library
+}
+
+func loadDriver(dllPath string) (driver, error) {
+	lib, err := openLibrary(dllPath)
+	if err != nil {
+		return nil, fmt.Errorf("error loading %s: %w", dllPath, err)
+	}
+	return &dllDriver{lib: lib}, nil
+}
+
+// call calls the export name, a missing export fails like the call did.
+func (d *dllDriver) call(name string, args ...uintptr) uintptr {
+	proc := d.lib.proc(name)
+	if proc == 0 {
+		return 0
+	}
+	return callProc(proc, args...)
+}
+
+// cPointer turns an address returned by the library into a pointer. The memory belongs to
+// the library, so the garbage collector never moves or frees it.
+func cPointer(addr uintptr) unsafe.Pointer {
+	return *(*unsafe.Pointer)(unsafe.Pointer(&addr))
+}
+
+func (d *dllDriver) callUint(name string, args ...uintptr) uint {
+	return uint(uint32(d.call(name, args...)))
+}
+
+func (d *dllDriver) Close() error {
+	return d.lib.close()
+}
+
+func (d *dllDriver) OpenDevice(deviceType int, deviceIndex int, reserved int) int {
+	return int(d.call("ZCAN_OpenDevice", uintptr(deviceType), uintptr(deviceIndex), uintptr(reserved)))
+}
+
+func (d *dllDriver) CloseDevice(deviceHandle int) int {
+	return int(d.callUint("ZCAN_CloseDevice", uintptr(deviceHandle)))
+}
+
+func (d *dllDriver) GetDeviceInf(deviceHandle int, info *ZCAN_DEVICE_INFO) uint {
+	ret := d.callUint("ZCAN_GetDeviceInf", uintptr(deviceHandle), uintptr(unsafe.Pointer(info)))
+	runtime.KeepAlive(info)
+	return ret
+}
+
+func (d *dllDriver) IsDeviceOnLine(deviceHandle int) int {
+	return int(d.callUint("ZCAN_IsDeviceOnLine", uintptr(deviceHandle)))
+}
+
+func (d *dllDriver) InitCAN(deviceHandle int, canIndex uint, initConfig *ZCAN_NORMAL_CHANNEL_INIT_CONFIG) int {
+	ret := int(d.call("ZCAN_InitCAN", uintptr(deviceHandle), uintptr(canIndex), uintptr(unsafe.Pointer(initConfig))))
+	runtime.KeepAlive(initConfig)
+	return ret
+}
+
+func (d *dllDriver) InitCANFD(deviceHandle int, canIndex uint, initConfig *ZCAN_CANFD_CHANNEL_INIT_CONFIG) int {
+	ret := int(d.call("ZCAN_InitCAN", uintptr(deviceHandle), uintptr(canIndex), uintptr(unsafe.Pointer(initConfig))))
+	runtime.KeepAlive(initConfig)
+	return ret
+}
+
+func (d *dllDriver) StartCAN(channelHandle int) uint {
+	return d.callUint("ZCAN_StartCAN", uintptr(channelHandle))
+}
+
+func (d *dllDriver) ResetCAN(channelHandle int) uint {
+	return d.callUint("ZCAN_ResetCAN", uintptr(channelHandle))
+}
+
+func (d *dllDriver) ClearBuffer(channelHandle int) uint {
+	return d.callUint("ZCAN_ClearBuffer", uintptr(channelHandle))
+}
+
+func (d *dllDriver) ReadChannelErrInfo(channelHandle int, errInfo *ZCAN_CHANNEL_ERR_INFO) uint {
+	ret := d.callUint("ZCAN_ReadChannelErrInfo", uintptr(channelHandle), uintptr(unsafe.Pointer(errInfo)))
+	runtime.KeepAlive(errInfo)
+	return ret
+}
+
+func (d *dllDriver) ReadChannelStatus(channelHandle int, status *ZCAN_CHANNEL_STATUS) uint {
+	ret := d.callUint("ZCAN_ReadChannelStatus", uintptr(channelHandle), uintptr(unsafe.Pointer(status)))
+	runtime.KeepAlive(status)
+	return ret
+}
+
+func (d *dllDriver) GetReceiveNum(channelHandle int, canType uint) uint {
+	return d.callUint("ZCAN_GetReceiveNum", uintptr(channelHandle), uintptr(canType))
+}
+
+func (d *dllDriver) Transmit(channelHandle int, msgs []ZCAN_Transmit_Data, len uint) uint {
+	if len == 0 {
+		return 0
+	}
+	ret := d.callUint("ZCAN_Transmit", uintptr(channelHandle), uintptr(unsafe.Pointer(&msgs[0])), uintptr(len))
+	runtime.KeepAlive(msgs)
+	return ret
+}
+
+func (d *dllDriver) Receive(channelHandle int, msgs []ZCAN_Receive_Data, waitTime int) uint {
+	if len(msgs) == 0 {
+		return 0
+	}
+	ret := d.callUint("ZCAN_Receive", uintptr(channelHandle), uintptr(unsafe.Pointer(&msgs[0])), uintptr(len(msgs)), uintptr(waitTime))
+	runtime.KeepAlive(msgs)
+	return ret
+}
+
+func (d *dllDriver) TransmitFD(channelHandle int, msgs []ZCAN_TransmitFD_Data, len uint) uint {
+	if len == 0 {
+		return 0
+	}
+	ret := d.callUint("ZCAN_TransmitFD", uintptr(channelHandle), uintptr(unsafe.Pointer(&msgs[0])), uintptr(len))
+	runtime.KeepAlive(msgs)
+	return ret
+}
+
+func (d *dllDriver) ReceiveFD(channelHandle int, msgs []ZCAN_ReceiveFD_Data, waitTime int) uint {
+	if len(msgs) == 0 {
+		return 0
+	}
+	ret := d.callUint("ZCAN_ReceiveFD", uintptr(channelHandle), uintptr(unsafe.Pointer(&msgs[0])), uintptr(len(msgs)), uintptr(waitTime))
+	runtime.KeepAlive(msgs)
+	return ret
+}
+
+func (d *dllDriver) TransmitData(deviceHandle int, objs []ZCAN_DATA_OBJ, len uint) uint {
+	if len == 0 {
+		return 0
+	}
+	ret := d.callUint("ZCAN_TransmitData", uintptr(deviceHandle), uintptr(unsafe.Pointer(&objs[0])), uintptr(len))
+	runtime.KeepAlive(objs)
+	return ret
+}
+
+func (d *dllDriver) ReceiveData(deviceHandle int, objs []ZCAN_DATA_OBJ, waitTime int) uint {
+	if len(objs) == 0 {
+		return 0
+	}
+	ret := d.callUint("ZCAN_ReceiveData", uintptr(deviceHandle), uintptr(unsafe.Pointer(&objs[0])), uintptr(len(objs)), uintptr(waitTime))
+	runtime.KeepAlive(objs)
+	return ret
+}
+
+func (d *dllDriver) GetIProperty(deviceHandle int) (*ZCAN_IProperty, error) {
+	ret := d.call("GetIProperty", uintptr(deviceHandle))
+	return (*ZCAN_IProperty)(cPointer(ret)), nil
+}
+
+func (d *dllDriver) SetValue(iproperty *ZCAN_IProperty, path, value string) uint {
+	cPath := C.CString(path)
+	cValue := C.CString(value)
+	defer C.free(unsafe.Pointer(cPath))
+	defer C.free(unsafe.Pointer(cValue))
+	ret := callProc(uintptr(unsafe.Pointer(iproperty.SetValue)), uintptr(unsafe.Pointer(cPath)), uintptr(unsafe.Pointer(cValue)))
+	return uint(uint32(ret))
+}
+
+func (d *dllDriver) SetValuePtr(iproperty *ZCAN_IProperty, path string, value unsafe.Pointer) uint {
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+	ret := callProc(uintptr(unsafe.Pointer(iproperty.SetValue)), uintptr(unsafe.Pointer(cPath)), uintptr(value))
+	runtime.KeepAlive(value)
+	return uint(uint32(ret))
+}
+
+func (d *dllDriver) GetValue(iproperty *ZCAN_IProperty, path string) string {
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+	ret := callProc(uintptr(unsafe.Pointer(iproperty.GetValue)), uintptr(unsafe.Pointer(cPath)))
+	return C.GoString((*C.char)(cPointer(ret)))
+}
+
+func (d *dllDriver) ReleaseIProperty(iproperty *ZCAN_IProperty) uint {
+	return d.callUint("ReleaseIProperty", uintptr(unsafe.Pointer(iproperty)))
+}
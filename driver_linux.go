@@ -0,0 +1,79 @@
+//go:build linux && cgo
+
+package zlgcan
+
+/*
+#cgo LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stdint.h>
+#include <stdlib.h>
+
+typedef uintptr_t W;
+
+// zlg_call calls fn with its first n arguments from a, like SyscallN does on Windows.
+static W zlg_call(W fn, int n, W a0, W a1, W a2, W a3, W a4, W a5) {
+	switch (n) {
+	case 0: return ((W (*)(void))fn)();
+	case 1: return ((W (*)(W))fn)(a0);
+	case 2: return ((W (*)(W, W))fn)(a0, a1);
+	case 3: return ((W (*)(W, W, W))fn)(a0, a1, a2);
+	case 4: return ((W (*)(W, W, W, W))fn)(a0, a1, a2, a3);
+	case 5: return ((W (*)(W, W, W, W, W))fn)(a0, a1, a2, a3, a4);
+	}
+	return ((W (*)(W, W, W, W, W, W))fn)(a0, a1, a2, a3, a4, a5);
+}
+*/
+import "C"
+import (
+	"errors"
+	"unsafe"
+)
+
+const maxCallArgs = 6
+
+// library is a shared object loaded with dlopen.
+type library struct {
+	handle unsafe.Pointer
+}
+
+func dlerror() error {
+	if msg := C.dlerror(); msg != nil {
+		return errors.New(C.GoString(msg))
+	}
+	return errors.New("unknown dlopen error")
+}
+
+func openLibrary(path string) (library, error) {
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+	handle := C.dlopen(cPath, C.RTLD_NOW|C.RTLD_LOCAL)
+	if handle == nil {
+		return library{}, dlerror()
+	}
+	return library{handle: handle}, nil
+}
+
+// proc returns the address of the export name, 0 if there is none.
+func (l library) proc(name string) uintptr {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	return uintptr(C.dlsym(l.handle, cName))
+}
+
+func (l library) close() error {
+	if C.dlclose(l.handle) != 0 {
+		return dlerror()
+	}
+	return nil
+}
+
+func callProc(fn uintptr, args ...uintptr) uintptr {
+	if len(args) > maxCallArgs {
+		panic("zlgcan: too many arguments")
+	}
+	var a [maxCallArgs]C.W
+	for i, arg := range args {
+		a[i] = C.W(arg)
+	}
+	return uintptr(C.zlg_call(C.W(fn), C.int(len(args)), a[0], a[1], a[2], a[3], a[4], a[5]))
+}
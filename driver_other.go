@@ -0,0 +1,9 @@
+//go:build !windows && !(linux && cgo)
+
+package zlgcan
+
+import "fmt"
+
+func loadDriver(dllPath string) (driver, error) {
+	return nil, fmt.Errorf("unsupported OS")
+}
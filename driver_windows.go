@@ -0,0 +1,33 @@
+//go:build windows
+
+package zlgcan
+
+import "syscall"
+
+// library is a DLL loaded with LoadLibrary.
+type library struct {
+	dll syscall.Handle
+}
+
+func openLibrary(path string) (library, error) {
+	dll, err := syscall.LoadLibrary(path)
+	return library{dll: dll}, err
+}
+
+// proc returns the address of the export name, 0 if there is none.
+func (l library) proc(name string) uintptr {
+	proc, err := syscall.GetProcAddress(l.dll, name)
+	if err != nil {
+		return 0
+	}
+	return proc
+}
+
+func (l library) close() error {
+	return syscall.FreeLibrary(l.dll)
+}
+
+func callProc(fn uintptr, args ...uintptr) uintptr {
+	ret, _, _ := syscall.SyscallN(fn, args...)
+	return ret
+}
@@ -0,0 +1,213 @@
+package zlgcan
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrEchoTimeout = errors.New("timeout waiting for transmit echo")
+
+// Echo is a transmitted frame waiting for its self-receive echo.
+type Echo struct {
+	id        uint32
+	data      []byte
+	done      chan struct{}
+	timestamp uint64
+}
+
+// Done is closed once the echo has been matched.
+func (e *Echo) Done() <-chan struct{} {
+	return e.done
+}
+
+// Wait blocks until the echo arrives and returns its hardware timestamp.
+func (e *Echo) Wait(timeout time.Duration) (uint64, error) {
+	select {
+	case <-e.done:
+		return e.timestamp, nil
+	case <-time.After(timeout):
+		return 0, ErrEchoTimeout
+	}
+}
+
+// EchoMatcher matches frames received on a channel against frames sent with
+// ZCAN_TX_SELF_RX or ZCAN_TX_SINGLE_SHOT_SELF_RX. Echoes are matched in send order
+// on the ID word, length and payload.
+type EchoMatcher struct {
+	mu      sync.Mutex
+	pending []*Echo
+}
+
+func (m *EchoMatcher) expect(id uint32, data []byte) *Echo {
+	e := &Echo{id: id, data: append([]byte(nil), data...), done: make(chan struct{})}
+	m.mu.Lock()
+	m.pending = append(m.pending, e)
+	m.mu.Unlock()
+	return e
+}
+
+// Expect registers a CAN frame about to be transmitted.
+func (m *EchoMatcher) Expect(frame *ZCAN_CAN_FRAME) *Echo {
+	return m.expect(frame.Id, frame.Data[:min(int(frame.Dlc), len(frame.Data))])
+}
+
+// ExpectFD registers a CANFD frame about to be transmitted.
+func (m *EchoMatcher) ExpectFD(frame *ZCAN_CANFD_FRAME) *Echo {
+	return m.expect(frame.Id, frame.Data[:min(int(frame.Len), len(frame.Data))])
+}
+
+func (m *EchoMatcher) match(id uint32, data []byte, timestamp uint64) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, e := range m.pending {
+		if e.id == id && bytes.Equal(e.data, data) {
+			m.pending = append(m.pending[:i], m.pending[i+1:]...)
+			e.timestamp = timestamp
+			close(e.done)
+			return true
+		}
+	}
+	return false
+}
+
+// Match consumes rcv if it is the echo of a pending frame.
+func (m *EchoMatcher) Match(rcv *ZCAN_Receive_Data) bool {
+	return m.match(rcv.Frame.Id, rcv.Frame.Data[:min(int(rcv.Frame.Dlc), len(rcv.Frame.Data))], rcv.Timestamp)
+}
+
+// MatchFD consumes rcv if it is the echo of a pending frame.
+func (m *EchoMatcher) MatchFD(rcv *ZCAN_ReceiveFD_Data) bool {
+	return m.match(rcv.Frame.Id, rcv.Frame.Data[:min(int(rcv.Frame.Len), len(rcv.Frame.Data))], rcv.Timestamp)
+}
+
+// Pending returns the number of echoes still outstanding.
+func (m *EchoMatcher) Pending() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.pending)
+}
+
+// Forget drops e from the pending list, e.g. after a timeout.
+func (m *EchoMatcher) Forget(e *Echo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, p := range m.pending {
+		if p == e {
+			m.pending = append(m.pending[:i], m.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// TxConfirmation reports whether a transmitted frame was seen leaving the controller.
+type TxConfirmation struct {
+	Confirmed bool
+	Timestamp uint64 // hardware timestamp of the echo
+}
+
+const echoPollInterval = time.Millisecond
+
+// TransmitConfirmed sends msgs with self-receive enabled and waits up to timeout for their
+// echoes. Frames received in the meantime that are not echoes are returned in others.
+// msgs is left as it is; the frames are sent from a copy with the self-receive type.
+// It reads the receive buffer itself, so it needs exclusive use of the channel: a running
+// Dispatcher or Receive loop would take the echoes away from it, and it would take their
+// frames. Use an EchoMatcher fed by that loop instead.
+func (ch *Channel) TransmitConfirmed(msgs []ZCAN_Transmit_Data, timeout time.Duration) ([]TxConfirmation, []ZCAN_Receive_Data, error) {
+	if len(msgs) == 0 {
+		return nil, nil, nil
+	}
+	msgs = append([]ZCAN_Transmit_Data(nil), msgs...)
+	var matcher EchoMatcher
+	echoes := make([]*Echo, len(msgs))
+	for i := range msgs {
+		msgs[i].Type = msgs[i].Type.SelfRx()
+		echoes[i] = matcher.Expect(&msgs[i].Frame)
+	}
+	sent := int(ch.zc.Transmit(ch.Handle(), msgs, uint(len(msgs))))
+	for _, e := range echoes[min(sent, len(echoes)):] {
+		matcher.Forget(e)
+	}
+
+	var others []ZCAN_Receive_Data
+	deadline := time.Now().Add(timeout)
+	for matcher.Pending() > 0 && time.Now().Before(deadline) {
+		num := ch.zc.GetReceiveNum(ch.Handle(), ZCAN_TYPE_CAN)
+		if num == 0 {
+			time.Sleep(echoPollInterval)
+			continue
+		}
+		rcv, n := ch.zc.Receive(ch.Handle(), num, int(echoPollInterval/time.Millisecond))
+		for i := range rcv[:min(int(n), len(rcv))] {
+			if !matcher.Match(&rcv[i]) {
+				others = append(others, rcv[i])
+			}
+		}
+	}
+	return confirmations(echoes, sent), others, echoError(echoes, sent)
+}
+
+// TransmitFDConfirmed is TransmitConfirmed for CANFD frames, with the same need for
+// exclusive use of the channel.
+func (ch *Channel) TransmitFDConfirmed(msgs []ZCAN_TransmitFD_Data, timeout time.Duration) ([]TxConfirmation, []ZCAN_ReceiveFD_Data, error) {
+	if len(msgs) == 0 {
+		return nil, nil, nil
+	}
+	msgs = append([]ZCAN_TransmitFD_Data(nil), msgs...)
+	var matcher EchoMatcher
+	echoes := make([]*Echo, len(msgs))
+	for i := range msgs {
+		msgs[i].Type = msgs[i].Type.SelfRx()
+		echoes[i] = matcher.ExpectFD(&msgs[i].Frame)
+	}
+	sent := int(ch.zc.TransmitFD(ch.Handle(), msgs, uint(len(msgs))))
+	for _, e := range echoes[min(sent, len(echoes)):] {
+		matcher.Forget(e)
+	}
+
+	var others []ZCAN_ReceiveFD_Data
+	deadline := time.Now().Add(timeout)
+	for matcher.Pending() > 0 && time.Now().Before(deadline) {
+		num := ch.zc.GetReceiveNum(ch.Handle(), ZCAN_TYPE_CANFD)
+		if num == 0 {
+			time.Sleep(echoPollInterval)
+			continue
+		}
+		rcv, n := ch.zc.ReceiveFD(ch.Handle(), num, int(echoPollInterval/time.Millisecond))
+		for i := range rcv[:min(int(n), len(rcv))] {
+			if !matcher.MatchFD(&rcv[i]) {
+				others = append(others, rcv[i])
+			}
+		}
+	}
+	return confirmations(echoes, sent), others, echoError(echoes, sent)
+}
+
+func confirmations(echoes []*Echo, sent int) []TxConfirmation {
+	result := make([]TxConfirmation, len(echoes))
+	for i, e := range echoes[:min(sent, len(echoes))] {
+		select {
+		case <-e.done:
+			result[i] = TxConfirmation{Confirmed: true, Timestamp: e.timestamp}
+		default:
+		}
+	}
+	return result
+}
+
+func echoError(echoes []*Echo, sent int) error {
+	if sent < len(echoes) {
+		return fmt.Errorf("driver accepted %d of %d frames", sent, len(echoes))
+	}
+	for _, e := range echoes {
+		select {
+		case <-e.done:
+		default:
+			return ErrEchoTimeout
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package zlgcan
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Test that echoes are matched to the transmitted frames in order
+func TestEchoMatcher(t *testing.T) {
+	var matcher EchoMatcher
+
+	msgs := make([]ZCAN_Transmit_Data, 2)
+	for i := range msgs {
+		msgs[i].Frame.GenerateID(0x100, 0, 0, 0)
+		msgs[i].Frame.Dlc = 2
+		msgs[i].Frame.Data[0] = uint8(i)
+	}
+	first := matcher.Expect(&msgs[0].Frame)
+	second := matcher.Expect(&msgs[1].Frame)
+
+	other := ZCAN_Receive_Data{Frame: msgs[1].Frame, Timestamp: 5}
+	other.Frame.Data[1] = 0xFF
+	if matcher.Match(&other) {
+		t.Fatalf("Frame with different payload matched as echo")
+	}
+
+	echo := ZCAN_Receive_Data{Frame: msgs[1].Frame, Timestamp: 42}
+	echo.Frame.Data[7] = 0xAA // beyond DLC, ignored
+	if !matcher.Match(&echo) {
+		t.Fatalf("Echo of the second frame was not matched")
+	}
+	ts, err := second.Wait(time.Millisecond)
+	if err != nil || ts != 42 {
+		t.Fatalf("Second echo: ts %d, err %v", ts, err)
+	}
+
+	if _, err := first.Wait(time.Millisecond); !errors.Is(err, ErrEchoTimeout) {
+		t.Fatalf("First echo: expected ErrEchoTimeout, got %v", err)
+	}
+	if matcher.Pending() != 1 {
+		t.Fatalf("Expected 1 pending echo, got %d", matcher.Pending())
+	}
+	matcher.Forget(first)
+	if matcher.Pending() != 0 {
+		t.Fatalf("Expected no pending echo, got %d", matcher.Pending())
+	}
+}
+
+// Test for the self-receive variants of the transmit types
+func TestTransmitTypeSelfRx(t *testing.T) {
+	if ZCAN_TX_NORMAL.SelfRx() != ZCAN_TX_SELF_RX || ZCAN_TX_SINGLE_SHOT.SelfRx() != ZCAN_TX_SINGLE_SHOT_SELF_RX {
+		t.Fatalf("SelfRx mapping is wrong")
+	}
+	if ZCAN_TX_SELF_RX.SelfRx() != ZCAN_TX_SELF_RX {
+		t.Fatalf("SelfRx of ZCAN_TX_SELF_RX should be unchanged")
+	}
+}
+
+// Test transmit confirmation through the self-receive echo of a simulated channel
+func TestTransmitConfirmedSimulated(t *testing.T) {
+	sim := NewSimulator()
+	zc, handle := newSimulatedDevice(t, sim, ZCAN_USBCANFD_200U, 0)
+	ch0, err := zc.OpenChannel(handle, 0)
+	if err != nil {
+		t.Fatalf("OpenChannel 0 failed: %v", err)
+	}
+	ch1, err := zc.OpenChannel(handle, 1)
+	if err != nil {
+		t.Fatalf("OpenChannel 1 failed: %v", err)
+	}
+
+	// A frame from the peer already waits in the buffer of channel 0.
+	peer := []ZCAN_Transmit_Data{{}}
+	peer[0].Frame.GenerateID(0x7FF, 0, 0, 0)
+	if sent := zc.Transmit(ch1.Handle(), peer, 1); sent != 1 {
+		t.Fatalf("Peer frame not sent")
+	}
+	msgs := make([]ZCAN_Transmit_Data, 2)
+	for i := range msgs {
+		msgs[i].Frame.GenerateID(uint32(0x100+i), 0, 0, 0)
+		msgs[i].Frame.Dlc = 1
+	}
+	confs, others, err := ch0.TransmitConfirmed(msgs, time.Second)
+	if err != nil {
+		t.Fatalf("TransmitConfirmed failed: %v", err)
+	}
+	if len(confs) != 2 || !confs[0].Confirmed || !confs[1].Confirmed || confs[1].Timestamp < confs[0].Timestamp {
+		t.Fatalf("Unexpected confirmations %+v", confs)
+	}
+	if msgs[0].Type != ZCAN_TX_NORMAL || msgs[1].Type != ZCAN_TX_NORMAL {
+		t.Fatalf("Expected the caller's transmit types unchanged, got %s, %s", msgs[0].Type, msgs[1].Type)
+	}
+	if len(others) != 1 || others[0].Frame.GetFrameID() != 0x7FF {
+		t.Fatalf("Expected the peer frame in others, got %+v", others)
+	}
+	if num := zc.GetReceiveNum(ch1.Handle(), ZCAN_TYPE_CAN); num != 2 {
+		t.Fatalf("Expected both frames on the bus, got %d", num)
+	}
+
+	fd := []ZCAN_TransmitFD_Data{newFDTransmit(0x200, ZCAN_TX_SINGLE_SHOT)}
+	confs, _, err = ch0.TransmitFDConfirmed(fd, time.Second)
+	if err != nil || !confs[0].Confirmed {
+		t.Fatalf("TransmitFDConfirmed failed: %+v, %v", confs, err)
+	}
+	if fd[0].Type != ZCAN_TX_SINGLE_SHOT {
+		t.Fatalf("Expected the caller's transmit type unchanged, got %s", fd[0].Type)
+	}
+
+	// A frame the driver does not accept is never confirmed.
+	ch0.Close()
+	confs, _, err = ch0.TransmitConfirmed(msgs[:1], 10*time.Millisecond)
+	if err == nil || confs[0].Confirmed {
+		t.Fatalf("Expected an unsent frame to fail, got %+v, %v", confs, err)
+	}
+}
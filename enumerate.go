@@ -0,0 +1,158 @@
+package zlgcan
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// EnumerateMaxIndex is the number of device indexes probed per device type.
+const EnumerateMaxIndex = 8
+
+// DeviceDescriptor describes a device found by Enumerate.
+type DeviceDescriptor struct {
+	DeviceType  int
+	DeviceIndex int
+	Name        string
+	Serial      string
+	HwType      string
+	HwVersion   string
+	FwVersion   string
+	CanNum      uint8
+}
+
+func (d DeviceDescriptor) String() string {
+	name := d.Name
+	if name == "" {
+		name = fmt.Sprintf("type 0x%x", d.DeviceType)
+	}
+	return fmt.Sprintf("%s #%d: serial=%s hw=%s fw=%s channels=%d", name, d.DeviceIndex, d.Serial, d.HwType, d.FwVersion, d.CanNum)
+}
+
+func newDeviceDescriptor(deviceType int, deviceIndex int, info *ZCAN_DEVICE_INFO) DeviceDescriptor {
+	desc := DeviceDescriptor{DeviceType: deviceType, DeviceIndex: deviceIndex}
+	if spec, ok := GetDeviceSpec(deviceType); ok {
+		desc.Name = spec.Name
+	}
+	desc.Serial = info.Serial()
+	desc.HwType = info.HwType()
+	desc.HwVersion = info.HwVersion()
+	desc.FwVersion = info.FwVersion()
+	desc.CanNum = info.CanNum()
+	return desc
+}
+
+// openedDevice returns the handle of a device this ZCAN already holds open.
+func (zc *ZCAN) openedDevice(deviceType int, deviceIndex int) (int, *deviceEntry, bool) {
+	zc.mu.Lock()
+	defer zc.mu.Unlock()
+	for handle, entry := range zc.devices {
+		if entry.deviceType == deviceType && entry.deviceIndex == deviceIndex {
+			return handle, entry, true
+		}
+	}
+	return INVALID_DEVICE_HANDLE, nil, false
+}
+
+// Enumerate probes device indexes 0..EnumerateMaxIndex-1 of each given device type and
+// returns the devices that could be opened. Probe handles are closed before returning;
+// devices already opened through zc are reported without being reopened. Devices whose
+// ZCAN_DEVICE_INFO cannot be read are skipped, since they cannot be told apart by serial.
+// Network device types open without contacting the hardware, so they should not be probed.
+func (zc *ZCAN) Enumerate(types ...int) ([]DeviceDescriptor, error) {
+	if zc.drv == nil {
+		return nil, errors.New("zlgcan library not loaded")
+	}
+	if len(types) == 0 {
+		return nil, errors.New("no device types to enumerate")
+	}
+
+	var found []DeviceDescriptor
+	for _, deviceType := range types {
+		for deviceIndex := 0; deviceIndex < EnumerateMaxIndex; deviceIndex++ {
+			if handle, entry, ok := zc.openedDevice(deviceType, deviceIndex); ok {
+				if info := zc.deviceInfo(entry, handle); info != nil {
+					found = append(found, newDeviceDescriptor(deviceType, deviceIndex, info))
+				}
+				continue
+			}
+			handle := zc.probeDevice(deviceType, deviceIndex)
+			if handle == INVALID_DEVICE_HANDLE {
+				continue
+			}
+			if info := zc.GetDeviceInf(handle); info != nil {
+				found = append(found, newDeviceDescriptor(deviceType, deviceIndex, info))
+			}
+			zc.CloseDevice(handle)
+		}
+	}
+	return found, nil
+}
+
+// probeDevice opens a device index that may well be empty, so a miss is only logged at
+// debug level.
+func (zc *ZCAN) probeDevice(deviceType int, deviceIndex int) int {
+	return zc.openDevice(deviceType, deviceIndex, 0, slog.LevelDebug)
+}
+
+// OpenBySerial opens the device of deviceType whose ZCAN_DEVICE_INFO.Serial() equals serial.
+// Device index ordering is not stable across reboots, so rigs with several identical boxes
+// should open them by serial. A device already open through zc is not handed out again,
+// since closing that handle would close it for its channels too; ErrDeviceBusy is returned.
+func (zc *ZCAN) OpenBySerial(deviceType int, serial string) (int, error) {
+	if zc.drv == nil {
+		return INVALID_DEVICE_HANDLE, errors.New("zlgcan library not loaded")
+	}
+
+	var seen []string
+	for deviceIndex := 0; deviceIndex < EnumerateMaxIndex; deviceIndex++ {
+		if handle, entry, ok := zc.openedDevice(deviceType, deviceIndex); ok {
+			if info := zc.deviceInfo(entry, handle); info != nil {
+				if info.Serial() == serial {
+					return INVALID_DEVICE_HANDLE, fmt.Errorf("%w: serial %q is open as handle %d", ErrDeviceBusy, serial, handle)
+				}
+				seen = append(seen, info.Serial())
+			}
+			continue
+		}
+		handle := zc.probeDevice(deviceType, deviceIndex)
+		if handle == INVALID_DEVICE_HANDLE {
+			continue
+		}
+		entry, _ := zc.device(handle)
+		info := zc.deviceInfo(entry, handle)
+		if info != nil && info.Serial() == serial {
+			return handle, nil
+		}
+		if info != nil {
+			seen = append(seen, info.Serial())
+		}
+		zc.CloseDevice(handle)
+	}
+
+	name := fmt.Sprintf("device type 0x%x", deviceType)
+	if spec, ok := GetDeviceSpec(deviceType); ok {
+		name = spec.Name
+	}
+	return INVALID_DEVICE_HANDLE, fmt.Errorf("%w: serial %q on %s, seen %q", ErrDeviceNotFound, serial, name, seen)
+}
+
+// DeviceConfig selects a device from a configuration file.
+// When Serial is set it takes precedence over DeviceIndex.
+type DeviceConfig struct {
+	DeviceType  int    `json:"device_type"`
+	DeviceIndex int    `json:"device_index"`
+	Serial      string `json:"serial,omitempty"`
+}
+
+// OpenConfigured opens the device selected by cfg.
+func (zc *ZCAN) OpenConfigured(cfg DeviceConfig) (int, error) {
+	if cfg.Serial != "" {
+		return zc.OpenBySerial(cfg.DeviceType, cfg.Serial)
+	}
+	handle := zc.OpenDevice(cfg.DeviceType, cfg.DeviceIndex, 0)
+	if handle == INVALID_DEVICE_HANDLE {
+		return INVALID_DEVICE_HANDLE, fmt.Errorf("%w: device type 0x%x index %d", ErrDeviceNotFound, cfg.DeviceType, cfg.DeviceIndex)
+	}
+	return handle, nil
+}
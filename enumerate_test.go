@@ -0,0 +1,177 @@
+package zlgcan
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// Test for Enumerate against simulated devices
+func TestEnumerateSimulated(t *testing.T) {
+	sim := NewSimulator()
+	zc, opened := newSimulatedDevice(t, sim, ZCAN_USBCANFD_200U, 0)
+	for _, d := range []struct {
+		deviceType, deviceIndex int
+		serial                  string
+	}{{ZCAN_USBCANFD_200U, 2, "SIM0002"}, {ZCAN_USBCAN2, 0, "SIMC0000"}} {
+		if err := sim.AddDevice(d.deviceType, d.deviceIndex, d.serial); err != nil {
+			t.Fatalf("AddDevice failed: %v", err)
+		}
+	}
+
+	// An opened device that no longer reports its info is skipped.
+	sim.SetOnline(ZCAN_USBCANFD_200U, 0, false)
+	devices, err := zc.Enumerate(ZCAN_USBCANFD_200U, ZCAN_USBCAN2)
+	if err != nil {
+		t.Fatalf("Enumerate failed: %v", err)
+	}
+	if len(devices) != 2 || devices[0].Serial != "SIM0002" || devices[1].Serial != "SIMC0000" {
+		t.Fatalf("Expected the device without info skipped, got %v", devices)
+	}
+
+	sim.SetOnline(ZCAN_USBCANFD_200U, 0, true)
+	devices, err = zc.Enumerate(ZCAN_USBCANFD_200U, ZCAN_USBCAN2)
+	if err != nil {
+		t.Fatalf("Enumerate failed: %v", err)
+	}
+	var serials []string
+	for _, d := range devices {
+		serials = append(serials, d.Serial)
+	}
+	if len(devices) != 3 || serials[0] != "SIM0000" || serials[1] != "SIM0002" || serials[2] != "SIMC0000" {
+		t.Fatalf("Unexpected devices %v", devices)
+	}
+	if d := devices[1]; d.DeviceIndex != 2 || d.Name != "USBCANFD-200U" || d.HwType != "USBCANFD-200U" || d.CanNum != 2 {
+		t.Fatalf("Unexpected descriptor %+v", d)
+	}
+	if ret := zc.IsDeviceOnLine(opened); ret != ZCAN_STATUS_ONLINE {
+		t.Fatalf("Expected the device opened before to stay open, got %d", ret)
+	}
+	// The probed devices were closed again.
+	if handle := zc.OpenDevice(ZCAN_USBCANFD_200U, 2, 0); handle == INVALID_DEVICE_HANDLE {
+		t.Fatalf("Expected the probed device to be closed")
+	} else {
+		zc.CloseDevice(handle)
+	}
+
+	// An unplugged device cannot be opened.
+	sim.SetOnline(ZCAN_USBCANFD_200U, 2, false)
+	devices, err = zc.Enumerate(ZCAN_USBCANFD_200U, ZCAN_USBCAN2)
+	if err != nil {
+		t.Fatalf("Enumerate failed: %v", err)
+	}
+	if len(devices) != 2 || devices[0].Serial != "SIM0000" || devices[1].Serial != "SIMC0000" {
+		t.Fatalf("Expected only the online devices, got %v", devices)
+	}
+
+	if _, err := zc.Enumerate(); err == nil {
+		t.Fatalf("Expected Enumerate without types to fail")
+	}
+}
+
+// Test for OpenBySerial and OpenConfigured against simulated devices
+func TestOpenBySerialSimulated(t *testing.T) {
+	sim := NewSimulator()
+	for i, serial := range []string{"SIM-A", "SIM-B", "SIM-C"} {
+		if err := sim.AddDevice(ZCAN_USBCANFD_200U, i*2, serial); err != nil {
+			t.Fatalf("AddDevice failed: %v", err)
+		}
+	}
+	zc := NewSimulatedZCAN(sim)
+
+	handle, err := zc.OpenBySerial(ZCAN_USBCANFD_200U, "SIM-B")
+	if err != nil {
+		t.Fatalf("OpenBySerial failed: %v", err)
+	}
+	if info := zc.GetDeviceInf(handle); info == nil || info.Serial() != "SIM-B" {
+		t.Fatalf("OpenBySerial opened the wrong device: %+v", info)
+	}
+	// Devices probed on the way are closed again, the open one is not handed out twice.
+	if other := zc.OpenDevice(ZCAN_USBCANFD_200U, 0, 0); other == INVALID_DEVICE_HANDLE {
+		t.Fatalf("Expected the probed device to be closed")
+	} else {
+		zc.CloseDevice(other)
+	}
+	if again, err := zc.OpenBySerial(ZCAN_USBCANFD_200U, "SIM-B"); !errors.Is(err, ErrDeviceBusy) || again != INVALID_DEVICE_HANDLE {
+		t.Fatalf("Expected ErrDeviceBusy for the open device, got %d, %v", again, err)
+	}
+	zc.CloseDevice(handle)
+	if again, err := zc.OpenBySerial(ZCAN_USBCANFD_200U, "SIM-B"); err != nil || again == INVALID_DEVICE_HANDLE {
+		t.Fatalf("Expected the closed device to open again, got %d, %v", again, err)
+	}
+
+	_, err = zc.OpenBySerial(ZCAN_USBCANFD_200U, "NO-SUCH-SERIAL")
+	if !errors.Is(err, ErrDeviceNotFound) {
+		t.Fatalf("Expected ErrDeviceNotFound, got %v", err)
+	}
+	for _, serial := range []string{"SIM-A", "SIM-B", "SIM-C"} {
+		if !strings.Contains(err.Error(), serial) {
+			t.Fatalf("Expected the seen serial %s in %v", serial, err)
+		}
+	}
+
+	cfgHandle, err := zc.OpenConfigured(DeviceConfig{DeviceType: ZCAN_USBCANFD_200U, DeviceIndex: 0, Serial: "SIM-C"})
+	if err != nil {
+		t.Fatalf("OpenConfigured by serial failed: %v", err)
+	}
+	if info := zc.GetDeviceInf(cfgHandle); info == nil || info.Serial() != "SIM-C" {
+		t.Fatalf("Expected the serial to take precedence over the index, got %+v", info)
+	}
+	cfgHandle, err = zc.OpenConfigured(DeviceConfig{DeviceType: ZCAN_USBCANFD_200U, DeviceIndex: 0})
+	if err != nil {
+		t.Fatalf("OpenConfigured by index failed: %v", err)
+	}
+	if info := zc.GetDeviceInf(cfgHandle); info == nil || info.Serial() != "SIM-A" {
+		t.Fatalf("Expected device index 0, got %+v", info)
+	}
+	if _, err := zc.OpenConfigured(DeviceConfig{DeviceType: ZCAN_USBCANFD_200U, DeviceIndex: 1}); !errors.Is(err, ErrDeviceNotFound) {
+		t.Fatalf("Expected ErrDeviceNotFound for an empty index, got %v", err)
+	}
+	if _, err := zc.OpenConfigured(DeviceConfig{DeviceType: ZCAN_USBCANFD_200U, Serial: "SIM-X"}); !errors.Is(err, ErrDeviceNotFound) {
+		t.Fatalf("Expected ErrDeviceNotFound for an unknown serial, got %v", err)
+	}
+}
+
+// Test that empty indexes found while probing are not logged as errors
+func TestEnumerateProbeLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	sim := NewSimulator()
+	if err := sim.AddDevice(ZCAN_USBCANFD_200U, 0, "SIM0000"); err != nil {
+		t.Fatalf("AddDevice failed: %v", err)
+	}
+	zc := NewSimulatedZCAN(sim, WithLogger(logger))
+	if found, err := zc.Enumerate(ZCAN_USBCANFD_200U); err != nil || len(found) != 1 {
+		t.Fatalf("Expected one device, got %v, %v", found, err)
+	}
+	if _, err := zc.OpenBySerial(ZCAN_USBCANFD_200U, "MISSING"); !errors.Is(err, ErrDeviceNotFound) {
+		t.Fatalf("Expected ErrDeviceNotFound, got %v", err)
+	}
+
+	misses := 0
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var r map[string]any
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("Bad log line %q: %v", line, err)
+		}
+		if r["level"] != "DEBUG" {
+			t.Fatalf("Expected probing to log at debug level only, got %v", r)
+		}
+		if r["function"] == "ZCAN_OpenDevice" {
+			misses++
+		}
+	}
+	if misses != 2*(EnumerateMaxIndex-1) {
+		t.Fatalf("Expected %d probe misses, got %d", 2*(EnumerateMaxIndex-1), misses)
+	}
+
+	// Opening a given index still reports the failure as an error.
+	buf.Reset()
+	zc.OpenDevice(ZCAN_USBCANFD_200U, 5, 0)
+	if !strings.Contains(buf.String(), `"level":"ERROR"`) {
+		t.Fatalf("Expected an error record, got %q", buf.String())
+	}
+}
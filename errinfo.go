@@ -0,0 +1,219 @@
+package zlgcan
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ErrorCode is the ErrorCode bitfield of ZCAN_CHANNEL_ERR_INFO.
+type ErrorCode uint32
+
+const (
+	ZCAN_ERROR_CAN_OVERFLOW        ErrorCode = 0x0001 // controller FIFO overflow
+	ZCAN_ERROR_CAN_ERRALARM        ErrorCode = 0x0002 // error warning limit reached
+	ZCAN_ERROR_CAN_PASSIVE         ErrorCode = 0x0004 // error passive
+	ZCAN_ERROR_CAN_LOSE            ErrorCode = 0x0008 // arbitration lost
+	ZCAN_ERROR_CAN_BUSERR          ErrorCode = 0x0010 // bus error
+	ZCAN_ERROR_CAN_BUSOFF          ErrorCode = 0x0020 // bus off
+	ZCAN_ERROR_CAN_BUFFER_OVERFLOW ErrorCode = 0x0040 // driver receive buffer overflow
+
+	ZCAN_ERROR_DEVICEOPENED    ErrorCode = 0x0100
+	ZCAN_ERROR_DEVICEOPEN      ErrorCode = 0x0200
+	ZCAN_ERROR_DEVICENOTOPEN   ErrorCode = 0x0400
+	ZCAN_ERROR_BUFFEROVERFLOW  ErrorCode = 0x0800
+	ZCAN_ERROR_DEVICENOTEXIST  ErrorCode = 0x1000
+	ZCAN_ERROR_LOADKERNELDLL   ErrorCode = 0x2000
+	ZCAN_ERROR_CMDFAILED       ErrorCode = 0x4000
+	ZCAN_ERROR_BUFFERCREATE    ErrorCode = 0x8000
+	ZCAN_ERROR_CANETE_PORTOPEN ErrorCode = 0x00010000
+	ZCAN_ERROR_CANETE_INDEXUSE ErrorCode = 0x00020000
+)
+
+var errorCodeNames = []struct {
+	flag ErrorCode
+	name string
+}{
+	{ZCAN_ERROR_CAN_OVERFLOW, "fifo overflow"},
+	{ZCAN_ERROR_CAN_ERRALARM, "error warning"},
+	{ZCAN_ERROR_CAN_PASSIVE, "error passive"},
+	{ZCAN_ERROR_CAN_LOSE, "arbitration lost"},
+	{ZCAN_ERROR_CAN_BUSERR, "bus error"},
+	{ZCAN_ERROR_CAN_BUSOFF, "bus off"},
+	{ZCAN_ERROR_CAN_BUFFER_OVERFLOW, "buffer overflow"},
+	{ZCAN_ERROR_DEVICEOPENED, "device already open"},
+	{ZCAN_ERROR_DEVICEOPEN, "device open failed"},
+	{ZCAN_ERROR_DEVICENOTOPEN, "device not open"},
+	{ZCAN_ERROR_BUFFEROVERFLOW, "device buffer overflow"},
+	{ZCAN_ERROR_DEVICENOTEXIST, "device does not exist"},
+	{ZCAN_ERROR_LOADKERNELDLL, "load kernel dll failed"},
+	{ZCAN_ERROR_CMDFAILED, "command failed"},
+	{ZCAN_ERROR_BUFFERCREATE, "out of memory"},
+	{ZCAN_ERROR_CANETE_PORTOPEN, "port open failed"},
+	{ZCAN_ERROR_CANETE_INDEXUSE, "device index in use"},
+}
+
+func (c ErrorCode) Has(flag ErrorCode) bool {
+	return c&flag == flag
+}
+
+func (c ErrorCode) String() string {
+	if c == 0 {
+		return "no error"
+	}
+	var names []string
+	rest := c
+	for _, n := range errorCodeNames {
+		if c.Has(n.flag) {
+			names = append(names, n.name)
+			rest &^= n.flag
+		}
+	}
+	if rest != 0 {
+		names = append(names, fmt.Sprintf("0x%x", uint32(rest)))
+	}
+	return strings.Join(names, ", ")
+}
+
+// BusErrorType is the error code field (bits 7-6) of the SJA1000 error code capture register.
+type BusErrorType uint8
+
+const (
+	BusErrorBit BusErrorType = iota
+	BusErrorForm
+	BusErrorStuff
+	BusErrorOther
+)
+
+func (t BusErrorType) String() string {
+	switch t {
+	case BusErrorBit:
+		return "bit error"
+	case BusErrorForm:
+		return "form error"
+	case BusErrorStuff:
+		return "stuff error"
+	}
+	return "other error"
+}
+
+// BusErrorSegment is the frame segment (bits 4-0) in which a bus error occurred.
+type BusErrorSegment uint8
+
+var busErrorSegmentNames = map[BusErrorSegment]string{
+	0x03: "start of frame",
+	0x02: "ID.28-ID.21",
+	0x06: "ID.20-ID.18",
+	0x04: "SRTR bit",
+	0x05: "IDE bit",
+	0x07: "ID.17-ID.13",
+	0x0F: "ID.12-ID.5",
+	0x0E: "ID.4-ID.0",
+	0x0C: "RTR bit",
+	0x0D: "reserved bit 1",
+	0x09: "reserved bit 0",
+	0x0B: "data length code",
+	0x0A: "data field",
+	0x08: "CRC sequence",
+	0x18: "CRC delimiter",
+	0x19: "acknowledge slot",
+	0x1B: "acknowledge delimiter",
+	0x1A: "end of frame",
+	0x12: "intermission",
+	0x11: "active error flag",
+	0x16: "passive error flag",
+	0x13: "tolerate dominant bits",
+	0x17: "error delimiter",
+	0x1C: "overload flag",
+}
+
+func (s BusErrorSegment) String() string {
+	if name, ok := busErrorSegmentNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("segment 0x%02x", uint8(s))
+}
+
+// BusErrorCapture is a decoded SJA1000 error code capture (ECC) register.
+type BusErrorCapture struct {
+	Type    BusErrorType
+	Rx      bool // the error occurred while receiving, otherwise while transmitting
+	Segment BusErrorSegment
+}
+
+// DecodeErrorCapture decodes the value of an error code capture register.
+func DecodeErrorCapture(ecc uint8) BusErrorCapture {
+	return BusErrorCapture{
+		Type:    BusErrorType(ecc >> 6),
+		Rx:      ecc&0x20 != 0,
+		Segment: BusErrorSegment(ecc & 0x1F),
+	}
+}
+
+func (c BusErrorCapture) String() string {
+	direction := "tx"
+	if c.Rx {
+		direction = "rx"
+	}
+	return fmt.Sprintf("%s during %s in %s", c.Type, direction, c.Segment)
+}
+
+// ArbitrationLostBit is the bit position (bits 4-0) of the SJA1000 arbitration lost capture register.
+type ArbitrationLostBit uint8
+
+// DecodeArbitrationLost decodes the value of an arbitration lost capture register.
+func DecodeArbitrationLost(alc uint8) ArbitrationLostBit {
+	return ArbitrationLostBit(alc & 0x1F)
+}
+
+func (b ArbitrationLostBit) String() string {
+	switch {
+	case b <= 10:
+		return fmt.Sprintf("ID.%d", 28-int(b))
+	case b == 11:
+		return "SRTR bit"
+	case b == 12:
+		return "IDE bit"
+	case b <= 30:
+		return fmt.Sprintf("ID.%d", 30-int(b))
+	}
+	return "RTR bit"
+}
+
+// ChannelErrInfo is the decoded form of ZCAN_CHANNEL_ERR_INFO.
+type ChannelErrInfo struct {
+	Code ErrorCode
+	// Capture, RxErrors and TxErrors are taken from PassiveErrData and are only
+	// meaningful with ZCAN_ERROR_CAN_PASSIVE or ZCAN_ERROR_CAN_BUSERR set.
+	Capture  BusErrorCapture
+	RxErrors uint8
+	TxErrors uint8
+	// ArbitrationLost is only meaningful with ZCAN_ERROR_CAN_LOSE set.
+	ArbitrationLost ArbitrationLostBit
+}
+
+// Decode splits the raw error information into its fields.
+func (e *ZCAN_CHANNEL_ERR_INFO) Decode() ChannelErrInfo {
+	return ChannelErrInfo{
+		Code:            ErrorCode(e.ErrorCode),
+		Capture:         DecodeErrorCapture(e.PassiveErrData[0]),
+		RxErrors:        e.PassiveErrData[1],
+		TxErrors:        e.PassiveErrData[2],
+		ArbitrationLost: DecodeArbitrationLost(e.ArLostErrData),
+	}
+}
+
+func (e *ZCAN_CHANNEL_ERR_INFO) String() string {
+	return e.Decode().String()
+}
+
+func (i ChannelErrInfo) String() string {
+	var b strings.Builder
+	b.WriteString(i.Code.String())
+	if i.Code.Has(ZCAN_ERROR_CAN_PASSIVE) || i.Code.Has(ZCAN_ERROR_CAN_BUSERR) {
+		fmt.Fprintf(&b, "; %s; rx errors %d, tx errors %d", i.Capture, i.RxErrors, i.TxErrors)
+	}
+	if i.Code.Has(ZCAN_ERROR_CAN_LOSE) {
+		fmt.Fprintf(&b, "; arbitration lost at %s", i.ArbitrationLost)
+	}
+	return b.String()
+}
@@ -0,0 +1,47 @@
+package zlgcan
+
+import "testing"
+
+// Test for ZCAN_CHANNEL_ERR_INFO decoding
+func TestDecodeChannelErrInfo(t *testing.T) {
+	raw := ZCAN_CHANNEL_ERR_INFO{
+		ErrorCode:      uint32(ZCAN_ERROR_CAN_PASSIVE | ZCAN_ERROR_CAN_BUSERR | ZCAN_ERROR_CAN_LOSE),
+		PassiveErrData: [3]uint8{0x99, 3, 130}, // stuff error while transmitting, acknowledge slot
+		ArLostErrData:  13,
+	}
+	info := raw.Decode()
+	if info.Capture.Type != BusErrorStuff || info.Capture.Rx || info.Capture.Segment != 0x19 {
+		t.Fatalf("Unexpected capture: %+v", info.Capture)
+	}
+	if info.RxErrors != 3 || info.TxErrors != 130 {
+		t.Fatalf("Unexpected counters: rx %d tx %d", info.RxErrors, info.TxErrors)
+	}
+	want := "error passive, arbitration lost, bus error; stuff error during tx in acknowledge slot; rx errors 3, tx errors 130; arbitration lost at ID.17"
+	if got := raw.String(); got != want {
+		t.Fatalf("Expected %q, got %q", want, got)
+	}
+}
+
+// Test for ErrorCode names
+func TestErrorCodeString(t *testing.T) {
+	cases := map[ErrorCode]string{
+		0:                                   "no error",
+		ZCAN_ERROR_CAN_BUSOFF:               "bus off",
+		ZCAN_ERROR_CAN_OVERFLOW | 0x8000000: "fifo overflow, 0x8000000",
+	}
+	for code, want := range cases {
+		if got := code.String(); got != want {
+			t.Fatalf("Expected %q for 0x%x, got %q", want, uint32(code), got)
+		}
+	}
+}
+
+// Test for arbitration lost bit positions
+func TestArbitrationLostBit(t *testing.T) {
+	cases := map[uint8]string{0: "ID.28", 10: "ID.18", 11: "SRTR bit", 12: "IDE bit", 30: "ID.0", 31: "RTR bit", 0xE0: "ID.28"}
+	for alc, want := range cases {
+		if got := DecodeArbitrationLost(alc).String(); got != want {
+			t.Fatalf("Expected %q for %d, got %q", want, alc, got)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package zlgcan
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ErrorClass is the error class carried in the ID of a frame with the ERR bit set.
+// Error frames follow the SocketCAN layout: the class in the ID, details in the data bytes.
+type ErrorClass uint32
+
+const (
+	ErrorClassTxTimeout  ErrorClass = 0x001
+	ErrorClassLostArb    ErrorClass = 0x002 // data[0] holds the bit position
+	ErrorClassController ErrorClass = 0x004 // data[1] holds ErrorController flags
+	ErrorClassProtocol   ErrorClass = 0x008 // data[2] holds ErrorProtocol flags, data[3] the location
+	ErrorClassTrx        ErrorClass = 0x010
+	ErrorClassAck        ErrorClass = 0x020
+	ErrorClassBusOff     ErrorClass = 0x040
+	ErrorClassBusError   ErrorClass = 0x080
+	ErrorClassRestarted  ErrorClass = 0x100
+	ErrorClassCounters   ErrorClass = 0x200 // data[6] and data[7] hold the TX and RX error counters
+)
+
+var errorClassNames = []struct {
+	flag ErrorClass
+	name string
+}{
+	{ErrorClassTxTimeout, "tx timeout"},
+	{ErrorClassLostArb, "arbitration lost"},
+	{ErrorClassController, "controller"},
+	{ErrorClassProtocol, "protocol"},
+	{ErrorClassTrx, "transceiver"},
+	{ErrorClassAck, "no ack"},
+	{ErrorClassBusOff, "bus off"},
+	{ErrorClassBusError, "bus error"},
+	{ErrorClassRestarted, "restarted"},
+	{ErrorClassCounters, "counters"},
+}
+
+func (c ErrorClass) String() string {
+	var names []string
+	for _, n := range errorClassNames {
+		if c&n.flag != 0 {
+			names = append(names, n.name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// ErrorController is the controller state byte (data[1]) of an error frame.
+type ErrorController uint8
+
+const (
+	ErrorControllerRxOverflow ErrorController = 0x01
+	ErrorControllerTxOverflow ErrorController = 0x02
+	ErrorControllerRxWarning  ErrorController = 0x04
+	ErrorControllerTxWarning  ErrorController = 0x08
+	ErrorControllerRxPassive  ErrorController = 0x10
+	ErrorControllerTxPassive  ErrorController = 0x20
+	ErrorControllerActive     ErrorController = 0x40 // back to error active
+)
+
+// ErrorProtocol is the protocol violation byte (data[2]) of an error frame.
+type ErrorProtocol uint8
+
+const (
+	ErrorProtocolBit      ErrorProtocol = 0x01
+	ErrorProtocolForm     ErrorProtocol = 0x02
+	ErrorProtocolStuff    ErrorProtocol = 0x04
+	ErrorProtocolBit0     ErrorProtocol = 0x08 // unable to send a dominant bit
+	ErrorProtocolBit1     ErrorProtocol = 0x10 // unable to send a recessive bit
+	ErrorProtocolOverload ErrorProtocol = 0x20
+	ErrorProtocolActive   ErrorProtocol = 0x40 // active error announcement
+	ErrorProtocolTx       ErrorProtocol = 0x80 // the error occurred on transmission
+)
+
+// ErrorKind is the main classification of an error frame.
+type ErrorKind uint8
+
+const (
+	ErrorKindOther ErrorKind = iota
+	ErrorKindBit
+	ErrorKindStuff
+	ErrorKindForm
+	ErrorKindCRC
+	ErrorKindAck
+	ErrorKindArbitrationLost
+	ErrorKindOverflow
+	ErrorKindStateChange
+	ErrorKindBusOff
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorKindBit:
+		return "bit error"
+	case ErrorKindStuff:
+		return "stuff error"
+	case ErrorKindForm:
+		return "form error"
+	case ErrorKindCRC:
+		return "CRC error"
+	case ErrorKindAck:
+		return "ACK error"
+	case ErrorKindArbitrationLost:
+		return "arbitration lost"
+	case ErrorKindOverflow:
+		return "overflow"
+	case ErrorKindStateChange:
+		return "state change"
+	case ErrorKindBusOff:
+		return "bus off"
+	}
+	return "other error"
+}
+
+// ErrorFrame is a decoded frame with the ERR bit set.
+type ErrorFrame struct {
+	Timestamp       uint64
+	Class           ErrorClass
+	ArbitrationLost ArbitrationLostBit
+	Controller      ErrorController
+	Protocol        ErrorProtocol
+	Location        BusErrorSegment
+	TxErrors        uint8
+	RxErrors        uint8
+}
+
+func decodeErrorFrame(id uint32, data []uint8) ErrorFrame {
+	var d [8]uint8
+	copy(d[:], data)
+	return ErrorFrame{
+		Class:           ErrorClass(id),
+		ArbitrationLost: ArbitrationLostBit(d[0]),
+		Controller:      ErrorController(d[1]),
+		Protocol:        ErrorProtocol(d[2]),
+		Location:        BusErrorSegment(d[3]),
+		TxErrors:        d[6],
+		RxErrors:        d[7],
+	}
+}
+
+// DecodeErrorFrame decodes f, the second result is false if f is not an error frame.
+func DecodeErrorFrame(f *ZCAN_CAN_FRAME) (ErrorFrame, bool) {
+	if f.GetFrameERR() == 0 {
+		return ErrorFrame{}, false
+	}
+	return decodeErrorFrame(f.GetFrameID(), f.Data[:min(int(f.Dlc), len(f.Data))]), true
+}
+
+// DecodeErrorFrameFD decodes f, the second result is false if f is not an error frame.
+func DecodeErrorFrameFD(f *ZCAN_CANFD_FRAME) (ErrorFrame, bool) {
+	if f.GetFrameERR() == 0 {
+		return ErrorFrame{}, false
+	}
+	return decodeErrorFrame(f.GetFrameID(), f.Data[:min(int(f.Len), len(f.Data))]), true
+}
+
+// Kind returns the most severe classification of the frame.
+func (e ErrorFrame) Kind() ErrorKind {
+	switch {
+	case e.Class&ErrorClassBusOff != 0:
+		return ErrorKindBusOff
+	case e.Class&ErrorClassController != 0 && e.Controller&(ErrorControllerRxOverflow|ErrorControllerTxOverflow) != 0:
+		return ErrorKindOverflow
+	case e.Class&ErrorClassController != 0:
+		return ErrorKindStateChange
+	case e.Class&ErrorClassAck != 0 || e.Location == 0x19 || e.Location == 0x1B:
+		return ErrorKindAck
+	case e.Class&ErrorClassProtocol != 0:
+		switch {
+		case e.Location == 0x08 || e.Location == 0x18:
+			return ErrorKindCRC
+		case e.Protocol&(ErrorProtocolBit|ErrorProtocolBit0|ErrorProtocolBit1) != 0:
+			return ErrorKindBit
+		case e.Protocol&ErrorProtocolStuff != 0:
+			return ErrorKindStuff
+		case e.Protocol&ErrorProtocolForm != 0:
+			return ErrorKindForm
+		}
+	case e.Class&ErrorClassLostArb != 0:
+		return ErrorKindArbitrationLost
+	}
+	return ErrorKindOther
+}
+
+// State returns the controller state reported by the frame. Frames without bus-off or
+// controller class do not change the state and report ControllerErrorActive.
+func (e ErrorFrame) State() ControllerState {
+	switch {
+	case e.Class&ErrorClassBusOff != 0:
+		return ControllerBusOff
+	case e.Class&ErrorClassController == 0:
+		return ControllerErrorActive
+	case e.Controller&(ErrorControllerRxPassive|ErrorControllerTxPassive) != 0:
+		return ControllerErrorPassive
+	case e.Controller&(ErrorControllerRxWarning|ErrorControllerTxWarning) != 0:
+		return ControllerErrorWarning
+	}
+	return ControllerErrorActive
+}
+
+func (e ErrorFrame) String() string {
+	str := e.Kind().String()
+	switch e.Kind() {
+	case ErrorKindStateChange:
+		str += " to " + e.State().String()
+	case ErrorKindArbitrationLost:
+		str += " at " + e.ArbitrationLost.String()
+	case ErrorKindBit, ErrorKindStuff, ErrorKindForm, ErrorKindCRC, ErrorKindAck:
+		if e.Location != 0 {
+			str += " in " + e.Location.String()
+		}
+		if e.Protocol&ErrorProtocolTx != 0 {
+			str += " during tx"
+		}
+	}
+	return fmt.Sprintf("%s (%s), tx errors %d, rx errors %d", str, e.Class, e.TxErrors, e.RxErrors)
+}
+
+// sendErrorFrame hands e to the error stream without blocking the receive path.
+func (ch *Channel) sendErrorFrame(e ErrorFrame) {
+	select {
+	case ch.opts.errorFrames <- e:
+	default:
+		ch.errorFramesDropped.Add(1)
+	}
+}
+
+// ErrorFramesDropped returns the number of error frames lost because the error stream
+// of WithErrorFrames was full.
+func (ch *Channel) ErrorFramesDropped() uint64 {
+	return ch.errorFramesDropped.Load()
+}
+
+// Receive reads frames from the channel like ZCAN.Receive. With WithErrorFrames, error
+// frames are sent to the error stream and left out of the result.
+func (ch *Channel) Receive(rcvNum uint, waitTime int) ([]ZCAN_Receive_Data, uint) {
+	rcv, n := ch.zc.Receive(ch.Handle(), rcvNum, waitTime)
+	if ch.opts.errorFrames == nil {
+		return rcv, n
+	}
+	kept := rcv[:0]
+	for _, r := range rcv[:min(int(n), len(rcv))] {
+		if e, ok := DecodeErrorFrame(&r.Frame); ok {
+			e.Timestamp = r.Timestamp
+			ch.sendErrorFrame(e)
+			continue
+		}
+		kept = append(kept, r)
+	}
+	return kept, uint(len(kept))
+}
+
+// ReceiveFD is Receive for CANFD frames.
+func (ch *Channel) ReceiveFD(rcvNum uint, waitTime int) ([]ZCAN_ReceiveFD_Data, uint) {
+	rcv, n := ch.zc.ReceiveFD(ch.Handle(), rcvNum, waitTime)
+	if ch.opts.errorFrames == nil {
+		return rcv, n
+	}
+	kept := rcv[:0]
+	for _, r := range rcv[:min(int(n), len(rcv))] {
+		if e, ok := DecodeErrorFrameFD(&r.Frame); ok {
+			e.Timestamp = r.Timestamp
+			ch.sendErrorFrame(e)
+			continue
+		}
+		kept = append(kept, r)
+	}
+	return kept, uint(len(kept))
+}
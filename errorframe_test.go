@@ -0,0 +1,102 @@
+package zlgcan
+
+import "testing"
+
+func newErrorFrame(class ErrorClass, data ...uint8) ZCAN_CAN_FRAME {
+	var f ZCAN_CAN_FRAME
+	f.GenerateID(uint32(class), 1, 0, 0)
+	f.Dlc = 8
+	copy(f.Data[:], data)
+	return f
+}
+
+// Test for error frame classification
+func TestDecodeErrorFrame(t *testing.T) {
+	cases := []struct {
+		frame ZCAN_CAN_FRAME
+		kind  ErrorKind
+		state ControllerState
+	}{
+		{newErrorFrame(ErrorClassBusOff), ErrorKindBusOff, ControllerBusOff},
+		{newErrorFrame(ErrorClassController|ErrorClassCounters, 0, uint8(ErrorControllerTxPassive), 0, 0, 0, 0, 128, 5), ErrorKindStateChange, ControllerErrorPassive},
+		{newErrorFrame(ErrorClassController, 0, uint8(ErrorControllerRxWarning)), ErrorKindStateChange, ControllerErrorWarning},
+		{newErrorFrame(ErrorClassController, 0, uint8(ErrorControllerRxOverflow)), ErrorKindOverflow, ControllerErrorActive},
+		{newErrorFrame(ErrorClassAck|ErrorClassBusError, 0, 0, uint8(ErrorProtocolTx), 0x19), ErrorKindAck, ControllerErrorActive},
+		{newErrorFrame(ErrorClassProtocol|ErrorClassBusError, 0, 0, uint8(ErrorProtocolForm), 0x08), ErrorKindCRC, ControllerErrorActive},
+		{newErrorFrame(ErrorClassProtocol|ErrorClassBusError, 0, 0, uint8(ErrorProtocolBit1|ErrorProtocolTx), 0x0A), ErrorKindBit, ControllerErrorActive},
+		{newErrorFrame(ErrorClassProtocol|ErrorClassBusError, 0, 0, uint8(ErrorProtocolStuff), 0x0A), ErrorKindStuff, ControllerErrorActive},
+		{newErrorFrame(ErrorClassProtocol|ErrorClassBusError, 0, 0, uint8(ErrorProtocolForm), 0x1A), ErrorKindForm, ControllerErrorActive},
+		{newErrorFrame(ErrorClassLostArb, 3), ErrorKindArbitrationLost, ControllerErrorActive},
+		{newErrorFrame(ErrorClassTrx), ErrorKindOther, ControllerErrorActive},
+	}
+	for i, c := range cases {
+		e, ok := DecodeErrorFrame(&c.frame)
+		if !ok {
+			t.Fatalf("Case %d: not decoded as error frame", i)
+		}
+		if e.Kind() != c.kind || e.State() != c.state {
+			t.Fatalf("Case %d: expected %s/%s, got %s/%s", i, c.kind, c.state, e.Kind(), e.State())
+		}
+	}
+
+	e, _ := DecodeErrorFrame(&cases[1].frame)
+	if e.TxErrors != 128 || e.RxErrors != 5 {
+		t.Fatalf("Unexpected counters: tx %d rx %d", e.TxErrors, e.RxErrors)
+	}
+	want := "state change to error passive (controller, counters), tx errors 128, rx errors 5"
+	if e.String() != want {
+		t.Fatalf("Expected %q, got %q", want, e.String())
+	}
+}
+
+// Test that data frames and short error frames are handled
+func TestDecodeErrorFrameData(t *testing.T) {
+	var data ZCAN_CAN_FRAME
+	data.GenerateID(0x123, 0, 0, 0)
+	if _, ok := DecodeErrorFrame(&data); ok {
+		t.Fatalf("Data frame decoded as error frame")
+	}
+
+	var fd ZCAN_CANFD_FRAME
+	fd.GenerateID(uint32(ErrorClassProtocol), 1, 0, 0)
+	fd.Len = 3
+	fd.Data = [64]uint8{0, 0, uint8(ErrorProtocolStuff), 0x0A, 0, 0, 9, 9}
+	e, ok := DecodeErrorFrameFD(&fd)
+	if !ok || e.Kind() != ErrorKindStuff || e.Location != 0 || e.TxErrors != 0 {
+		t.Fatalf("Unexpected decoding of a 3 byte error frame: %+v", e)
+	}
+}
+
+// Test that a full error stream drops error frames instead of blocking Receive
+func TestErrorFramesDropped(t *testing.T) {
+	sim := NewSimulator()
+	zc, handle := newSimulatedDevice(t, sim, ZCAN_USBCANFD_200U, 0)
+	tx, err := zc.OpenChannel(handle, 0)
+	if err != nil {
+		t.Fatalf("OpenChannel failed: %v", err)
+	}
+	errs := make(chan ErrorFrame, 1)
+	rx, err := zc.OpenChannel(handle, 1, WithErrorFrames(errs))
+	if err != nil {
+		t.Fatalf("OpenChannel failed: %v", err)
+	}
+
+	msgs := make([]ZCAN_Transmit_Data, 4)
+	for i := range msgs[:3] {
+		msgs[i].Frame = newErrorFrame(ErrorClassBusOff)
+	}
+	msgs[3].Frame.GenerateID(0x100, 0, 0, 0)
+	if sent := zc.Transmit(tx.Handle(), msgs, 4); sent != 4 {
+		t.Fatalf("Expected 4 frames sent, got %d", sent)
+	}
+	rcv, n := rx.Receive(10, 100)
+	if n != 1 || rcv[0].Frame.GetFrameID() != 0x100 {
+		t.Fatalf("Expected the data frame only, got %d frames", n)
+	}
+	if len(errs) != 1 || rx.ErrorFramesDropped() != 2 {
+		t.Fatalf("Expected 1 error frame queued and 2 dropped, got %d and %d", len(errs), rx.ErrorFramesDropped())
+	}
+	if e := <-errs; e.Class != ErrorClassBusOff {
+		t.Fatalf("Unexpected error frame %+v", e)
+	}
+}
@@ -0,0 +1,159 @@
+package zlgcan
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+var ErrEmptyGroup = errors.New("channel group is empty")
+
+// GroupChannel names a channel opened by StartGroup.
+type GroupChannel struct {
+	Device  int // device handle
+	Index   uint
+	Options []ChannelOption
+}
+
+// ChannelGroup is a set of channels, possibly on several devices, started together by
+// StartGroup. Its timeline begins right before the first ZCAN_StartCAN; Offset tells when
+// each channel started on it and Align maps hardware timestamps onto it.
+type ChannelGroup struct {
+	channels []*Channel
+	start    time.Time
+	offsets  []time.Duration
+
+	mu       sync.Mutex
+	origins  []time.Duration // where each channel's timestamp 0 lies on the timeline
+	observed []bool
+}
+
+// StartGroup opens the channels in members so that they start receiving as close together
+// as possible. Every channel is set up and initialized first, as by OpenChannel; then all
+// are started by calling ZCAN_StartCAN back to back on one locked OS thread. If any channel
+// fails, the channels already initialized or started are reset again.
+func (zc *ZCAN) StartGroup(members ...GroupChannel) (*ChannelGroup, error) {
+	if len(members) == 0 {
+		return nil, ErrEmptyGroup
+	}
+	type key struct {
+		device int
+		index  uint
+	}
+	seen := make(map[key]bool, len(members))
+	for _, m := range members {
+		if seen[key{m.Device, m.Index}] {
+			return nil, fmt.Errorf("channel %d of device %d is in the group twice", m.Index, m.Device)
+		}
+		seen[key{m.Device, m.Index}] = true
+	}
+
+	g := &ChannelGroup{channels: make([]*Channel, len(members))}
+	handles := make([]int, 0, len(members))
+	reset := func() {
+		for _, h := range handles {
+			zc.ResetCAN(h)
+		}
+	}
+	for i, m := range members {
+		ch, err := zc.newChannel(m.Device, m.Index, m.Options)
+		if err != nil {
+			reset()
+			return nil, err
+		}
+		handle, err := ch.init()
+		if err != nil {
+			ch.logStartError("StartGroup", err)
+			reset()
+			return nil, err
+		}
+		g.channels[i] = ch
+		handles = append(handles, handle)
+	}
+
+	// Nothing but the calls and the clock reads between the first and the last start.
+	rets := make([]uint, len(handles))
+	ends := make([]time.Time, len(handles))
+	runtime.LockOSThread()
+	g.start = time.Now()
+	for i, h := range handles {
+		rets[i] = zc.StartCAN(h)
+		ends[i] = time.Now()
+	}
+	runtime.UnlockOSThread()
+
+	for i, ret := range rets {
+		if ret != ZCAN_STATUS_OK {
+			err := fmt.Errorf("error calling ZCAN_StartCAN on channel %d: %d", g.channels[i].index, ret)
+			g.channels[i].logStartError("StartGroup", err)
+			reset()
+			return nil, err
+		}
+	}
+	// A channel started somewhere during its call, take the middle.
+	g.offsets = make([]time.Duration, len(handles))
+	prev := g.start
+	for i, end := range ends {
+		g.offsets[i] = prev.Sub(g.start) + end.Sub(prev)/2
+		prev = end
+		g.channels[i].logStarted(handles[i])
+	}
+	g.origins = append([]time.Duration(nil), g.offsets...)
+	g.observed = make([]bool, len(handles))
+	return g, nil
+}
+
+// Channels returns the channels in the order of the members passed to StartGroup.
+func (g *ChannelGroup) Channels() []*Channel {
+	return append([]*Channel(nil), g.channels...)
+}
+
+// Start returns the beginning of the group timeline, taken right before the first
+// ZCAN_StartCAN.
+func (g *ChannelGroup) Start() time.Time {
+	return g.start
+}
+
+// Offset returns when channel i started, relative to Start.
+func (g *ChannelGroup) Offset(i int) time.Duration {
+	return g.offsets[i]
+}
+
+// Skew returns the time between the first and the last channel start.
+func (g *ChannelGroup) Skew() time.Duration {
+	return g.offsets[len(g.offsets)-1] - g.offsets[0]
+}
+
+// Observe refines where the hardware clock of channel i started from a frame with timestamp
+// (µs) that was read from the channel at host time at. Frames are read some time after they
+// arrive, so the reading with the least latency wins; the more frames are observed, the
+// closer Align gets. Before the first observation the clock is assumed to count from the
+// channel start.
+func (g *ChannelGroup) Observe(i int, timestamp uint64, at time.Time) {
+	origin := at.Sub(g.start) - time.Duration(timestamp)*time.Microsecond
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.observed[i] || origin < g.origins[i] {
+		g.origins[i] = origin
+		g.observed[i] = true
+	}
+}
+
+// Align maps hardware timestamp (µs) of channel i onto the group timeline, as the time
+// since Start.
+func (g *ChannelGroup) Align(i int, timestamp uint64) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.origins[i] + time.Duration(timestamp)*time.Microsecond
+}
+
+// Close resets all channels of the group.
+func (g *ChannelGroup) Close() error {
+	var errs []error
+	for _, ch := range g.channels {
+		errs = append(errs, ch.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,163 @@
+package zlgcan
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newGroupDevices opens two simulated USBCANFD-200U on one ZCAN, four channels in total.
+func newGroupDevices(t *testing.T) (*ZCAN, []GroupChannel) {
+	t.Helper()
+	sim := NewSimulator()
+	zc, dev0 := newSimulatedDevice(t, sim, ZCAN_USBCANFD_200U, 0)
+	if err := sim.AddDevice(ZCAN_USBCANFD_200U, 1, "SIM0001"); err != nil {
+		t.Fatalf("AddDevice failed: %v", err)
+	}
+	dev1 := zc.OpenDevice(ZCAN_USBCANFD_200U, 1, 0)
+	if dev1 == INVALID_DEVICE_HANDLE {
+		t.Fatalf("Open Device failed")
+	}
+	return zc, []GroupChannel{{Device: dev0, Index: 0}, {Device: dev0, Index: 1}, {Device: dev1, Index: 0}, {Device: dev1, Index: 1}}
+}
+
+// Test starting channels on several devices as a group
+func TestStartGroup(t *testing.T) {
+	zc, members := newGroupDevices(t)
+	if _, err := zc.StartGroup(); !errors.Is(err, ErrEmptyGroup) {
+		t.Fatalf("Expected ErrEmptyGroup, got %v", err)
+	}
+	if _, err := zc.StartGroup(members[0], members[1], members[0]); err == nil {
+		t.Fatalf("Expected a channel listed twice to fail")
+	}
+
+	g, err := zc.StartGroup(members...)
+	if err != nil {
+		t.Fatalf("StartGroup failed: %v", err)
+	}
+	chans := g.Channels()
+	if len(chans) != 4 || chans[2].Device() != members[2].Device || chans[3].Index() != 1 {
+		t.Fatalf("Unexpected channels %v", chans)
+	}
+	for i := range chans {
+		if g.Offset(i) < 0 || (i > 0 && g.Offset(i) < g.Offset(i-1)) {
+			t.Fatalf("Offsets out of order: %v at %d", g.Offset(i), i)
+		}
+	}
+	if g.Skew() != g.Offset(3)-g.Offset(0) || g.Skew() > 100*time.Millisecond {
+		t.Fatalf("Unexpected skew %v", g.Skew())
+	}
+
+	msgs := []ZCAN_TransmitFD_Data{newFDTransmit(0x100, ZCAN_TX_NORMAL)}
+	if sent := zc.TransmitFD(chans[0].Handle(), msgs, 1); sent != 1 {
+		t.Fatalf("Expected the frame sent, got %d", sent)
+	}
+	for _, ch := range chans[1:] {
+		if num := zc.GetReceiveNum(ch.Handle(), ZCAN_TYPE_CANFD); num != 1 {
+			t.Fatalf("Expected the frame on channel %d of device %d, got %d", ch.Index(), ch.Device(), num)
+		}
+	}
+
+	if err := g.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if sent := zc.TransmitFD(chans[0].Handle(), msgs, 1); sent != 0 {
+		t.Fatalf("Expected the closed channel not to send, got %d", sent)
+	}
+}
+
+// Test that a failing member resets the channels set up before it
+func TestStartGroupFailure(t *testing.T) {
+	sim := NewSimulator()
+	if err := sim.AddDevice(ZCAN_USBCANFD_200U, 0, "SIM0000"); err != nil {
+		t.Fatalf("AddDevice failed: %v", err)
+	}
+	var trace bytes.Buffer
+	zc := NewSimulatedZCAN(sim, WithTrace(&trace))
+	dev := zc.OpenDevice(ZCAN_USBCANFD_200U, 0, 0)
+	if dev == INVALID_DEVICE_HANDLE {
+		t.Fatalf("Open Device failed")
+	}
+	if _, err := zc.StartGroup(GroupChannel{Device: dev, Index: 0}, GroupChannel{Device: dev, Index: 1}, GroupChannel{Device: dev, Index: 5}); err == nil {
+		t.Fatalf("Expected channel 5 to fail")
+	}
+	if _, err := zc.StartGroup(GroupChannel{Device: 12345}); !errors.Is(err, ErrUnknownDevice) {
+		t.Fatalf("Expected ErrUnknownDevice, got %v", err)
+	}
+	calls, err := ReadTrace(&trace)
+	if err != nil {
+		t.Fatalf("ReadTrace failed: %v", err)
+	}
+	count := map[string]int{}
+	for _, call := range calls {
+		count[call.Function]++
+	}
+	if count["ZCAN_InitCANFD"] != 2 || count["ZCAN_ResetCAN"] != 2 || count["ZCAN_StartCAN"] != 0 {
+		t.Fatalf("Expected both channels initialized and reset without starting, got %v", count)
+	}
+}
+
+// Test mapping hardware timestamps onto the group timeline
+func TestGroupAlign(t *testing.T) {
+	zc, members := newGroupDevices(t)
+	g, err := zc.StartGroup(members...)
+	if err != nil {
+		t.Fatalf("StartGroup failed: %v", err)
+	}
+	defer g.Close()
+
+	// Until a frame is observed the clock counts from the channel start.
+	if got := g.Align(1, 1000); got != g.Offset(1)+time.Millisecond {
+		t.Fatalf("Expected %v, got %v", g.Offset(1)+time.Millisecond, got)
+	}
+	g.Observe(0, 4000, g.Start().Add(10*time.Millisecond))
+	if got := g.Align(0, 5000); got != 11*time.Millisecond {
+		t.Fatalf("Expected 11ms, got %v", got)
+	}
+	// A later read says less about the origin than an earlier one.
+	g.Observe(0, 4000, g.Start().Add(12*time.Millisecond))
+	if got := g.Align(0, 5000); got != 11*time.Millisecond {
+		t.Fatalf("Expected 11ms after a slower read, got %v", got)
+	}
+	g.Observe(0, 4000, g.Start().Add(9*time.Millisecond))
+	if got := g.Align(0, 5000); got != 10*time.Millisecond {
+		t.Fatalf("Expected 10ms after a faster read, got %v", got)
+	}
+
+	// The simulated clock runs from before the group start; after observing one frame the
+	// channels agree on when the next one was on the bus.
+	chans := g.Channels()
+	send := func() time.Duration {
+		sent := time.Since(g.Start())
+		if n := zc.TransmitFD(chans[3].Handle(), []ZCAN_TransmitFD_Data{newFDTransmit(0x200, ZCAN_TX_NORMAL)}, 1); n != 1 {
+			t.Fatalf("Expected the frame sent, got %d", n)
+		}
+		return sent
+	}
+	send()
+	for i, ch := range chans[:3] {
+		rcv, n := ch.ReceiveFD(1, 100)
+		if n != 1 {
+			t.Fatalf("Expected a frame on channel %d, got %d", i, n)
+		}
+		g.Observe(i, rcv[0].Timestamp, time.Now())
+	}
+	sent := send()
+	var aligned []time.Duration
+	for i, ch := range chans[:3] {
+		rcv, n := ch.ReceiveFD(1, 100)
+		if n != 1 {
+			t.Fatalf("Expected a frame on channel %d, got %d", i, n)
+		}
+		aligned = append(aligned, g.Align(i, rcv[0].Timestamp))
+	}
+	for i, at := range aligned {
+		if d := at - aligned[0]; d < -5*time.Millisecond || d > 5*time.Millisecond {
+			t.Fatalf("Channel %d places the frame at %v, channel 0 at %v", i, at, aligned[0])
+		}
+		if d := at - sent; d < -5*time.Millisecond || d > 5*time.Millisecond {
+			t.Fatalf("Channel %d places the frame at %v, it was sent at %v", i, at, sent)
+		}
+	}
+}
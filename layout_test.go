@@ -0,0 +1,244 @@
+package zlgcan
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// stubLayout maps a Go struct to its definition in testdata/zlgstub/zlgcan.h. cType is empty
+// for structs only used as a field, like the variants of the init config union.
+type stubLayout struct {
+	goType  reflect.Type
+	cType   string
+	members map[string]string // Go field -> C member
+}
+
+var stubLayouts = []stubLayout{
+	{reflect.TypeOf(ZCAN_DEVICE_INFO{}), "ZCAN_DEVICE_INFO", map[string]string{
+		"hw_Version": "hw_Version", "fw_Version": "fw_Version", "dr_Version": "dr_Version",
+		"in_Version": "in_Version", "irq_Num": "irq_Num", "can_Num": "can_Num",
+		"str_Serial_Num": "str_Serial_Num", "str_hw_Type": "str_hw_Type", "reserved": "reserved",
+	}},
+	{reflect.TypeOf(_ZCAN_CHANNEL_CAN_INIT_CONFIG{}), "", map[string]string{
+		"AccCode": "acc_code", "AccMask": "acc_mask", "Reserved": "reserved", "Filter": "filter",
+		"Timing0": "timing0", "Timing1": "timing1", "Mode": "mode",
+	}},
+	{reflect.TypeOf(_ZCAN_CHANNEL_CANFD_INIT_CONFIG{}), "", map[string]string{
+		"AccCode": "acc_code", "AccMask": "acc_mask", "AbitTiming": "abit_timing",
+		"DbitTiming": "dbit_timing", "Brp": "brp", "Filter": "filter", "Mode": "mode",
+		"Pad": "pad", "Reserved": "reserved",
+	}},
+	{reflect.TypeOf(ZCAN_NORMAL_CHANNEL_INIT_CONFIG{}), "ZCAN_CHANNEL_INIT_CONFIG", map[string]string{
+		"CanType": "can_type", "Config": "can",
+	}},
+	{reflect.TypeOf(ZCAN_CANFD_CHANNEL_INIT_CONFIG{}), "ZCAN_CHANNEL_INIT_CONFIG", map[string]string{
+		"CanType": "can_type", "Config": "canfd",
+	}},
+	{reflect.TypeOf(ZCAN_CHANNEL_ERR_INFO{}), "ZCAN_CHANNEL_ERR_INFO", map[string]string{
+		"ErrorCode": "error_code", "PassiveErrData": "passive_ErrData", "ArLostErrData": "arLost_ErrData",
+	}},
+	{reflect.TypeOf(ZCAN_CHANNEL_STATUS{}), "ZCAN_CHANNEL_STATUS", map[string]string{
+		"ErrInterrupt": "errInterrupt", "RegMode": "regMode", "RegStatus": "regStatus",
+		"RegALCapture": "regALCapture", "RegECCapture": "regECCapture", "RegEWLimit": "regEWLimit",
+		"RegRECounter": "regRECounter", "RegTECounter": "regTECounter", "Reserved": "Reserved",
+	}},
+	{reflect.TypeOf(ZCAN_CAN_FRAME{}), "can_frame", map[string]string{
+		"Id": "can_id", "Dlc": "can_dlc", "X__pad": "__pad", "X__res0": "__res0", "X__res1": "__res1", "Data": "data",
+	}},
+	{reflect.TypeOf(ZCAN_CANFD_FRAME{}), "canfd_frame", map[string]string{
+		"Id": "can_id", "Len": "len", "Flags": "flags", "X__res0": "__res0", "X__res1": "__res1", "Data": "data",
+	}},
+	{reflect.TypeOf(ZCAN_Transmit_Data{}), "ZCAN_Transmit_Data", map[string]string{
+		"Frame": "frame", "Type": "transmit_type",
+	}},
+	{reflect.TypeOf(ZCAN_Receive_Data{}), "ZCAN_Receive_Data", map[string]string{
+		"Frame": "frame", "Timestamp": "timestamp",
+	}},
+	{reflect.TypeOf(ZCAN_TransmitFD_Data{}), "ZCAN_TransmitFD_Data", map[string]string{
+		"Frame": "frame", "Type": "transmit_type",
+	}},
+	{reflect.TypeOf(ZCAN_ReceiveFD_Data{}), "ZCAN_ReceiveFD_Data", map[string]string{
+		"Frame": "frame", "Timestamp": "timestamp",
+	}},
+	{reflect.TypeOf(ZCAN_AUTO_TRANSMIT_OBJ{}), "ZCAN_AUTO_TRANSMIT_OBJ", map[string]string{
+		"Enable": "enable", "Index": "index", "Interval": "interval", "Obj": "obj",
+	}},
+	{reflect.TypeOf(ZCANFD_AUTO_TRANSMIT_OBJ{}), "ZCANFD_AUTO_TRANSMIT_OBJ", map[string]string{
+		"Enable": "enable", "Index": "index", "Interval": "interval", "Obj": "obj",
+	}},
+	{reflect.TypeOf(ZCAN_IProperty{}), "IProperty", map[string]string{
+		"SetValue": "SetValue", "GetValue": "GetValue", "GetPropertys": "GetPropertys",
+	}},
+	{reflect.TypeOf(ZCAN_DATA_OBJ{}), "ZCANDataObj", map[string]string{
+		"DataType": "dataType", "Chnl": "chnl", "Flag": "flag", "ExtraData": "extraData", "Data": "data.raw",
+	}},
+	{reflect.TypeOf(ZCAN_CANFD_DATA{}), "ZCANCANFDData", map[string]string{
+		"Timestamp": "timeStamp", "Flag": "flag", "ExtraData": "extraData", "Frame": "frame",
+	}},
+	{reflect.TypeOf(ZCAN_ERROR_DATA{}), "ZCANErrorData", map[string]string{
+		"Timestamp": "timeStamp", "ErrType": "errType", "ErrSubType": "errSubType", "NodeState": "nodeState",
+		"RxErrCount": "rxErrCount", "TxErrCount": "txErrCount", "ErrData": "errData", "Reserved": "reserved",
+	}},
+	{reflect.TypeOf(ZCAN_BUS_USAGE{}), "BusUsage", map[string]string{
+		"TimestampBegin": "nTimeStampBegin", "TimestampEnd": "nTimeStampEnd", "Chnl": "nChnl",
+		"Reserved": "nReserved", "BusUsage": "nBusUsage", "FrameCount": "nFrameCount",
+	}},
+}
+
+// layoutMember is where a field lives: offset and size, or size and alignment for a whole struct.
+type layoutMember struct {
+	offset, size uintptr
+}
+
+// goLayout collects the layout of every mapped Go struct, keyed like the output of the C
+// program: "<type>" for the struct, "<type>.<member>" for each field that is not a struct.
+func goLayout(t *testing.T) map[string]layoutMember {
+	byType := map[reflect.Type]stubLayout{}
+	for _, l := range stubLayouts {
+		byType[l.goType] = l
+	}
+	layout := map[string]layoutMember{}
+	var walk func(l stubLayout, key, cPath string, base uintptr)
+	walk = func(l stubLayout, key, cPath string, base uintptr) {
+		for name := range l.members {
+			if _, ok := l.goType.FieldByName(name); !ok {
+				t.Fatalf("%s has no field %s", l.goType, name)
+			}
+		}
+		for i := 0; i < l.goType.NumField(); i++ {
+			f := l.goType.Field(i)
+			if f.Name == "_" {
+				continue
+			}
+			member, ok := l.members[f.Name]
+			if !ok {
+				t.Fatalf("%s.%s has no C member", l.goType, f.Name)
+			}
+			if cPath != "" {
+				member = cPath + "." + member
+			}
+			if f.Type.Kind() == reflect.Struct {
+				nested, ok := byType[f.Type]
+				if !ok {
+					t.Fatalf("%s.%s has a struct type without a layout", l.goType, f.Name)
+				}
+				walk(nested, key, member, base+f.Offset)
+				continue
+			}
+			layout[key+"."+member] = layoutMember{base + f.Offset, f.Type.Size()}
+		}
+	}
+	for _, l := range stubLayouts {
+		if l.cType == "" {
+			continue
+		}
+		key := l.goType.Name()
+		layout[key] = layoutMember{uintptr(l.goType.Align()), l.goType.Size()}
+		walk(l, key, "", 0)
+	}
+	return layout
+}
+
+// cProgram prints the C layout of every key in layout, the same way goLayout keys it.
+func cProgram(layout map[string]layoutMember) string {
+	cTypes := map[string]string{}
+	for _, l := range stubLayouts {
+		cTypes[l.goType.Name()] = l.cType
+	}
+	keys := make([]string, 0, len(layout))
+	for key := range layout {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString("#include <stddef.h>\n#include <stdio.h>\n#include \"zlgcan.h\"\n\nint main(void) {\n")
+	for _, key := range keys {
+		goType, member, isMember := strings.Cut(key, ".")
+		cType := cTypes[goType]
+		if !isMember {
+			fmt.Fprintf(&b, "\tprintf(\"%s %%zu %%zu\\n\", (size_t)_Alignof(%s), sizeof(%s));\n", key, cType, cType)
+			continue
+		}
+		fmt.Fprintf(&b, "\tprintf(\"%s %%zu %%zu\\n\", offsetof(%s, %s), sizeof(((%s *)0)->%s));\n", key, cType, member, cType, member)
+	}
+	b.WriteString("\treturn 0;\n}\n")
+	return b.String()
+}
+
+// cCompiler returns the C compiler from $CC, cc by default, skipping the test without one.
+func cCompiler(t *testing.T) string {
+	t.Helper()
+	cc := os.Getenv("CC")
+	if cc == "" {
+		cc = "cc"
+	}
+	path, err := exec.LookPath(cc)
+	if err != nil {
+		t.Skipf("No C compiler: %v", err)
+	}
+	return path
+}
+
+// Test that the Go structs passed to the library match the C layout of the stub header. The
+// header is a transcription of the vendor zlgcan.h that has not been checked against it, so
+// a mistake made in both the Go structs and the transcription goes unnoticed
+func TestStubHeaderLayout(t *testing.T) {
+	cc := cCompiler(t)
+	want := goLayout(t)
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "layout.c")
+	exe := filepath.Join(dir, "layout")
+	if runtime.GOOS == "windows" {
+		exe += ".exe"
+	}
+	if err := os.WriteFile(src, []byte(cProgram(want)), 0o644); err != nil {
+		t.Fatalf("Writing the layout program failed: %v", err)
+	}
+	header, err := filepath.Abs(filepath.Join("testdata", "zlgstub"))
+	if err != nil {
+		t.Fatalf("Abs failed: %v", err)
+	}
+	if out, err := exec.Command(cc, "-std=c11", "-Wall", "-Werror", "-I", header, "-o", exe, src).CombinedOutput(); err != nil {
+		t.Fatalf("Building the layout program failed: %v\n%s", err, out)
+	}
+	out, err := exec.Command(exe).Output()
+	if err != nil {
+		t.Fatalf("Running the layout program failed: %v", err)
+	}
+
+	got := map[string]layoutMember{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		var key string
+		var m layoutMember
+		if _, err := fmt.Sscan(scanner.Text(), &key, &m.offset, &m.size); err != nil {
+			t.Fatalf("Unexpected output %q: %v", scanner.Text(), err)
+		}
+		got[key] = m
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d layout entries from C, got %d", len(want), len(got))
+	}
+	for key, w := range want {
+		g := got[key]
+		if !strings.Contains(key, ".") {
+			if g != w {
+				t.Errorf("%s: C has size %d align %d, Go has size %d align %d", key, g.size, g.offset, w.size, w.offset)
+			}
+			continue
+		}
+		if g != w {
+			t.Errorf("%s: C has offset %d size %d, Go has offset %d size %d", key, g.offset, g.size, w.offset, w.size)
+		}
+	}
+}
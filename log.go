@@ -0,0 +1,63 @@
+package zlgcan
+
+import (
+	"context"
+	"io"
+	"log/slog"
+)
+
+type zcanOptions struct {
+	logger *slog.Logger
+	trace  io.Writer
+}
+
+// ZCANOption configures a ZCAN created by NewZCAN, NewSimulatedZCAN or NewReplayZCAN.
+type ZCANOption func(*zcanOptions)
+
+// WithLogger sends the package's diagnostics to logger. Without it the package logs
+// nothing.
+func WithLogger(logger *slog.Logger) ZCANOption {
+	return func(o *zcanOptions) {
+		o.logger = logger
+	}
+}
+
+func newZCAN(drv driver, opts []ZCANOption) *ZCAN {
+	var o zcanOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.trace != nil && drv != nil {
+		drv = newTracingDriver(drv, o.trace, o.logger)
+	}
+	return &ZCAN{drv: drv, logger: o.logger}
+}
+
+func (zc *ZCAN) log(level slog.Level, msg string, attrs ...slog.Attr) {
+	if zc.logger == nil || !zc.logger.Enabled(context.Background(), level) {
+		return
+	}
+	zc.logger.LogAttrs(context.Background(), level, msg, attrs...)
+}
+
+// deviceAttrs describes deviceHandle, with its type when the device was opened through zc.
+func (zc *ZCAN) deviceAttrs(deviceHandle int) []slog.Attr {
+	attrs := []slog.Attr{slog.Int("handle", deviceHandle)}
+	if entry, ok := zc.device(deviceHandle); ok {
+		attrs = append(attrs, slog.String("device_type", deviceTypeName(entry.deviceType)))
+	}
+	return attrs
+}
+
+// logStatus logs a library call that returned status instead of ZCAN_STATUS_OK.
+func (zc *ZCAN) logStatus(function string, status uint, attrs ...slog.Attr) {
+	zc.log(slog.LevelError, "library call failed",
+		append([]slog.Attr{slog.String("function", function), slog.Uint64("status", uint64(status))}, attrs...)...)
+}
+
+func deviceTypeName(deviceType int) string {
+	if spec, ok := GetDeviceSpec(deviceType); ok {
+		return spec.Name
+	}
+	return "unknown"
+}
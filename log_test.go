@@ -0,0 +1,72 @@
+package zlgcan
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+// Test structured log records of failed library calls
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	sim := NewSimulator()
+	if err := sim.AddDevice(ZCAN_USBCANFD_200U, 0, "SIM0000"); err != nil {
+		t.Fatalf("AddDevice failed: %v", err)
+	}
+	zc := NewSimulatedZCAN(sim, WithLogger(logger))
+	handle := zc.OpenDevice(ZCAN_USBCANFD_200U, 0, 0)
+	if _, err := zc.OpenChannel(handle, 0); err != nil {
+		t.Fatalf("OpenChannel failed: %v", err)
+	}
+	sim.SetOnline(ZCAN_USBCANFD_200U, 0, false)
+	if info := zc.GetDeviceInf(handle); info != nil {
+		t.Fatalf("Expected GetDeviceInf to fail on an offline device")
+	}
+
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var r map[string]any
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("Bad log line %q: %v", line, err)
+		}
+		records = append(records, r)
+	}
+	if len(records) != 2 || records[0]["msg"] != "channel started" {
+		t.Fatalf("Expected a start and a failure record, got %v", records)
+	}
+	r := records[1]
+	if r["level"] != "ERROR" || r["function"] != "ZCAN_GetDeviceInf" || r["device_type"] != "USBCANFD-200U" ||
+		r["handle"] != float64(handle) || r["status"] != float64(ZCAN_STATUS_ERR) {
+		t.Fatalf("Unexpected failure record %v", r)
+	}
+}
+
+// Test that nothing is printed without a logger
+func TestLoggerSilentByDefault(t *testing.T) {
+	stdout := os.Stdout
+	rd, wr, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe failed: %v", err)
+	}
+	os.Stdout = wr
+	defer func() { os.Stdout = stdout }()
+
+	sim := NewSimulator()
+	zc := NewSimulatedZCAN(sim)
+	zc.GetDeviceInf(1)
+	zc.ReadChannelErrInfo(1)
+	zc.ReadChannelStatus(1)
+	zc.OpenDevice(ZCAN_USBCANFD_200U, 0, 0)
+	NewZCAN("missing.dll")
+
+	wr.Close()
+	out, _ := io.ReadAll(rd)
+	if len(out) != 0 {
+		t.Fatalf("Expected no output, got %q", out)
+	}
+}
@@ -0,0 +1,150 @@
+package zlgcan
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// Data types of ZCAN_DATA_OBJ.
+const (
+	ZCAN_DT_ZCAN_CAN_CANFD_DATA = 1
+	ZCAN_DT_ZCAN_ERROR_DATA     = 2
+	ZCAN_DT_ZCAN_GPS_DATA       = 3
+	ZCAN_DT_ZCAN_LIN_DATA       = 4
+	ZCAN_DT_ZCAN_BUSUSAGE_DATA  = 5
+)
+
+// ZCAN_DATA_OBJ is the unified data object of ZCAN_TransmitData/ZCAN_ReceiveData.
+// Data holds one of ZCAN_CANFD_DATA, ZCAN_ERROR_DATA or ZCAN_BUS_USAGE depending on DataType.
+// The alignment makes it 104 bytes, as in the stub header; this size has not been checked
+// against a vendor header.
+type ZCAN_DATA_OBJ struct {
+	_         [0]uint64 // the C union holds 64 bit timestamps and is aligned like them
+	DataType  uint8
+	Chnl      uint8
+	Flag      uint16
+	ExtraData [4]uint8
+	Data      [92]uint8
+}
+
+type ZCAN_CANFD_DATA struct {
+	Timestamp uint64
+	Flag      uint32 // frameType:2 txDelay:2 transmitType:4 txEchoRequest:1 txEchoed:1
+	ExtraData [4]uint8
+	Frame     ZCAN_CANFD_FRAME
+}
+
+func (d *ZCAN_CANFD_DATA) IsFD() bool {
+	return d.Flag&0x3 == ZCAN_TYPE_CANFD
+}
+
+func (d *ZCAN_CANFD_DATA) TransmitType() TransmitType {
+	return TransmitType((d.Flag >> 4) & 0xF)
+}
+
+func (d *ZCAN_CANFD_DATA) TxEchoed() bool {
+	return d.Flag&(1<<9) != 0
+}
+
+type ZCAN_ERROR_DATA struct {
+	Timestamp  uint64
+	ErrType    uint8
+	ErrSubType uint8
+	NodeState  uint8
+	RxErrCount uint8
+	TxErrCount uint8
+	ErrData    uint8
+	Reserved   [2]uint8
+}
+
+type ZCAN_BUS_USAGE struct {
+	TimestampBegin uint64
+	TimestampEnd   uint64
+	Chnl           uint8
+	Reserved       uint8
+	BusUsage       uint16 // 0-10000, i.e. 0.00%-100.00%
+	FrameCount     uint32
+}
+
+// DataEvent is a decoded ZCAN_DATA_OBJ: a CANDataEvent, ErrorDataEvent, BusUsageEvent or RawDataEvent.
+type DataEvent interface {
+	Channel() uint8
+}
+
+type CANDataEvent struct {
+	Chnl uint8
+	ZCAN_CANFD_DATA
+}
+
+func (e CANDataEvent) Channel() uint8 { return e.Chnl }
+
+type ErrorDataEvent struct {
+	Chnl uint8
+	ZCAN_ERROR_DATA
+}
+
+func (e ErrorDataEvent) Channel() uint8 { return e.Chnl }
+
+type BusUsageEvent struct {
+	Chnl uint8
+	ZCAN_BUS_USAGE
+}
+
+func (e BusUsageEvent) Channel() uint8 { return e.Chnl }
+
+// RawDataEvent carries data objects of types this package does not decode (GPS, LIN).
+type RawDataEvent struct {
+	ZCAN_DATA_OBJ
+}
+
+func (e RawDataEvent) Channel() uint8 { return e.Chnl }
+
+// Decode interprets the object according to its DataType.
+func (obj *ZCAN_DATA_OBJ) Decode() DataEvent {
+	data := unsafe.Pointer(&obj.Data[0])
+	switch obj.DataType {
+	case ZCAN_DT_ZCAN_CAN_CANFD_DATA:
+		return CANDataEvent{Chnl: obj.Chnl, ZCAN_CANFD_DATA: *(*ZCAN_CANFD_DATA)(data)}
+	case ZCAN_DT_ZCAN_ERROR_DATA:
+		return ErrorDataEvent{Chnl: obj.Chnl, ZCAN_ERROR_DATA: *(*ZCAN_ERROR_DATA)(data)}
+	case ZCAN_DT_ZCAN_BUSUSAGE_DATA:
+		return BusUsageEvent{Chnl: obj.Chnl, ZCAN_BUS_USAGE: *(*ZCAN_BUS_USAGE)(data)}
+	}
+	return RawDataEvent{ZCAN_DATA_OBJ: *obj}
+}
+
+// NewCANDataObj wraps a classic CAN frame for TransmitData on channel chnl.
+func NewCANDataObj(chnl uint8, msg ZCAN_Transmit_Data) ZCAN_DATA_OBJ {
+	obj := ZCAN_DATA_OBJ{DataType: ZCAN_DT_ZCAN_CAN_CANFD_DATA, Chnl: chnl}
+	d := (*ZCAN_CANFD_DATA)(unsafe.Pointer(&obj.Data[0]))
+	d.Flag = ZCAN_TYPE_CAN | uint32(msg.Type&0xF)<<4
+	d.Frame.Id = msg.Frame.Id
+	d.Frame.Len = msg.Frame.Dlc
+	d.Frame.X__res0 = msg.Frame.X__res0
+	d.Frame.X__res1 = msg.Frame.X__res1
+	copy(d.Frame.Data[:], msg.Frame.Data[:])
+	return obj
+}
+
+// NewCANFDDataObj wraps a CANFD frame for TransmitData on channel chnl.
+func NewCANFDDataObj(chnl uint8, msg ZCAN_TransmitFD_Data) ZCAN_DATA_OBJ {
+	obj := ZCAN_DATA_OBJ{DataType: ZCAN_DT_ZCAN_CAN_CANFD_DATA, Chnl: chnl}
+	d := (*ZCAN_CANFD_DATA)(unsafe.Pointer(&obj.Data[0]))
+	d.Flag = ZCAN_TYPE_CANFD | uint32(msg.Type&0xF)<<4
+	d.Frame = msg.Frame
+	return obj
+}
+
+// EnableMergedReceive makes the device deliver frames of all channels, error frames and
+// bus usage through ReceiveData. The pending count is read with
+// GetReceiveNum(channelHandle, ZCAN_TYPE_ALL_DATA) on any channel of the device.
+func (zc *ZCAN) EnableMergedReceive(deviceHandle int, enable bool) error {
+	value := "0"
+	if enable {
+		value = "1"
+	}
+	if err := zc.setProperties(deviceHandle, []property{{"0/set_device_recv_merge", value}}); err != nil {
+		return fmt.Errorf("merged receive: %w", err)
+	}
+	return nil
+}
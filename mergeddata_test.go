@@ -0,0 +1,63 @@
+package zlgcan
+
+import (
+	"testing"
+	"unsafe"
+)
+
+// Test for the merged data object layouts
+func TestDataObjLayout(t *testing.T) {
+	if size := unsafe.Sizeof(ZCAN_DATA_OBJ{}); size != 104 {
+		t.Fatalf("ZCAN_DATA_OBJ: expected 104 bytes, got %d", size)
+	}
+	if offset := unsafe.Offsetof(ZCAN_DATA_OBJ{}.Data); offset != 8 {
+		t.Fatalf("ZCAN_DATA_OBJ.Data: expected offset 8, got %d", offset)
+	}
+	if size := unsafe.Sizeof(ZCAN_CANFD_DATA{}); size != 88 {
+		t.Fatalf("ZCAN_CANFD_DATA: expected 88 bytes, got %d", size)
+	}
+	if size := unsafe.Sizeof(ZCAN_ERROR_DATA{}); size != 16 {
+		t.Fatalf("ZCAN_ERROR_DATA: expected 16 bytes, got %d", size)
+	}
+	if size := unsafe.Sizeof(ZCAN_BUS_USAGE{}); size != 24 {
+		t.Fatalf("ZCAN_BUS_USAGE: expected 24 bytes, got %d", size)
+	}
+}
+
+// Test that data objects built for TransmitData decode back into the same frame
+func TestDataObjDecode(t *testing.T) {
+	msg := ZCAN_TransmitFD_Data{Type: ZCAN_TX_SELF_RX}
+	msg.Frame.GenerateID(0x123, 0, 0, 0)
+	msg.Frame.Len = 12
+	msg.Frame.Data[11] = 0x5A
+	obj := NewCANFDDataObj(1, msg)
+
+	event, ok := obj.Decode().(CANDataEvent)
+	if !ok {
+		t.Fatalf("Expected CANDataEvent, got %T", obj.Decode())
+	}
+	if event.Channel() != 1 || !event.IsFD() || event.TransmitType() != ZCAN_TX_SELF_RX {
+		t.Fatalf("Unexpected event header: %+v", event)
+	}
+	if event.Frame.GetFrameID() != 0x123 || event.Frame.Len != 12 || event.Frame.Data[11] != 0x5A {
+		t.Fatalf("Unexpected frame: %+v", event.Frame)
+	}
+
+	canMsg := ZCAN_Transmit_Data{}
+	canMsg.Frame.Dlc = 8
+	obj = NewCANDataObj(0, canMsg)
+	if event := obj.Decode().(CANDataEvent); event.IsFD() || event.Frame.Len != 8 {
+		t.Fatalf("Classic frame decoded as %+v", event)
+	}
+
+	obj = ZCAN_DATA_OBJ{DataType: ZCAN_DT_ZCAN_BUSUSAGE_DATA, Chnl: 1}
+	usage := (*ZCAN_BUS_USAGE)(unsafe.Pointer(&obj.Data[0]))
+	usage.BusUsage = 2550
+	if event, ok := obj.Decode().(BusUsageEvent); !ok || event.BusUsage != 2550 {
+		t.Fatalf("Bus usage decoded as %+v", obj.Decode())
+	}
+	obj.DataType = ZCAN_DT_ZCAN_GPS_DATA
+	if _, ok := obj.Decode().(RawDataEvent); !ok {
+		t.Fatalf("GPS data should decode as RawDataEvent")
+	}
+}
@@ -0,0 +1,298 @@
+//go:build linux && cgo
+
+package zlgcan
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"unsafe"
+)
+
+// buildStub compiles testdata/zlgstub into a shared object, skipping the test without a C
+// compiler.
+func buildStub(t *testing.T) string {
+	t.Helper()
+	cc := cCompiler(t)
+	lib := filepath.Join(t.TempDir(), "libzlgcan_stub.so")
+	out, err := exec.Command(cc, "-shared", "-fPIC", "-Wall", "-Werror", "-o", lib, filepath.Join("testdata", "zlgstub", "zlgcan_stub.c")).CombinedOutput()
+	if err != nil {
+		t.Fatalf("Building the stub library failed: %v\n%s", err, out)
+	}
+	return lib
+}
+
+// Test the native call path against the stub library
+func TestNativeStub(t *testing.T) {
+	if _, err := NewZCAN(filepath.Join(t.TempDir(), "missing.so")); err == nil {
+		t.Fatalf("Expected loading a missing library to fail")
+	}
+	zc, err := NewZCAN(buildStub(t))
+	if err != nil {
+		t.Fatalf("NewZCAN failed: %v", err)
+	}
+	defer zc.Close()
+	drv := zc.drv.(*dllDriver)
+
+	handle := zc.OpenDevice(ZCAN_USBCANFD_200U, 0, 0)
+	if handle == INVALID_DEVICE_HANDLE {
+		t.Fatalf("Open Device failed")
+	}
+	defer zc.CloseDevice(handle)
+
+	t.Run("Device", func(t *testing.T) {
+		if handle>>32 != 0x5a5a {
+			t.Fatalf("Expected the full 64 bit handle, got 0x%x", handle)
+		}
+		if again := zc.OpenDevice(ZCAN_USBCANFD_200U, 0, 0); again != INVALID_DEVICE_HANDLE {
+			t.Fatalf("Expected opening the device twice to fail")
+		}
+		info := zc.GetDeviceInf(handle)
+		if info == nil {
+			t.Fatalf("GetDeviceInf failed")
+		}
+		if info.HwVersion() != "V1.02" || info.InVersion() != "V4.05" || info.IrqNum() != 7 || info.CanNum() != 2 {
+			t.Fatalf("Unexpected versions %s %s, irq %d, channels %d", info.HwVersion(), info.InVersion(), info.IrqNum(), info.CanNum())
+		}
+		if info.Serial() != "STUB0000" || info.HwType() != "USBCANFD-200U" {
+			t.Fatalf("Unexpected serial %q or type %q", info.Serial(), info.HwType())
+		}
+		if ret := zc.IsDeviceOnLine(handle); ret != ZCAN_STATUS_ONLINE {
+			t.Fatalf("Expected the device online, got %d", ret)
+		}
+		if ret := drv.call("ZCAN_NoSuchExport", 1, 2, 3); ret != 0 {
+			t.Fatalf("Expected a missing export to fail, got %d", ret)
+		}
+	})
+
+	t.Run("InitConfig", func(t *testing.T) {
+		fd := ZCAN_CANFD_CHANNEL_INIT_CONFIG{CanType: ZCAN_TYPE_CANFD}
+		fd.Config.AccCode = 0x12345678
+		fd.Config.AccMask = 0x9abcdef0
+		fd.Config.AbitTiming = 0x11223344
+		fd.Config.DbitTiming = 0x55667788
+		fd.Config.Mode = ZCAN_MODE_LISTEN_ONLY
+		if drv.InitCANFD(handle, 1, &fd) == INVALID_CHANNEL_HANDLE {
+			t.Fatalf("InitCANFD failed")
+		}
+		classic := ZCAN_NORMAL_CHANNEL_INIT_CONFIG{CanType: ZCAN_TYPE_CAN}
+		classic.Config.AccCode = 0x80000000
+		classic.Config.AccMask = 0xFFFFFFFF
+		classic.Config.Timing0 = 0x01
+		classic.Config.Timing1 = 0x1C
+		classic.Config.Mode = ZCAN_MODE_LOOPBACK
+		if drv.InitCAN(handle, 2, &classic) == INVALID_CHANNEL_HANDLE {
+			t.Fatalf("InitCAN failed")
+		}
+		ip, err := zc.GetIProperty(handle)
+		if err != nil {
+			t.Fatalf("GetIProperty failed: %v", err)
+		}
+		defer zc.ReleaseIProperty(ip)
+		want := "can_type=1 acc_code=0x12345678 acc_mask=0x9abcdef0 abit=0x11223344 dbit=0x55667788 mode=1"
+		if got := zc.GetValue(ip, "stub/init/1"); got != want {
+			t.Fatalf("CANFD config arrived as %q, want %q", got, want)
+		}
+		want = "can_type=0 acc_code=0x80000000 acc_mask=0xffffffff timing0=0x1 timing1=0x1c mode=2"
+		if got := zc.GetValue(ip, "stub/init/2"); got != want {
+			t.Fatalf("CAN config arrived as %q, want %q", got, want)
+		}
+	})
+
+	ch, err := zc.OpenChannel(handle, 0)
+	if err != nil {
+		t.Fatalf("OpenChannel failed: %v", err)
+	}
+
+	t.Run("ChannelInit", func(t *testing.T) {
+		ip, err := zc.GetIProperty(handle)
+		if err != nil {
+			t.Fatalf("GetIProperty failed: %v", err)
+		}
+		defer zc.ReleaseIProperty(ip)
+		abit, _ := calcBitTiming(defaultCANFDClock, defaultBitrate, defaultSamplePoint, canfdNominalLimits)
+		dbit, _ := calcBitTiming(defaultCANFDClock, defaultDataBitrate, defaultSamplePoint, canfdDataLimits)
+		want := fmt.Sprintf("can_type=1 acc_code=0x0 acc_mask=0x0 abit=0x%x dbit=0x%x mode=0", canfdTimingWord(abit), canfdTimingWord(dbit))
+		if got := zc.GetValue(ip, "stub/init/0"); got != want {
+			t.Fatalf("OpenChannel config arrived as %q, want %q", got, want)
+		}
+
+		before := zc.GetValue(ip, "stub/init/1")
+		if _, err := zc.OpenChannel(handle, 1, WithCanType(ZCAN_TYPE_CAN)); !errors.Is(err, ErrInvalidCanType) {
+			t.Fatalf("Expected ErrInvalidCanType for a CAN channel on a CANFD device, got %v", err)
+		}
+		if got := zc.GetValue(ip, "stub/init/1"); got != before {
+			t.Fatalf("Expected no init config to reach the stub, got %q", got)
+		}
+	})
+
+	t.Run("Properties", func(t *testing.T) {
+		ip, err := zc.GetIProperty(handle)
+		if err != nil {
+			t.Fatalf("GetIProperty failed: %v", err)
+		}
+		defer zc.ReleaseIProperty(ip)
+		if got := zc.GetValue(ip, "0/clock"); got != "60000000" {
+			t.Fatalf("Expected OpenChannel to set the clock, got %q", got)
+		}
+		for _, value := range []string{"", "0x1ff", "通道-β/ok", "long value with spaces"} {
+			if ret := zc.SetValue(ip, "0/test", value); ret != ZCAN_STATUS_OK {
+				t.Fatalf("SetValue %q failed: %d", value, ret)
+			}
+			if got := zc.GetValue(ip, "0/test"); got != value {
+				t.Fatalf("Expected %q back, got %q", value, got)
+			}
+		}
+		if got := zc.GetValue(ip, "0/missing"); got != "" {
+			t.Fatalf("Expected an empty value for a missing property, got %q", got)
+		}
+	})
+
+	// The stub hands out one interface and unbinds it on release; OpenChannel must not
+	// release it under a holder.
+	t.Run("SharedIProperty", func(t *testing.T) {
+		ip, err := zc.GetIProperty(handle)
+		if err != nil {
+			t.Fatalf("GetIProperty failed: %v", err)
+		}
+		defer zc.ReleaseIProperty(ip)
+		ch1, err := zc.OpenChannel(handle, 1)
+		if err != nil {
+			t.Fatalf("OpenChannel failed: %v", err)
+		}
+		defer ch1.Close()
+		if ret := zc.SetValue(ip, "1/test", "1"); ret != ZCAN_STATUS_OK {
+			t.Fatalf("SetValue after OpenChannel failed: %d", ret)
+		}
+	})
+
+	t.Run("Frames", func(t *testing.T) {
+		var msgs []ZCAN_Transmit_Data
+		for i := 0; i < 3; i++ {
+			msg := ZCAN_Transmit_Data{Type: TransmitType(i)}
+			msg.Frame.GenerateID(uint32(0x100+i), 0, 0, uint8(i%2))
+			msg.Frame.Dlc = uint8(6 + i)
+			for j := range msg.Frame.Data {
+				msg.Frame.Data[j] = uint8(i*16 + j)
+			}
+			msgs = append(msgs, msg)
+		}
+		if sent := zc.Transmit(ch.Handle(), msgs, uint(len(msgs))); sent != 3 {
+			t.Fatalf("Expected 3 frames sent, got %d", sent)
+		}
+		rcv, n := zc.Receive(ch.Handle(), zc.GetReceiveNum(ch.Handle(), ZCAN_TYPE_CAN), 0)
+		if n != 3 {
+			t.Fatalf("Expected 3 frames back, got %d", n)
+		}
+		for i, r := range rcv[:n] {
+			if r.Frame != msgs[i].Frame || r.Timestamp == 0 || (i > 0 && r.Timestamp <= rcv[i-1].Timestamp) {
+				t.Fatalf("Frame %d came back as %+v, sent %+v", i, r, msgs[i].Frame)
+			}
+		}
+
+		var fdMsgs []ZCAN_TransmitFD_Data
+		for i := 0; i < 3; i++ {
+			msg := newFDTransmit(uint32(0x1ABCDE00+i), TransmitType(i))
+			msg.Frame.GenerateID(uint32(0x1ABCDE00+i), 0, 0, 1)
+			msg.Frame.Len = 64
+			msg.Frame.GenerateFlags(1, uint8(i%2), 0)
+			for j := range msg.Frame.Data {
+				msg.Frame.Data[j] = uint8(255 - i*64 - j)
+			}
+			fdMsgs = append(fdMsgs, msg)
+		}
+		if sent := zc.TransmitFD(ch.Handle(), fdMsgs, uint(len(fdMsgs))); sent != 3 {
+			t.Fatalf("Expected 3 CANFD frames sent, got %d", sent)
+		}
+		rcvFD, n := zc.ReceiveFD(ch.Handle(), zc.GetReceiveNum(ch.Handle(), ZCAN_TYPE_CANFD), 0)
+		if n != 3 {
+			t.Fatalf("Expected 3 CANFD frames back, got %d", n)
+		}
+		for i, r := range rcvFD[:n] {
+			if r.Frame != fdMsgs[i].Frame || r.Timestamp == 0 {
+				t.Fatalf("CANFD frame %d came back as %+v, sent %+v", i, r.Frame, fdMsgs[i].Frame)
+			}
+		}
+	})
+
+	t.Run("ChannelState", func(t *testing.T) {
+		errInfo, err := zc.ReadChannelErrInfo(ch.Handle())
+		if err != nil {
+			t.Fatalf("ReadChannelErrInfo failed: %v", err)
+		}
+		if *errInfo != (ZCAN_CHANNEL_ERR_INFO{ErrorCode: 0x21, PassiveErrData: [3]uint8{0x11, 0x22, 0x33}, ArLostErrData: 0x44}) {
+			t.Fatalf("Unexpected error info %+v", *errInfo)
+		}
+		status, err := zc.ReadChannelStatus(ch.Handle())
+		if err != nil {
+			t.Fatalf("ReadChannelStatus failed: %v", err)
+		}
+		want := ZCAN_CHANNEL_STATUS{1, 2, 3, 4, 5, 6, 7, 8, 0xdeadbeef}
+		if *status != want {
+			t.Fatalf("Unexpected status %+v", *status)
+		}
+	})
+
+	t.Run("AutoSend", func(t *testing.T) {
+		obj := ZCANFD_AUTO_TRANSMIT_OBJ{Enable: 1, Index: 3, Interval: 250, Obj: newFDTransmit(0x3FF, ZCAN_TX_SINGLE_SHOT)}
+		obj.Obj.Frame.Len = 64
+		obj.Obj.Frame.Data[0], obj.Obj.Frame.Data[63] = 0xA5, 0x5A
+		if err := ch.SetAutoSendFD(obj); err != nil {
+			t.Fatalf("SetAutoSendFD failed: %v", err)
+		}
+		can := ZCAN_AUTO_TRANSMIT_OBJ{Enable: 1, Index: 4, Interval: 1000}
+		can.Obj.Frame.GenerateID(0x7E0, 0, 0, 0)
+		can.Obj.Frame.Dlc = 8
+		can.Obj.Frame.Data[0], can.Obj.Frame.Data[7] = 0x02, 0x3E
+		ip, err := zc.GetIProperty(handle)
+		if err != nil {
+			t.Fatalf("GetIProperty failed: %v", err)
+		}
+		defer zc.ReleaseIProperty(ip)
+		if ret := zc.setValuePtr(ip, "0/auto_send", unsafe.Pointer(&can)); ret != ZCAN_STATUS_OK {
+			t.Fatalf("Setting auto_send failed: %d", ret)
+		}
+		want := fmt.Sprintf("enable=1 index=3 interval=250 id=0x%x len=64 type=1 data0=0xa5 data63=0x5a", obj.Obj.Frame.Id)
+		if got := zc.GetValue(ip, "0/auto_send_canfd"); got != want {
+			t.Fatalf("CANFD auto-send entry arrived as %q, want %q", got, want)
+		}
+		want = fmt.Sprintf("enable=1 index=4 interval=1000 id=0x%x len=8 type=0 data0=0x2 data7=0x3e", can.Obj.Frame.Id)
+		if got := zc.GetValue(ip, "0/auto_send"); got != want {
+			t.Fatalf("CAN auto-send entry arrived as %q, want %q", got, want)
+		}
+	})
+
+	t.Run("MergedData", func(t *testing.T) {
+		objs := make([]ZCAN_DATA_OBJ, 2)
+		for i := range objs {
+			objs[i].DataType = 1
+			objs[i].Chnl = uint8(i)
+			objs[i].Flag = 0x8001
+			objs[i].ExtraData = [4]uint8{1, 2, 3, uint8(i)}
+			for j := 8; j < len(objs[i].Data); j++ {
+				objs[i].Data[j] = uint8(i + j)
+			}
+		}
+		if sent := zc.TransmitData(handle, objs, 2); sent != 2 {
+			t.Fatalf("Expected 2 objects sent, got %d", sent)
+		}
+		rcv := make([]ZCAN_DATA_OBJ, 4)
+		if n := drv.ReceiveData(handle, rcv, 0); n != 2 {
+			t.Fatalf("Expected 2 objects back, got %d", n)
+		}
+		for i := range objs {
+			// The stub stamps the timestamp, the first 8 data bytes.
+			rcv[i].Data[0], rcv[i].Data[1], rcv[i].Data[2], rcv[i].Data[3] = 0, 0, 0, 0
+			rcv[i].Data[4], rcv[i].Data[5], rcv[i].Data[6], rcv[i].Data[7] = 0, 0, 0, 0
+			if rcv[i] != objs[i] {
+				t.Fatalf("Object %d came back as %+v, sent %+v", i, rcv[i], objs[i])
+			}
+		}
+	})
+
+	if err := ch.Close(); err != nil {
+		t.Fatalf("Closing the channel failed: %v", err)
+	}
+}
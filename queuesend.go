@@ -0,0 +1,105 @@
+package zlgcan
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TX_DELAY_SEND_FLAG marks a frame in queue send mode whose delay is stored in X__res0/X__res1.
+// It is a bit of can_frame.__pad and canfd_frame.flags, clear of CANFD_BRS and CANFD_ESI.
+const TX_DELAY_SEND_FLAG = 0x80
+
+// SetDelay makes the device wait delay (1 ms resolution, up to 65535 ms) after sending
+// this frame before the next queued frame. Only honoured in queue send mode.
+func (f *ZCAN_CAN_FRAME) SetDelay(delay time.Duration) {
+	ms := uint16(min(delay/time.Millisecond, 0xFFFF))
+	f.X__pad |= TX_DELAY_SEND_FLAG
+	f.X__res0 = uint8(ms)
+	f.X__res1 = uint8(ms >> 8)
+}
+
+// GetDelay returns the queue send delay of the frame, if set.
+func (f *ZCAN_CAN_FRAME) GetDelay() (time.Duration, bool) {
+	if f.X__pad&TX_DELAY_SEND_FLAG == 0 {
+		return 0, false
+	}
+	return time.Duration(uint16(f.X__res0)|uint16(f.X__res1)<<8) * time.Millisecond, true
+}
+
+// SetDelay makes the device wait delay after sending this frame, see ZCAN_CAN_FRAME.SetDelay.
+func (f *ZCAN_CANFD_FRAME) SetDelay(delay time.Duration) {
+	ms := uint16(min(delay/time.Millisecond, 0xFFFF))
+	f.Flags |= TX_DELAY_SEND_FLAG
+	f.X__res0 = uint8(ms)
+	f.X__res1 = uint8(ms >> 8)
+}
+
+// GetDelay returns the queue send delay of the frame, if set.
+func (f *ZCAN_CANFD_FRAME) GetDelay() (time.Duration, bool) {
+	if f.Flags&TX_DELAY_SEND_FLAG == 0 {
+		return 0, false
+	}
+	return time.Duration(uint16(f.X__res0)|uint16(f.X__res1)<<8) * time.Millisecond, true
+}
+
+func (ch *Channel) checkQueueSend() error {
+	entry, ok := ch.zc.device(ch.Device())
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrUnknownDevice, ch.Device())
+	}
+	if spec, ok := GetDeviceSpec(entry.deviceType); !ok || !spec.QueueSend {
+		return fmt.Errorf("%w: device type 0x%x", ErrQueueSendUnsupported, entry.deviceType)
+	}
+	return nil
+}
+
+// EnableQueueSend switches the channel between normal and queue send mode. In queue mode
+// frames passed to Transmit/TransmitFD are buffered by the device and sent one after the
+// other, honouring the per-frame delay set with SetDelay.
+func (ch *Channel) EnableQueueSend(enable bool) error {
+	if err := ch.checkQueueSend(); err != nil {
+		return err
+	}
+	value := "0"
+	if enable {
+		value = "1"
+	}
+	if err := ch.zc.setProperties(ch.Device(), []property{{fmt.Sprintf("%d/set_send_mode", ch.index), value}}); err != nil {
+		return err
+	}
+	ch.mu.Lock()
+	ch.opts.queueSend = enable
+	ch.mu.Unlock()
+	return nil
+}
+
+// QueueSpace returns how many more frames the device send queue can take.
+func (ch *Channel) QueueSpace() (int, error) {
+	if err := ch.checkQueueSend(); err != nil {
+		return 0, err
+	}
+	path := fmt.Sprintf("%d/get_device_available_tx_count/1", ch.index)
+	var value string
+	err := ch.zc.withProperty(ch.Device(), func(ip *ZCAN_IProperty) error {
+		value = ch.zc.drv.GetValue(ip, path)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	space, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("error reading %s: %q", path, value)
+	}
+	return space, nil
+}
+
+// ClearQueue drops the frames waiting in the device send queue.
+func (ch *Channel) ClearQueue() error {
+	if err := ch.checkQueueSend(); err != nil {
+		return err
+	}
+	return ch.zc.setProperties(ch.Device(), []property{{fmt.Sprintf("%d/clear_delay_send_queue", ch.index), "0"}})
+}
@@ -0,0 +1,86 @@
+package zlgcan
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Test for the per-frame queue send delay encoding
+func TestFrameDelay(t *testing.T) {
+	var frame ZCAN_CAN_FRAME
+	if _, ok := frame.GetDelay(); ok {
+		t.Fatalf("New frame should not carry a delay")
+	}
+	frame.SetDelay(1234 * time.Millisecond)
+	if frame.X__pad != TX_DELAY_SEND_FLAG || frame.X__res0 != 0xD2 || frame.X__res1 != 0x04 {
+		t.Fatalf("Unexpected encoding: pad 0x%02x res 0x%02x 0x%02x", frame.X__pad, frame.X__res0, frame.X__res1)
+	}
+	if delay, ok := frame.GetDelay(); !ok || delay != 1234*time.Millisecond {
+		t.Fatalf("GetDelay: %s %v", delay, ok)
+	}
+
+	var fdFrame ZCAN_CANFD_FRAME
+	fdFrame.SetDelay(time.Hour)
+	if delay, ok := fdFrame.GetDelay(); !ok || delay != 65535*time.Millisecond {
+		t.Fatalf("Delay should saturate at 65535ms, got %s", delay)
+	}
+	if fdFrame.GetFrameBRS() != 0 || fdFrame.GetFrameESI() != 0 {
+		t.Fatalf("The delay must not read as BRS/ESI, flags 0x%02x", fdFrame.Flags)
+	}
+
+	// The delay and the BRS bit live side by side in Flags.
+	fdFrame = ZCAN_CANFD_FRAME{}
+	fdFrame.GenerateFlags(1, 0, 0)
+	fdFrame.SetDelay(20 * time.Millisecond)
+	if fdFrame.Flags != TX_DELAY_SEND_FLAG|CANFD_BRS {
+		t.Fatalf("Unexpected flags 0x%02x", fdFrame.Flags)
+	}
+	if delay, ok := fdFrame.GetDelay(); !ok || delay != 20*time.Millisecond || fdFrame.GetFrameBRS() != 1 {
+		t.Fatalf("Expected BRS with a 20ms delay, got BRS %d delay %s %v", fdFrame.GetFrameBRS(), delay, ok)
+	}
+	fdFrame = ZCAN_CANFD_FRAME{}
+	fdFrame.GenerateFlags(1, 1, 0)
+	if _, ok := fdFrame.GetDelay(); ok {
+		t.Fatalf("A BRS/ESI frame should not carry a delay, flags 0x%02x", fdFrame.Flags)
+	}
+}
+
+// Test the CANFD flag bits against the vendor header: CANFD_BRS 0x01, CANFD_ESI 0x02 and
+// TX_DELAY_SEND_FLAG 0x80
+func TestCANFDFlagBits(t *testing.T) {
+	if CANFD_BRS != 0x01 || CANFD_ESI != 0x02 || TX_DELAY_SEND_FLAG != 0x80 {
+		t.Fatalf("Unexpected flag bits: BRS 0x%02x ESI 0x%02x delay 0x%02x", CANFD_BRS, CANFD_ESI, TX_DELAY_SEND_FLAG)
+	}
+	for _, tc := range []struct {
+		brs, esi, res uint8
+		flags         uint8
+	}{
+		{1, 0, 0, 0x01},
+		{0, 1, 0, 0x02},
+		{1, 1, 0, 0x03},
+		{0, 0, 1, 0x04},
+		{0, 0, 0x20, 0x80},
+		{1, 1, 0x3F, 0xFF},
+	} {
+		var frame ZCAN_CANFD_FRAME
+		frame.GenerateFlags(tc.brs, tc.esi, tc.res)
+		if frame.Flags != tc.flags {
+			t.Fatalf("GenerateFlags(%d, %d, 0x%x): got 0x%02x, want 0x%02x", tc.brs, tc.esi, tc.res, frame.Flags, tc.flags)
+		}
+		if frame.GetFrameBRS() != tc.brs || frame.GetFrameESI() != tc.esi || frame.GetFrameRES() != tc.res {
+			t.Fatalf("Flags 0x%02x read back as BRS %d ESI %d RES 0x%x", frame.Flags, frame.GetFrameBRS(), frame.GetFrameESI(), frame.GetFrameRES())
+		}
+	}
+}
+
+// Test that queue send is rejected on devices without it
+func TestQueueSendUnsupported(t *testing.T) {
+	ch := &Channel{zc: newTestZCAN(1, ZCAN_USBCAN2, 2), device: 1}
+	if err := ch.EnableQueueSend(true); !errors.Is(err, ErrQueueSendUnsupported) {
+		t.Fatalf("Queue send on USBCAN-II: expected ErrQueueSendUnsupported, got %v", err)
+	}
+	if _, err := ch.QueueSpace(); !errors.Is(err, ErrQueueSendUnsupported) {
+		t.Fatalf("QueueSpace on USBCAN-II: expected ErrQueueSendUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package zlgcan
+
+import "time"
+
+// ReceiveMode selects how a Receiver waits for frames.
+type ReceiveMode uint8
+
+const (
+	ReceiveAdaptive ReceiveMode = iota // poll GetReceiveNum, the interval following the load
+	ReceiveBlocking                    // block in ZCAN_Receive/ZCAN_ReceiveFD
+)
+
+// ReceiveStrategy configures the Receivers of a channel. Zero fields take the defaults of
+// DefaultReceiveStrategy.
+type ReceiveStrategy struct {
+	Mode ReceiveMode
+	// WaitTime is the longest a blocking call waits. The library counts in milliseconds.
+	WaitTime time.Duration
+	// MinInterval and MaxInterval bound the adaptive poll interval. It halves every time
+	// frames are found and doubles every time none are.
+	MinInterval time.Duration
+	MaxInterval time.Duration
+	// MaxLatency caps WaitTime and MaxInterval, so a frame never waits longer in the
+	// device buffer.
+	MaxLatency time.Duration
+	// BatchSize is the most frames read per call.
+	BatchSize uint
+}
+
+// DefaultReceiveStrategy is used by channels opened without WithReceiveStrategy.
+var DefaultReceiveStrategy = ReceiveStrategy{
+	Mode:        ReceiveAdaptive,
+	WaitTime:    10 * time.Millisecond,
+	MinInterval: 100 * time.Microsecond,
+	MaxInterval: 10 * time.Millisecond,
+	BatchSize:   256,
+}
+
+func (s ReceiveStrategy) normalized() ReceiveStrategy {
+	d := DefaultReceiveStrategy
+	if s.WaitTime <= 0 {
+		s.WaitTime = d.WaitTime
+	}
+	if s.MinInterval <= 0 {
+		s.MinInterval = d.MinInterval
+	}
+	if s.MaxInterval <= 0 {
+		s.MaxInterval = max(d.MaxInterval, s.MinInterval)
+	}
+	if s.BatchSize == 0 {
+		s.BatchSize = d.BatchSize
+	}
+	if s.MaxLatency > 0 {
+		s.WaitTime = min(s.WaitTime, s.MaxLatency)
+		s.MaxInterval = min(s.MaxInterval, s.MaxLatency)
+	}
+	s.WaitTime = max(s.WaitTime, time.Millisecond)
+	s.MinInterval = min(s.MinInterval, s.MaxInterval)
+	return s
+}
+
+// WithReceiveStrategy sets how Receivers and Dispatchers of the channel wait for frames.
+func WithReceiveStrategy(s ReceiveStrategy) ChannelOption {
+	return func(o *channelOptions) {
+		o.receive = s
+	}
+}
+
+// Receiver reads a channel in batches following the channel's ReceiveStrategy. Like the
+// library's receive functions, a channel should have only one Receiver at a time.
+type Receiver struct {
+	ch       *Channel
+	strategy ReceiveStrategy
+	interval time.Duration
+}
+
+// NewReceiver creates a Receiver for ch.
+func NewReceiver(ch *Channel) *Receiver {
+	s := ch.opts.receive.normalized()
+	return &Receiver{ch: ch, strategy: s, interval: s.MinInterval}
+}
+
+// Strategy returns the strategy in use, with the defaults filled in.
+func (r *Receiver) Strategy() ReceiveStrategy {
+	return r.strategy
+}
+
+// Interval returns the current adaptive poll interval.
+func (r *Receiver) Interval() time.Duration {
+	return r.interval
+}
+
+func canFrames(rcv []ZCAN_Receive_Data, n uint) []RxFrame {
+	rcv = rcv[:min(int(n), len(rcv))]
+	frames := make([]RxFrame, len(rcv))
+	for i := range rcv {
+		frames[i].CAN = &rcv[i]
+	}
+	return frames
+}
+
+func fdFrames(rcv []ZCAN_ReceiveFD_Data, n uint) []RxFrame {
+	rcv = rcv[:min(int(n), len(rcv))]
+	frames := make([]RxFrame, len(rcv))
+	for i := range rcv {
+		frames[i].FD = &rcv[i]
+	}
+	return frames
+}
+
+// pendingWaitTime is the wait, in ms, of the reads in readPending. The frames were counted
+// already, so the reads need not wait; ZCAN.ReceiveFD turns a wait of 0 into an infinite one.
+const pendingWaitTime = 1
+
+// readPending reads the frames already in the device buffer, at most BatchSize of them.
+func (r *Receiver) readPending() []RxFrame {
+	handle := r.ch.Handle()
+	limit := r.strategy.BatchSize
+	var frames []RxFrame
+	if num := r.ch.zc.GetReceiveNum(handle, ZCAN_TYPE_CAN); num > 0 {
+		frames = canFrames(r.ch.Receive(min(num, limit), pendingWaitTime))
+	}
+	if r.ch.CanType() != ZCAN_TYPE_CANFD || uint(len(frames)) >= limit {
+		return frames
+	}
+	if num := r.ch.zc.GetReceiveNum(handle, ZCAN_TYPE_CANFD); num > 0 {
+		frames = append(frames, fdFrames(r.ch.ReceiveFD(min(num, limit-uint(len(frames))), pendingWaitTime))...)
+	}
+	return frames
+}
+
+// adapt moves the poll interval after a poll that found n frames.
+func (r *Receiver) adapt(n int) {
+	s := r.strategy
+	switch {
+	case uint(n) >= s.BatchSize:
+		r.interval = s.MinInterval
+	case n > 0:
+		r.interval = max(r.interval/2, s.MinInterval)
+	default:
+		r.interval = min(r.interval*2, s.MaxInterval)
+	}
+}
+
+// Next waits for frames and returns the next batch. The second result is false once stop
+// is closed; a blocking Receiver notices that only when its current wait ends.
+func (r *Receiver) Next(stop <-chan struct{}) ([]RxFrame, bool) {
+	for {
+		select {
+		case <-stop:
+			return nil, false
+		default:
+		}
+		frames := r.readPending()
+		if r.strategy.Mode == ReceiveBlocking {
+			if len(frames) == 0 {
+				frames = r.block()
+			}
+			if len(frames) > 0 {
+				return frames, true
+			}
+			continue
+		}
+		r.adapt(len(frames))
+		if len(frames) > 0 {
+			return frames, true
+		}
+		timer := time.NewTimer(r.interval)
+		select {
+		case <-stop:
+			timer.Stop()
+			return nil, false
+		case <-timer.C:
+		}
+	}
+}
+
+// block waits for frames in the library. A CANFD channel waits on the CANFD buffer, so a
+// classic frame arriving meanwhile is picked up after at most WaitTime.
+func (r *Receiver) block() []RxFrame {
+	wait := int(r.strategy.WaitTime / time.Millisecond)
+	if r.ch.CanType() == ZCAN_TYPE_CANFD {
+		return fdFrames(r.ch.ReceiveFD(r.strategy.BatchSize, wait))
+	}
+	return canFrames(r.ch.Receive(r.strategy.BatchSize, wait))
+}
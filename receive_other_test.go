@@ -0,0 +1,10 @@
+//go:build !unix
+
+package zlgcan
+
+import "time"
+
+// processCPUTime is not measured on this platform.
+func processCPUTime() (time.Duration, bool) {
+	return 0, false
+}
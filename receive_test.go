@@ -0,0 +1,228 @@
+package zlgcan
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// Test filling in and capping the receive strategy
+func TestReceiveStrategyNormalized(t *testing.T) {
+	s := ReceiveStrategy{}.normalized()
+	if s.WaitTime != 10*time.Millisecond || s.MinInterval != 100*time.Microsecond || s.MaxInterval != 10*time.Millisecond || s.BatchSize != 256 {
+		t.Fatalf("Unexpected defaults %+v", s)
+	}
+	s = ReceiveStrategy{WaitTime: 50 * time.Millisecond, MinInterval: 5 * time.Millisecond, MaxLatency: 2 * time.Millisecond}.normalized()
+	if s.WaitTime != 2*time.Millisecond || s.MaxInterval != 2*time.Millisecond || s.MinInterval != 2*time.Millisecond {
+		t.Fatalf("Expected everything capped by MaxLatency, got %+v", s)
+	}
+	if s = (ReceiveStrategy{WaitTime: time.Microsecond}).normalized(); s.WaitTime != time.Millisecond {
+		t.Fatalf("Expected the wait rounded up to 1ms, got %v", s.WaitTime)
+	}
+}
+
+// Test the adaptive poll interval following the load
+func TestReceiverAdapt(t *testing.T) {
+	r := &Receiver{strategy: ReceiveStrategy{MinInterval: time.Millisecond, MaxInterval: 8 * time.Millisecond, BatchSize: 10}}
+	r.interval = r.strategy.MinInterval
+	for i := 0; i < 5; i++ {
+		r.adapt(0)
+	}
+	if r.Interval() != 8*time.Millisecond {
+		t.Fatalf("Expected the idle interval capped at 8ms, got %v", r.Interval())
+	}
+	r.adapt(3)
+	if r.Interval() != 4*time.Millisecond {
+		t.Fatalf("Expected the interval halved, got %v", r.Interval())
+	}
+	r.adapt(10)
+	if r.Interval() != time.Millisecond {
+		t.Fatalf("Expected a full batch to reset the interval, got %v", r.Interval())
+	}
+}
+
+// Test receiving in batches with both strategies
+func TestReceiver(t *testing.T) {
+	for _, mode := range []ReceiveMode{ReceiveAdaptive, ReceiveBlocking} {
+		sim := NewSimulator()
+		zc, handle := newSimulatedDevice(t, sim, ZCAN_USBCANFD_200U, 0)
+		tx, err := zc.OpenChannel(handle, 0)
+		if err != nil {
+			t.Fatalf("OpenChannel 0 failed: %v", err)
+		}
+		rx, err := zc.OpenChannel(handle, 1, WithReceiveStrategy(ReceiveStrategy{Mode: mode, BatchSize: 3}))
+		if err != nil {
+			t.Fatalf("OpenChannel 1 failed: %v", err)
+		}
+		r := NewReceiver(rx)
+
+		classic := ZCAN_Transmit_Data{}
+		classic.Frame.GenerateID(0x10, 0, 0, 0)
+		zc.Transmit(tx.Handle(), []ZCAN_Transmit_Data{classic, classic}, 2)
+		msgs := []ZCAN_TransmitFD_Data{newFDTransmit(0x20, ZCAN_TX_NORMAL), newFDTransmit(0x21, ZCAN_TX_NORMAL)}
+		zc.TransmitFD(tx.Handle(), msgs, 2)
+		frames, ok := r.Next(nil)
+		if !ok || len(frames) != 3 || frames[0].CAN == nil || frames[2].FD == nil {
+			t.Fatalf("Mode %d: expected 2 CAN and 1 CANFD frame in the first batch, got %d", mode, len(frames))
+		}
+		if frames, _ = r.Next(nil); len(frames) != 1 || frames[0].ID() != 0x21 {
+			t.Fatalf("Mode %d: expected the remaining CANFD frame", mode)
+		}
+
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			zc.TransmitFD(tx.Handle(), msgs, 1)
+		}()
+		if frames, _ = r.Next(nil); len(frames) != 1 || frames[0].ID() != 0x20 {
+			t.Fatalf("Mode %d: expected a frame after waiting", mode)
+		}
+
+		stop := make(chan struct{})
+		close(stop)
+		if _, ok := r.Next(stop); ok {
+			t.Fatalf("Mode %d: expected Next to return after stop", mode)
+		}
+	}
+}
+
+// benchmarkReceive sends b.N frames gap apart and reports the mean latency from transmit to
+// receive and the CPU used by the process relative to the wall time.
+func benchmarkReceive(b *testing.B, strategy ReceiveStrategy, spin bool, gap time.Duration) {
+	sim := NewSimulator()
+	sim.AddDevice(ZCAN_USBCANFD_200U, 0, "BENCH")
+	zc := NewSimulatedZCAN(sim)
+	handle := zc.OpenDevice(ZCAN_USBCANFD_200U, 0, 0)
+	tx, err := zc.OpenChannel(handle, 0)
+	if err != nil {
+		b.Fatalf("OpenChannel 0 failed: %v", err)
+	}
+	rx, err := zc.OpenChannel(handle, 1, WithReceiveStrategy(strategy))
+	if err != nil {
+		b.Fatalf("OpenChannel 1 failed: %v", err)
+	}
+
+	start := time.Now()
+	latency := make(chan time.Duration)
+	stop := make(chan struct{})
+	record := func(f RxFrame, total *time.Duration) {
+		sent := time.Duration(binary.LittleEndian.Uint64(f.FD.Frame.Data[:8]))
+		*total += time.Since(start) - sent
+	}
+	go func() {
+		var total time.Duration
+		defer func() { latency <- total }()
+		if spin {
+			// The pattern of the package examples: spin on GetReceiveNum.
+			for received := 0; received < b.N; {
+				if num := zc.GetReceiveNum(rx.Handle(), ZCAN_TYPE_CANFD); num > 0 {
+					rcv, n := rx.ReceiveFD(num, 0)
+					for _, f := range fdFrames(rcv, n) {
+						record(f, &total)
+					}
+					received += int(n)
+				}
+			}
+			return
+		}
+		r := NewReceiver(rx)
+		for received := 0; received < b.N; {
+			frames, ok := r.Next(stop)
+			if !ok {
+				return
+			}
+			for _, f := range frames {
+				record(f, &total)
+			}
+			received += len(frames)
+		}
+	}()
+
+	cpuStart, cpuOK := processCPUTime()
+	b.ResetTimer()
+	msg := []ZCAN_TransmitFD_Data{newFDTransmit(0x100, ZCAN_TX_NORMAL)}
+	for i := 0; i < b.N; i++ {
+		binary.LittleEndian.PutUint64(msg[0].Frame.Data[:8], uint64(time.Since(start)))
+		zc.TransmitFD(tx.Handle(), msg, 1)
+		if gap > 0 {
+			time.Sleep(gap)
+		}
+	}
+	var total time.Duration
+	select {
+	case total = <-latency:
+	case <-time.After(time.Second):
+		close(stop)
+		b.Fatalf("Frames lost")
+	}
+	b.StopTimer()
+	wall := time.Since(start)
+	b.ReportMetric(float64(total.Microseconds())/float64(b.N), "latency-us")
+	if cpuEnd, ok := processCPUTime(); cpuOK && ok {
+		b.ReportMetric(float64(cpuEnd-cpuStart)/float64(wall)*100, "cpu-%")
+	}
+}
+
+func BenchmarkReceiveSpin(b *testing.B) {
+	benchmarkReceive(b, ReceiveStrategy{}, true, time.Millisecond)
+}
+
+func BenchmarkReceiveAdaptive(b *testing.B) {
+	benchmarkReceive(b, ReceiveStrategy{}, false, time.Millisecond)
+}
+
+func BenchmarkReceiveAdaptiveLowLatency(b *testing.B) {
+	benchmarkReceive(b, ReceiveStrategy{MinInterval: 20 * time.Microsecond, MaxLatency: 500 * time.Microsecond}, false, time.Millisecond)
+}
+
+func BenchmarkReceiveBlocking(b *testing.B) {
+	benchmarkReceive(b, ReceiveStrategy{Mode: ReceiveBlocking}, false, time.Millisecond)
+}
+
+func BenchmarkReceiveBurstAdaptive(b *testing.B) {
+	benchmarkReceive(b, ReceiveStrategy{}, false, 0)
+}
+
+func BenchmarkReceiveBurstBlocking(b *testing.B) {
+	benchmarkReceive(b, ReceiveStrategy{Mode: ReceiveBlocking}, false, 0)
+}
+
+// staleCount is a simulator reporting a CANFD frame more than its channels hold, like a
+// count read just before another reader took the frame.
+type staleCount struct {
+	*Simulator
+}
+
+func (d staleCount) GetReceiveNum(channelHandle int, canType uint) uint {
+	num := d.Simulator.GetReceiveNum(channelHandle, canType)
+	if canType == ZCAN_TYPE_CANFD {
+		num++
+	}
+	return num
+}
+
+// Test that reading counted frames does not wait for frames that are gone
+func TestReceiverReadPendingStale(t *testing.T) {
+	sim := NewSimulator()
+	if err := sim.AddDevice(ZCAN_USBCANFD_200U, 0, "SIM0000"); err != nil {
+		t.Fatalf("AddDevice failed: %v", err)
+	}
+	zc := newZCAN(staleCount{sim}, nil)
+	handle := zc.OpenDevice(ZCAN_USBCANFD_200U, 0, 0)
+	ch, err := zc.OpenChannel(handle, 0)
+	if err != nil {
+		t.Fatalf("OpenChannel failed: %v", err)
+	}
+	r := NewReceiver(ch)
+	done := make(chan int, 1)
+	go func() {
+		done <- len(r.readPending())
+	}()
+	select {
+	case n := <-done:
+		if n != 0 {
+			t.Fatalf("Expected no frames, got %d", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("readPending waited for a frame that is not there")
+	}
+}
@@ -0,0 +1,267 @@
+package zlgcan
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrRecoveryGaveUp = errors.New("bus-off recovery gave up")
+
+// SupervisorEventType tells what a SupervisorEvent reports.
+type SupervisorEventType uint8
+
+const (
+	SupervisorStateChanged SupervisorEventType = iota
+	SupervisorRecoveryAttempt
+	SupervisorRecovered
+	SupervisorGaveUp
+	SupervisorPollFailed
+)
+
+func (t SupervisorEventType) String() string {
+	switch t {
+	case SupervisorStateChanged:
+		return "state changed"
+	case SupervisorRecoveryAttempt:
+		return "recovery attempt"
+	case SupervisorRecovered:
+		return "recovered"
+	case SupervisorGaveUp:
+		return "gave up"
+	case SupervisorPollFailed:
+		return "poll failed"
+	}
+	return fmt.Sprintf("event(%d)", uint8(t))
+}
+
+// SupervisorEvent reports a state change of a supervised channel or a recovery step.
+type SupervisorEvent struct {
+	Type    SupervisorEventType
+	Time    time.Time
+	From    ControllerState
+	To      ControllerState
+	Attempt int   // recovery attempt, starting at 1
+	Err     error // why a recovery attempt or a poll failed
+}
+
+type supervisorOptions struct {
+	pollInterval time.Duration
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	maxRetries   int
+	events       chan<- SupervisorEvent
+}
+
+// SupervisorOption configures a Supervisor.
+type SupervisorOption func(*supervisorOptions)
+
+// WithPollInterval sets how often the channel status is read.
+func WithPollInterval(d time.Duration) SupervisorOption {
+	return func(o *supervisorOptions) {
+		o.pollInterval = d
+	}
+}
+
+// WithRecoveryBackoff sets the pause before the first recovery attempt; it doubles after
+// every failed attempt up to max.
+func WithRecoveryBackoff(initial, max time.Duration) SupervisorOption {
+	return func(o *supervisorOptions) {
+		o.minBackoff = initial
+		o.maxBackoff = max
+	}
+}
+
+// WithRecoveryRetries limits the failed recovery attempts before the supervisor gives up.
+// Zero or less retries forever.
+func WithRecoveryRetries(n int) SupervisorOption {
+	return func(o *supervisorOptions) {
+		o.maxRetries = n
+	}
+}
+
+// WithSupervisorEvents delivers every SupervisorEvent to events. The channel must be drained.
+func WithSupervisorEvents(events chan<- SupervisorEvent) SupervisorOption {
+	return func(o *supervisorOptions) {
+		o.events = events
+	}
+}
+
+// Supervisor watches a channel for bus-off and brings it back by resetting, initializing
+// and starting it again with the options it was opened with.
+type Supervisor struct {
+	ch   *Channel
+	opts supervisorOptions
+
+	mu      sync.Mutex
+	state   ControllerState
+	running bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewSupervisor creates a stopped supervisor for ch.
+func NewSupervisor(ch *Channel, opts ...SupervisorOption) *Supervisor {
+	o := supervisorOptions{
+		pollInterval: 100 * time.Millisecond,
+		minBackoff:   100 * time.Millisecond,
+		maxBackoff:   10 * time.Second,
+		maxRetries:   10,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.pollInterval = max(o.pollInterval, time.Millisecond)
+	o.minBackoff = max(o.minBackoff, time.Millisecond)
+	o.maxBackoff = max(o.maxBackoff, o.minBackoff)
+	return &Supervisor{ch: ch, opts: o}
+}
+
+// State returns the last observed controller state.
+func (s *Supervisor) State() ControllerState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// Start begins polling the channel.
+func (s *Supervisor) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return
+	}
+	s.running = true
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run(s.stop, s.done)
+}
+
+// Stop halts the supervisor and waits for a running recovery attempt to finish.
+func (s *Supervisor) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stop)
+	done := s.done
+	s.mu.Unlock()
+	<-done
+}
+
+func (s *Supervisor) emit(e SupervisorEvent) {
+	e.Time = time.Now()
+	if s.opts.events != nil {
+		s.opts.events <- e
+	}
+}
+
+func (s *Supervisor) setState(state ControllerState) {
+	s.mu.Lock()
+	from := s.state
+	s.state = state
+	s.mu.Unlock()
+	if from != state {
+		s.emit(SupervisorEvent{Type: SupervisorStateChanged, From: from, To: state})
+	}
+}
+
+// errInfoState derives the controller state from the latched error flags.
+func errInfoState(code ErrorCode) ControllerState {
+	switch {
+	case code.Has(ZCAN_ERROR_CAN_BUSOFF):
+		return ControllerBusOff
+	case code.Has(ZCAN_ERROR_CAN_PASSIVE):
+		return ControllerErrorPassive
+	case code.Has(ZCAN_ERROR_CAN_ERRALARM):
+		return ControllerErrorWarning
+	}
+	return ControllerErrorActive
+}
+
+// poll reads the error information and the status of the channel and returns the more
+// severe state. The error flags catch a bus-off that was already left again.
+func (s *Supervisor) poll() (ControllerState, error) {
+	errInfo, errInfoErr := s.ch.zc.ReadChannelErrInfo(s.ch.Handle())
+	status, statusErr := s.ch.zc.ReadChannelStatus(s.ch.Handle())
+	if errInfoErr != nil && statusErr != nil {
+		return 0, errors.Join(errInfoErr, statusErr)
+	}
+	state := ControllerErrorActive
+	if errInfoErr == nil {
+		state = errInfoState(ErrorCode(errInfo.ErrorCode))
+	}
+	if statusErr == nil {
+		state = max(state, status.Decode().State)
+	}
+	return state, nil
+}
+
+// nextBackoff doubles d up to limit.
+func nextBackoff(d, limit time.Duration) time.Duration {
+	return min(2*d, limit)
+}
+
+func (s *Supervisor) wait(d time.Duration, stop chan struct{}) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+// recoverBusOff resets and restarts the channel until it succeeds, the retries are used up or
+// the supervisor is stopped. It returns false if the supervisor should exit.
+func (s *Supervisor) recoverBusOff(stop chan struct{}) bool {
+	backoff := s.opts.minBackoff
+	for attempt := 1; ; attempt++ {
+		if !s.wait(backoff, stop) {
+			return false
+		}
+		err := s.ch.restart()
+		s.emit(SupervisorEvent{Type: SupervisorRecoveryAttempt, From: ControllerBusOff, Attempt: attempt, Err: err})
+		if err == nil {
+			s.setState(ControllerErrorActive)
+			s.emit(SupervisorEvent{Type: SupervisorRecovered, From: ControllerBusOff, To: ControllerErrorActive, Attempt: attempt})
+			return true
+		}
+		if s.opts.maxRetries > 0 && attempt >= s.opts.maxRetries {
+			s.emit(SupervisorEvent{Type: SupervisorGaveUp, From: ControllerBusOff, To: ControllerBusOff, Attempt: attempt,
+				Err: fmt.Errorf("%w after %d attempts: %w", ErrRecoveryGaveUp, attempt, err)})
+			return false
+		}
+		backoff = nextBackoff(backoff, s.opts.maxBackoff)
+	}
+}
+
+func (s *Supervisor) run(stop, done chan struct{}) {
+	defer close(done)
+	// After giving up the supervisor is stopped and may be started again.
+	defer func() {
+		s.mu.Lock()
+		if s.stop == stop {
+			s.running = false
+		}
+		s.mu.Unlock()
+	}()
+	for {
+		state, err := s.poll()
+		if err != nil {
+			s.emit(SupervisorEvent{Type: SupervisorPollFailed, Err: err})
+		} else {
+			s.setState(state)
+			if state == ControllerBusOff && !s.recoverBusOff(stop) {
+				return
+			}
+		}
+		if !s.wait(s.opts.pollInterval, stop) {
+			return
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package zlgcan
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Test for the state derived from latched error flags
+func TestErrInfoState(t *testing.T) {
+	cases := map[ErrorCode]ControllerState{
+		0:                       ControllerErrorActive,
+		ZCAN_ERROR_CAN_BUSERR:   ControllerErrorActive,
+		ZCAN_ERROR_CAN_ERRALARM: ControllerErrorWarning,
+		ZCAN_ERROR_CAN_ERRALARM | ZCAN_ERROR_CAN_PASSIVE: ControllerErrorPassive,
+		ZCAN_ERROR_CAN_PASSIVE | ZCAN_ERROR_CAN_BUSOFF:   ControllerBusOff,
+	}
+	for code, want := range cases {
+		if got := errInfoState(code); got != want {
+			t.Fatalf("Expected %s for %s, got %s", want, code, got)
+		}
+	}
+}
+
+// Test for the recovery backoff
+func TestSupervisorBackoff(t *testing.T) {
+	s := NewSupervisor(nil, WithRecoveryBackoff(50*time.Millisecond, 300*time.Millisecond), WithRecoveryRetries(3))
+	var got []time.Duration
+	for d := s.opts.minBackoff; len(got) < 5; d = nextBackoff(d, s.opts.maxBackoff) {
+		got = append(got, d)
+	}
+	want := []time.Duration{50 * time.Millisecond, 100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected backoff %v, got %v", want, got)
+		}
+	}
+	if s.opts.maxRetries != 3 || s.opts.pollInterval != 100*time.Millisecond {
+		t.Fatalf("Unexpected options: %+v", s.opts)
+	}
+
+	// A maximum below the initial backoff is raised to it.
+	s = NewSupervisor(nil, WithRecoveryBackoff(time.Second, time.Millisecond))
+	if s.opts.maxBackoff != time.Second {
+		t.Fatalf("Expected max backoff 1s, got %v", s.opts.maxBackoff)
+	}
+}
+
+func nextSupervisorEvent(t *testing.T, events <-chan SupervisorEvent) SupervisorEvent {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(time.Second):
+		t.Fatalf("Expected a supervisor event")
+	}
+	return SupervisorEvent{}
+}
+
+// failingInit is a simulator counting channel resets whose channel initialization fails
+// while fail is set.
+type failingInit struct {
+	*Simulator
+	fail   atomic.Bool
+	resets atomic.Int32
+}
+
+func (d *failingInit) ResetCAN(channelHandle int) uint {
+	d.resets.Add(1)
+	return d.Simulator.ResetCAN(channelHandle)
+}
+
+func (d *failingInit) InitCANFD(deviceHandle int, canIndex uint, initConfig *ZCAN_CANFD_CHANNEL_INIT_CONFIG) int {
+	if d.fail.Load() {
+		return INVALID_CHANNEL_HANDLE
+	}
+	return d.Simulator.InitCANFD(deviceHandle, canIndex, initConfig)
+}
+
+// Test bus-off recovery and giving up on a simulated channel
+func TestSupervisorSimulated(t *testing.T) {
+	sim := NewSimulator()
+	if err := sim.AddDevice(ZCAN_USBCANFD_200U, 0, "SIM0000"); err != nil {
+		t.Fatalf("AddDevice failed: %v", err)
+	}
+	drv := &failingInit{Simulator: sim}
+	zc := newZCAN(drv, nil)
+	handle := zc.OpenDevice(ZCAN_USBCANFD_200U, 0, 0)
+	ch, err := zc.OpenChannel(handle, 0)
+	if err != nil {
+		t.Fatalf("OpenChannel failed: %v", err)
+	}
+	events := make(chan SupervisorEvent, 16)
+	s := NewSupervisor(ch, WithPollInterval(time.Millisecond), WithRecoveryBackoff(time.Millisecond, 4*time.Millisecond),
+		WithRecoveryRetries(3), WithSupervisorEvents(events))
+	s.Start()
+	defer s.Stop()
+
+	// Bus-off is detected, the channel is reset and brought up again.
+	sim.SetBusOff(ZCAN_USBCANFD_200U, 0, 0)
+	if e := nextSupervisorEvent(t, events); e.Type != SupervisorStateChanged || e.From != ControllerErrorActive || e.To != ControllerBusOff {
+		t.Fatalf("Expected the change to bus-off, got %+v", e)
+	}
+	if e := nextSupervisorEvent(t, events); e.Type != SupervisorRecoveryAttempt || e.Attempt != 1 || e.Err != nil {
+		t.Fatalf("Expected a successful first attempt, got %+v", e)
+	}
+	if e := nextSupervisorEvent(t, events); e.Type != SupervisorStateChanged || e.From != ControllerBusOff || e.To != ControllerErrorActive {
+		t.Fatalf("Expected the change to error active, got %+v", e)
+	}
+	if e := nextSupervisorEvent(t, events); e.Type != SupervisorRecovered || e.Attempt != 1 {
+		t.Fatalf("Expected recovered, got %+v", e)
+	}
+	if n := drv.resets.Load(); n != 1 {
+		t.Fatalf("Expected one reset, got %d", n)
+	}
+	if status, err := zc.ReadChannelStatus(ch.Handle()); err != nil || status.Decode().State != ControllerErrorActive {
+		t.Fatalf("Expected the channel error active, got %v %v", status.Decode(), err)
+	}
+	msgs := []ZCAN_TransmitFD_Data{newFDTransmit(0x100, ZCAN_TX_NORMAL)}
+	if sent := zc.TransmitFD(ch.Handle(), msgs, 1); sent != 1 {
+		t.Fatalf("Expected the restarted channel to send, got %d", sent)
+	}
+
+	// Every bring-up fails until the retries are used up.
+	drv.fail.Store(true)
+	sim.SetBusOff(ZCAN_USBCANFD_200U, 0, 0)
+	if e := nextSupervisorEvent(t, events); e.Type != SupervisorStateChanged || e.To != ControllerBusOff {
+		t.Fatalf("Expected the change to bus-off, got %+v", e)
+	}
+	for attempt := 1; attempt <= 3; attempt++ {
+		if e := nextSupervisorEvent(t, events); e.Type != SupervisorRecoveryAttempt || e.Attempt != attempt || e.Err == nil {
+			t.Fatalf("Expected failed attempt %d, got %+v", attempt, e)
+		}
+	}
+	if e := nextSupervisorEvent(t, events); e.Type != SupervisorGaveUp || e.Attempt != 3 || !errors.Is(e.Err, ErrRecoveryGaveUp) {
+		t.Fatalf("Expected giving up after 3 attempts, got %+v", e)
+	}
+	// One reset per attempt; the failed initializations leave nothing to reset.
+	if n := drv.resets.Load(); n != 4 {
+		t.Fatalf("Expected a reset per attempt, got %d", n)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.mu.Lock()
+		running := s.running
+		s.mu.Unlock()
+		if !running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the supervisor stopped after giving up")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// It can be started again.
+	drv.fail.Store(false)
+	s.Start()
+	if e := nextSupervisorEvent(t, events); e.Type != SupervisorStateChanged || e.From != ControllerBusOff || e.To != ControllerErrorActive {
+		t.Fatalf("Expected the restarted supervisor to poll, got %+v", e)
+	}
+}
@@ -0,0 +1,269 @@
+package zlgcan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"unsafe"
+)
+
+var ErrTraceMismatch = errors.New("call not in trace")
+
+// replayKeys are the arguments a call must share with a recorded call to be answered by it.
+var replayKeys = []string{"handle", "device_type", "device_index", "can_index", "can_type", "iproperty", "path"}
+
+// Replay answers library calls from a trace written by WithTrace, to reproduce a recorded
+// session without hardware. Each call is answered by the earliest unplayed recorded call
+// with the same function and the same handle, device, channel index, CAN type and property
+// path, so calls interleaved differently by several goroutines still match. Data sent is
+// not compared; calls that match nothing fail and are reported by Err.
+type Replay struct {
+	mu     sync.Mutex
+	calls  []TraceCall
+	args   []map[string]any
+	played []bool
+	first  int // index of the earliest unplayed call
+	err    error
+
+	ips map[int]*ZCAN_IProperty
+}
+
+// NewReplay reads the trace from r.
+func NewReplay(r io.Reader) (*Replay, error) {
+	calls, err := ReadTrace(r)
+	if err != nil {
+		return nil, err
+	}
+	rp := &Replay{
+		calls:  calls,
+		args:   make([]map[string]any, len(calls)),
+		played: make([]bool, len(calls)),
+		ips:    make(map[int]*ZCAN_IProperty),
+	}
+	for i, call := range calls {
+		if len(call.Args) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(call.Args, &rp.args[i]); err != nil {
+			return nil, fmt.Errorf("reading trace call %d arguments: %w", call.Seq, err)
+		}
+	}
+	return rp, nil
+}
+
+// NewReplayZCAN returns a ZCAN backed by rp.
+func NewReplayZCAN(rp *Replay, opts ...ZCANOption) *ZCAN {
+	return newZCAN(rp, opts)
+}
+
+// Err returns the first call that was not found in the trace.
+func (rp *Replay) Err() error {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	return rp.err
+}
+
+// Remaining returns the number of recorded calls not played yet.
+func (rp *Replay) Remaining() int {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	n := 0
+	for _, played := range rp.played {
+		if !played {
+			n++
+		}
+	}
+	return n
+}
+
+// next plays the recorded call matching function and args and returns its result. A call
+// without a match returns the zero result, which the library uses for failure.
+func (rp *Replay) next(function string, args traceArgs) traceResult {
+	// Round trip the arguments so they compare like the decoded recorded ones.
+	var want map[string]any
+	if data, err := json.Marshal(args); err == nil {
+		json.Unmarshal(data, &want)
+	}
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	for i := rp.first; i < len(rp.calls); i++ {
+		if rp.played[i] || rp.calls[i].Function != function || !replayMatch(rp.args[i], want) {
+			continue
+		}
+		rp.played[i] = true
+		for rp.first < len(rp.played) && rp.played[rp.first] {
+			rp.first++
+		}
+		var result traceResult
+		if len(rp.calls[i].Result) > 0 {
+			if err := json.Unmarshal(rp.calls[i].Result, &result); err != nil && rp.err == nil {
+				rp.err = fmt.Errorf("reading trace call %d result: %w", rp.calls[i].Seq, err)
+			}
+		}
+		return result
+	}
+	if rp.err == nil {
+		rp.err = fmt.Errorf("%w: %s %v", ErrTraceMismatch, function, want)
+	}
+	return traceResult{}
+}
+
+func replayMatch(recorded, want map[string]any) bool {
+	for _, key := range replayKeys {
+		if fmt.Sprint(recorded[key]) != fmt.Sprint(want[key]) {
+			return false
+		}
+	}
+	return true
+}
+
+// iproperty returns the pointer standing for the trace number id.
+func (rp *Replay) iproperty(id int) *ZCAN_IProperty {
+	if id == 0 {
+		return nil
+	}
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	ip, ok := rp.ips[id]
+	if !ok {
+		ip = &ZCAN_IProperty{}
+		rp.ips[id] = ip
+	}
+	return ip
+}
+
+// ipropertyID returns the trace number of a pointer handed out by GetIProperty.
+func (rp *Replay) ipropertyID(iproperty *ZCAN_IProperty) int {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	for id, ip := range rp.ips {
+		if ip == iproperty {
+			return id
+		}
+	}
+	return 0
+}
+
+func (rp *Replay) OpenDevice(deviceType int, deviceIndex int, reserved int) int {
+	return int(rp.next("ZCAN_OpenDevice", traceArgs{"device_type": deviceType, "device_index": deviceIndex, "reserved": reserved}).Ret)
+}
+
+func (rp *Replay) CloseDevice(deviceHandle int) int {
+	return int(rp.next("ZCAN_CloseDevice", traceArgs{"handle": deviceHandle}).Ret)
+}
+
+func (rp *Replay) GetDeviceInf(deviceHandle int, info *ZCAN_DEVICE_INFO) uint {
+	result := rp.next("ZCAN_GetDeviceInf", traceArgs{"handle": deviceHandle})
+	if result.Info != nil {
+		*info = result.Info.deviceInfo()
+	}
+	return uint(result.Ret)
+}
+
+func (rp *Replay) IsDeviceOnLine(deviceHandle int) int {
+	return int(rp.next("ZCAN_IsDeviceOnLine", traceArgs{"handle": deviceHandle}).Ret)
+}
+
+func (rp *Replay) InitCAN(deviceHandle int, canIndex uint, initConfig *ZCAN_NORMAL_CHANNEL_INIT_CONFIG) int {
+	return int(rp.next("ZCAN_InitCAN", traceArgs{"handle": deviceHandle, "can_index": canIndex}).Ret)
+}
+
+func (rp *Replay) InitCANFD(deviceHandle int, canIndex uint, initConfig *ZCAN_CANFD_CHANNEL_INIT_CONFIG) int {
+	return int(rp.next("ZCAN_InitCANFD", traceArgs{"handle": deviceHandle, "can_index": canIndex}).Ret)
+}
+
+func (rp *Replay) StartCAN(channelHandle int) uint {
+	return uint(rp.next("ZCAN_StartCAN", traceArgs{"handle": channelHandle}).Ret)
+}
+
+func (rp *Replay) ResetCAN(channelHandle int) uint {
+	return uint(rp.next("ZCAN_ResetCAN", traceArgs{"handle": channelHandle}).Ret)
+}
+
+func (rp *Replay) ClearBuffer(channelHandle int) uint {
+	return uint(rp.next("ZCAN_ClearBuffer", traceArgs{"handle": channelHandle}).Ret)
+}
+
+func (rp *Replay) ReadChannelErrInfo(channelHandle int, errInfo *ZCAN_CHANNEL_ERR_INFO) uint {
+	result := rp.next("ZCAN_ReadChannelErrInfo", traceArgs{"handle": channelHandle})
+	if result.ErrInfo != nil {
+		*errInfo = *result.ErrInfo
+	}
+	return uint(result.Ret)
+}
+
+func (rp *Replay) ReadChannelStatus(channelHandle int, status *ZCAN_CHANNEL_STATUS) uint {
+	result := rp.next("ZCAN_ReadChannelStatus", traceArgs{"handle": channelHandle})
+	if result.Status != nil {
+		*status = *result.Status
+	}
+	return uint(result.Ret)
+}
+
+func (rp *Replay) GetReceiveNum(channelHandle int, canType uint) uint {
+	return uint(rp.next("ZCAN_GetReceiveNum", traceArgs{"handle": channelHandle, "can_type": canType}).Ret)
+}
+
+func (rp *Replay) Transmit(channelHandle int, msgs []ZCAN_Transmit_Data, n uint) uint {
+	return uint(rp.next("ZCAN_Transmit", traceArgs{"handle": channelHandle}).Ret)
+}
+
+func (rp *Replay) Receive(channelHandle int, msgs []ZCAN_Receive_Data, waitTime int) uint {
+	result := rp.next("ZCAN_Receive", traceArgs{"handle": channelHandle})
+	return uint(copy(msgs, result.Msgs))
+}
+
+func (rp *Replay) TransmitFD(channelHandle int, msgs []ZCAN_TransmitFD_Data, n uint) uint {
+	return uint(rp.next("ZCAN_TransmitFD", traceArgs{"handle": channelHandle}).Ret)
+}
+
+func (rp *Replay) ReceiveFD(channelHandle int, msgs []ZCAN_ReceiveFD_Data, waitTime int) uint {
+	result := rp.next("ZCAN_ReceiveFD", traceArgs{"handle": channelHandle})
+	return uint(copy(msgs, result.MsgsFD))
+}
+
+func (rp *Replay) TransmitData(deviceHandle int, objs []ZCAN_DATA_OBJ, n uint) uint {
+	return uint(rp.next("ZCAN_TransmitData", traceArgs{"handle": deviceHandle}).Ret)
+}
+
+func (rp *Replay) ReceiveData(deviceHandle int, objs []ZCAN_DATA_OBJ, waitTime int) uint {
+	result := rp.next("ZCAN_ReceiveData", traceArgs{"handle": deviceHandle})
+	return uint(copy(objs, result.Objs))
+}
+
+func (rp *Replay) GetIProperty(deviceHandle int) (*ZCAN_IProperty, error) {
+	result := rp.next("GetIProperty", traceArgs{"handle": deviceHandle})
+	if result.Error != "" {
+		return nil, errors.New(result.Error)
+	}
+	return rp.iproperty(result.IProperty), nil
+}
+
+func (rp *Replay) SetValue(iproperty *ZCAN_IProperty, path, value string) uint {
+	return uint(rp.next("SetValue", traceArgs{"iproperty": rp.ipropertyID(iproperty), "path": path}).Ret)
+}
+
+func (rp *Replay) SetValuePtr(iproperty *ZCAN_IProperty, path string, value unsafe.Pointer) uint {
+	return uint(rp.next("SetValuePtr", traceArgs{"iproperty": rp.ipropertyID(iproperty), "path": path}).Ret)
+}
+
+func (rp *Replay) GetValue(iproperty *ZCAN_IProperty, path string) string {
+	return rp.next("GetValue", traceArgs{"iproperty": rp.ipropertyID(iproperty), "path": path}).Value
+}
+
+func (rp *Replay) ReleaseIProperty(iproperty *ZCAN_IProperty) uint {
+	id := rp.ipropertyID(iproperty)
+	rp.mu.Lock()
+	delete(rp.ips, id)
+	rp.mu.Unlock()
+	return uint(rp.next("ReleaseIProperty", traceArgs{"iproperty": id}).Ret)
+}
+
+func (rp *Replay) Close() error {
+	if result := rp.next("Close", nil); result.Error != "" {
+		return errors.New(result.Error)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package zlgcan
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// Test playing a recorded session back without the simulator
+func TestReplay(t *testing.T) {
+	trace, recorded := recordSession(t)
+	rp, err := NewReplay(bytes.NewReader(trace))
+	if err != nil {
+		t.Fatalf("NewReplay failed: %v", err)
+	}
+	zc := NewReplayZCAN(rp)
+	replayed := traceSession(t, zc)
+	if err := zc.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if !slices.Equal(recorded, replayed) {
+		t.Fatalf("Replay observed %v, the recording %v", replayed, recorded)
+	}
+	if err := rp.Err(); err != nil {
+		t.Fatalf("Unexpected replay error: %v", err)
+	}
+	if n := rp.Remaining(); n != 0 {
+		t.Fatalf("Expected the whole trace played, %d calls left", n)
+	}
+}
+
+// Test calls that are not in the trace
+func TestReplayMismatch(t *testing.T) {
+	trace, _ := recordSession(t)
+	rp, err := NewReplay(bytes.NewReader(trace))
+	if err != nil {
+		t.Fatalf("NewReplay failed: %v", err)
+	}
+	zc := NewReplayZCAN(rp)
+	if handle := zc.OpenDevice(ZCAN_USBCANFD_200U, 1, 0); handle != INVALID_DEVICE_HANDLE {
+		t.Fatalf("Expected opening another device to fail, got handle %d", handle)
+	}
+	if !errors.Is(rp.Err(), ErrTraceMismatch) {
+		t.Fatalf("Expected ErrTraceMismatch, got %v", rp.Err())
+	}
+	if handle := zc.OpenDevice(ZCAN_USBCANFD_200U, 0, 0); handle == INVALID_DEVICE_HANDLE {
+		t.Fatalf("Expected the recorded device to open")
+	}
+	if _, err := NewReplay(bytes.NewReader([]byte("{bad"))); err == nil {
+		t.Fatalf("Expected a broken trace to be rejected")
+	}
+}
+
+// Test that property calls are answered by the recording of the same property interface
+func TestReplayIProperty(t *testing.T) {
+	var trace bytes.Buffer
+	sim := NewSimulator()
+	for i, serial := range []string{"SIM0000", "SIM0001"} {
+		if err := sim.AddDevice(ZCAN_USBCANFD_200U, i, serial); err != nil {
+			t.Fatalf("AddDevice failed: %v", err)
+		}
+	}
+	zc := NewSimulatedZCAN(sim, WithTrace(&trace))
+	var ips []*ZCAN_IProperty
+	for i, value := range []string{"first", "second"} {
+		handle := zc.OpenDevice(ZCAN_USBCANFD_200U, i, 0)
+		ip, err := zc.GetIProperty(handle)
+		if err != nil {
+			t.Fatalf("GetIProperty failed: %v", err)
+		}
+		zc.SetValue(ip, "0/name", value)
+		ips = append(ips, ip)
+	}
+	for _, ip := range ips {
+		zc.GetValue(ip, "0/name")
+	}
+
+	rp, err := NewReplay(bytes.NewReader(trace.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReplay failed: %v", err)
+	}
+	zc = NewReplayZCAN(rp)
+	ips = ips[:0]
+	for i := range 2 {
+		ip, err := zc.GetIProperty(zc.OpenDevice(ZCAN_USBCANFD_200U, i, 0))
+		if err != nil {
+			t.Fatalf("GetIProperty failed: %v", err)
+		}
+		ips = append(ips, ip)
+	}
+	// Played in another order than recorded, each value still comes from its own device.
+	if got := zc.GetValue(ips[1], "0/name"); got != "second" {
+		t.Fatalf("Expected the value of the second device, got %q", got)
+	}
+	if got := zc.GetValue(ips[0], "0/name"); got != "first" {
+		t.Fatalf("Expected the value of the first device, got %q", got)
+	}
+	if err := rp.Err(); err != nil {
+		t.Fatalf("Unexpected replay error: %v", err)
+	}
+}
+
+// Test that two holders of a shared iproperty are traced under one number until the last
+// one releases it
+func TestReplaySharedIProperty(t *testing.T) {
+	var trace bytes.Buffer
+	sim := NewSimulator()
+	if err := sim.AddDevice(ZCAN_USBCANFD_200U, 0, "SIM0000"); err != nil {
+		t.Fatalf("AddDevice failed: %v", err)
+	}
+	zc := newZCAN(&sharedIProperty{Simulator: sim}, []ZCANOption{WithTrace(&trace)})
+	run := func(zc *ZCAN) []string {
+		handle := zc.OpenDevice(ZCAN_USBCANFD_200U, 0, 0)
+		first, _ := zc.GetIProperty(handle)
+		second, _ := zc.GetIProperty(handle)
+		zc.SetValue(second, "0/name", "shared")
+		zc.ReleaseIProperty(second)
+		got := []string{zc.GetValue(first, "0/name")}
+		zc.ReleaseIProperty(first)
+		third, _ := zc.GetIProperty(handle)
+		got = append(got, zc.GetValue(third, "0/name"))
+		zc.ReleaseIProperty(third)
+		return got
+	}
+	if got := run(zc); got[0] != "shared" || got[1] != "shared" {
+		t.Fatalf("Unexpected values %q", got)
+	}
+
+	calls, err := ReadTrace(bytes.NewReader(trace.Bytes()))
+	if err != nil {
+		t.Fatalf("ReadTrace failed: %v", err)
+	}
+	var ids []int
+	for _, c := range calls {
+		raw := c.Args
+		switch c.Function {
+		case "GetIProperty":
+			raw = c.Result
+		case "SetValue", "GetValue", "ReleaseIProperty":
+		default:
+			continue
+		}
+		var v struct {
+			IProperty int `json:"iproperty"`
+		}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			t.Fatalf("Decoding %s failed: %v", c.Function, err)
+		}
+		ids = append(ids, v.IProperty)
+	}
+	// Both holders share number 1 up to the last release, which is the only one reaching
+	// the driver; the next GetIProperty gets 2.
+	if want := []int{1, 1, 1, 1, 1, 2, 2, 2}; fmt.Sprint(ids) != fmt.Sprint(want) {
+		t.Fatalf("Traced iproperty numbers %v, want %v", ids, want)
+	}
+
+	rp, err := NewReplay(bytes.NewReader(trace.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReplay failed: %v", err)
+	}
+	if got := run(NewReplayZCAN(rp)); got[0] != "shared" || got[1] != "shared" {
+		t.Fatalf("Unexpected replayed values %q", got)
+	}
+	if err := rp.Err(); err != nil {
+		t.Fatalf("Unexpected replay error: %v", err)
+	}
+}
@@ -0,0 +1,299 @@
+package zlgcan
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+var ErrUnknownMessage = errors.New("unknown periodic message")
+
+// PeriodicMessage is a frame sent every Period by a Scheduler. Exactly one of CAN and FD must be set.
+type PeriodicMessage struct {
+	Period time.Duration
+	CAN    *ZCAN_Transmit_Data
+	FD     *ZCAN_TransmitFD_Data
+	// Update, if set, is called before every send with the frame about to go out and
+	// the number of previous sends, e.g. to bump a counter or recompute a checksum.
+	// It runs on the scheduler goroutine and must not call back into the Scheduler.
+	Update func(frame TxFrame, count uint64)
+}
+
+// JitterStats summarizes how far actual sends were from their schedule.
+type JitterStats struct {
+	Sent    uint64
+	Missed  uint64 // periods skipped because the scheduler fell behind
+	Dropped uint64 // frames the driver did not accept
+	Min     time.Duration
+	Max     time.Duration
+	Mean    time.Duration
+	StdDev  time.Duration
+}
+
+type scheduledMessage struct {
+	msg   PeriodicMessage
+	can   ZCAN_Transmit_Data
+	fd    ZCAN_TransmitFD_Data
+	next  time.Time
+	count uint64
+
+	stats      JitterStats
+	mean, m2   float64 // Welford running mean and squared deviation, in ns
+	minJ, maxJ time.Duration
+}
+
+func (sm *scheduledMessage) record(jitter time.Duration) {
+	sm.stats.Sent++
+	if sm.stats.Sent == 1 || jitter < sm.minJ {
+		sm.minJ = jitter
+	}
+	if sm.stats.Sent == 1 || jitter > sm.maxJ {
+		sm.maxJ = jitter
+	}
+	delta := float64(jitter) - sm.mean
+	sm.mean += delta / float64(sm.stats.Sent)
+	sm.m2 += delta * (float64(jitter) - sm.mean)
+}
+
+func (sm *scheduledMessage) snapshot() JitterStats {
+	stats := sm.stats
+	stats.Min, stats.Max = sm.minJ, sm.maxJ
+	stats.Mean = time.Duration(sm.mean)
+	if stats.Sent > 1 {
+		stats.StdDev = time.Duration(math.Sqrt(sm.m2 / float64(stats.Sent-1)))
+	}
+	return stats
+}
+
+// Scheduler sends periodic messages in software through Transmit/TransmitFD, for devices
+// without hardware auto-send or when more cyclic messages are needed than it has slots.
+// Due frames are batched into one driver call per tick. Each message is scheduled on its
+// own time grid, so late sends do not accumulate drift.
+type Scheduler struct {
+	ch   *Channel
+	tick time.Duration
+
+	mu      sync.Mutex
+	msgs    map[int]*scheduledMessage
+	nextID  int
+	running bool
+	wake    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewScheduler creates a stopped scheduler for ch. tick is the batching granularity;
+// frames due within the same tick are sent together.
+func NewScheduler(ch *Channel, tick time.Duration) *Scheduler {
+	if tick <= 0 {
+		tick = time.Millisecond
+	}
+	return &Scheduler{ch: ch, tick: tick, msgs: make(map[int]*scheduledMessage), wake: make(chan struct{}, 1)}
+}
+
+func (s *Scheduler) poke() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func checkPeriodicMessage(m PeriodicMessage) error {
+	if (m.CAN == nil) == (m.FD == nil) {
+		return ErrTxInvalid
+	}
+	if m.Period <= 0 {
+		return fmt.Errorf("invalid period %s", m.Period)
+	}
+	return nil
+}
+
+// Add schedules m and returns its id. The first send is due immediately.
+func (s *Scheduler) Add(m PeriodicMessage) (int, error) {
+	if err := checkPeriodicMessage(m); err != nil {
+		return 0, err
+	}
+	sm := &scheduledMessage{msg: m, next: time.Now()}
+	if m.CAN != nil {
+		sm.can = *m.CAN
+	} else {
+		sm.fd = *m.FD
+	}
+	s.mu.Lock()
+	s.nextID++
+	id := s.nextID
+	s.msgs[id] = sm
+	s.mu.Unlock()
+	s.poke()
+	return id, nil
+}
+
+// Modify replaces the frame, period and update callback of message id. The next send
+// keeps its place on the current schedule.
+func (s *Scheduler) Modify(id int, m PeriodicMessage) error {
+	if err := checkPeriodicMessage(m); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sm, ok := s.msgs[id]
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrUnknownMessage, id)
+	}
+	sm.msg = m
+	if m.CAN != nil {
+		sm.can = *m.CAN
+	} else {
+		sm.fd = *m.FD
+	}
+	s.poke()
+	return nil
+}
+
+// Remove stops sending message id.
+func (s *Scheduler) Remove(id int) {
+	s.mu.Lock()
+	delete(s.msgs, id)
+	s.mu.Unlock()
+}
+
+// Stats returns the jitter statistics of message id.
+func (s *Scheduler) Stats(id int) (JitterStats, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sm, ok := s.msgs[id]
+	if !ok {
+		return JitterStats{}, false
+	}
+	return sm.snapshot(), true
+}
+
+// Start begins sending. Messages due while the scheduler was stopped are sent at once.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return
+	}
+	s.running = true
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	now := time.Now()
+	for _, sm := range s.msgs {
+		if sm.next.Before(now) {
+			sm.next = now
+		}
+	}
+	go s.run(s.stop, s.done)
+}
+
+// Stop halts sending and waits for the scheduler goroutine to exit.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stop)
+	done := s.done
+	s.mu.Unlock()
+	<-done
+}
+
+type dueMessage struct {
+	sm        *scheduledMessage
+	scheduled time.Time
+}
+
+// collect advances every message due before horizon and returns copies of their frames.
+func (s *Scheduler) collect(now, horizon time.Time) ([]dueMessage, []ZCAN_Transmit_Data, []dueMessage, []ZCAN_TransmitFD_Data, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due, dueFD []dueMessage
+	var can []ZCAN_Transmit_Data
+	var fd []ZCAN_TransmitFD_Data
+	var earliest time.Time
+	for _, sm := range s.msgs {
+		if !sm.next.After(horizon) {
+			scheduled := sm.next
+			frame := TxFrame{CAN: &sm.can}
+			if sm.msg.FD != nil {
+				frame = TxFrame{FD: &sm.fd}
+			}
+			if sm.msg.Update != nil {
+				sm.msg.Update(frame, sm.count)
+			}
+			sm.count++
+			if frame.FD != nil {
+				dueFD = append(dueFD, dueMessage{sm, scheduled})
+				fd = append(fd, sm.fd)
+			} else {
+				due = append(due, dueMessage{sm, scheduled})
+				can = append(can, sm.can)
+			}
+			// Stay on the grid; periods that have already passed are skipped, not sent in a burst.
+			// A period due exactly now is still on time.
+			sm.next = sm.next.Add(sm.msg.Period)
+			if behind := now.Sub(sm.next); behind > 0 {
+				skipped := uint64((behind + sm.msg.Period - 1) / sm.msg.Period)
+				sm.stats.Missed += skipped
+				sm.next = sm.next.Add(time.Duration(skipped) * sm.msg.Period)
+			}
+		}
+		if earliest.IsZero() || sm.next.Before(earliest) {
+			earliest = sm.next
+		}
+	}
+	return due, can, dueFD, fd, earliest
+}
+
+func (s *Scheduler) account(due []dueMessage, sent int, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, d := range due {
+		if i < sent {
+			d.sm.record(at.Sub(d.scheduled))
+		} else {
+			d.sm.stats.Dropped++
+		}
+	}
+}
+
+func (s *Scheduler) run(stop, done chan struct{}) {
+	defer close(done)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-s.wake:
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		due, can, dueFD, fd, earliest := s.collect(now, now.Add(s.tick/2))
+		if len(can) > 0 {
+			sent := int(s.ch.zc.Transmit(s.ch.Handle(), can, uint(len(can))))
+			s.account(due, sent, time.Now())
+		}
+		if len(fd) > 0 {
+			sent := int(s.ch.zc.TransmitFD(s.ch.Handle(), fd, uint(len(fd))))
+			s.account(dueFD, sent, time.Now())
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if earliest.IsZero() {
+			continue // nothing scheduled, wait for Add
+		}
+		timer.Reset(max(time.Until(earliest), 0))
+	}
+}
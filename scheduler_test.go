@@ -0,0 +1,115 @@
+package zlgcan
+
+import (
+	"testing"
+	"time"
+)
+
+// Test that due frames are collected on their own grid and late periods are skipped
+func TestSchedulerCollect(t *testing.T) {
+	s := NewScheduler(&Channel{}, time.Millisecond)
+	msg := &ZCAN_Transmit_Data{}
+	msg.Frame.Dlc = 1
+	id, err := s.Add(PeriodicMessage{
+		Period: 10 * time.Millisecond,
+		CAN:    msg,
+		Update: func(frame TxFrame, count uint64) {
+			frame.CAN.Frame.Data[0] = uint8(count)
+		},
+	})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	start := s.msgs[id].next
+
+	due, can, _, fd, next := s.collect(start, start)
+	if len(due) != 1 || len(can) != 1 || len(fd) != 0 {
+		t.Fatalf("Expected one due CAN frame, got %d/%d", len(can), len(fd))
+	}
+	if can[0].Frame.Data[0] != 0 || !next.Equal(start.Add(10*time.Millisecond)) {
+		t.Fatalf("Unexpected first send: data %d, next %s", can[0].Frame.Data[0], next.Sub(start))
+	}
+	s.account(due, 1, start.Add(time.Millisecond))
+
+	now := start.Add(35 * time.Millisecond)
+	due, can, _, _, next = s.collect(now, now)
+	if len(can) != 1 || can[0].Frame.Data[0] != 1 {
+		t.Fatalf("Expected the second send with counter 1, got %+v", can)
+	}
+	if !next.Equal(start.Add(40 * time.Millisecond)) {
+		t.Fatalf("Next send should stay on the 10ms grid, got %s", next.Sub(start))
+	}
+	s.account(due, 0, now)
+
+	stats, ok := s.Stats(id)
+	if !ok || stats.Sent != 1 || stats.Missed != 2 || stats.Dropped != 1 || stats.Max != time.Millisecond {
+		t.Fatalf("Unexpected stats: %+v", stats)
+	}
+
+	// Falling behind by whole periods skips them and keeps the one due now.
+	now = start.Add(60 * time.Millisecond)
+	s.collect(now, now)
+	if stats, _ := s.Stats(id); stats.Missed != 3 || !s.msgs[id].next.Equal(now) {
+		t.Fatalf("Expected 50ms missed and 60ms due, got %+v next %s", stats, s.msgs[id].next.Sub(start))
+	}
+	s.collect(now, now)
+	if stats, _ := s.Stats(id); stats.Missed != 3 || !s.msgs[id].next.Equal(start.Add(70*time.Millisecond)) {
+		t.Fatalf("A send due exactly now should not count as missed, got %+v", stats)
+	}
+
+	if _, err := s.Add(PeriodicMessage{Period: time.Second}); err == nil {
+		t.Fatalf("Message without frame should be rejected")
+	}
+}
+
+// Test a running scheduler sending on a simulated channel
+func TestSchedulerRun(t *testing.T) {
+	sim := NewSimulator()
+	zc, handle := newSimulatedDevice(t, sim, ZCAN_USBCANFD_200U, 0)
+	tx, err := zc.OpenChannel(handle, 0)
+	if err != nil {
+		t.Fatalf("OpenChannel failed: %v", err)
+	}
+	rx, err := zc.OpenChannel(handle, 1)
+	if err != nil {
+		t.Fatalf("OpenChannel failed: %v", err)
+	}
+
+	s := NewScheduler(tx, time.Millisecond)
+	fd := newFDTransmit(0x321, ZCAN_TX_NORMAL)
+	id, err := s.Add(PeriodicMessage{
+		Period: 5 * time.Millisecond,
+		FD:     &fd,
+		Update: func(frame TxFrame, count uint64) {
+			frame.FD.Frame.Data[0] = uint8(count)
+		},
+	})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	s.Start()
+	s.Start()
+	time.Sleep(60 * time.Millisecond)
+	s.Stop()
+	s.Stop()
+
+	stats, _ := s.Stats(id)
+	if stats.Sent < 3 || stats.Dropped != 0 {
+		t.Fatalf("Expected several sends without drops, got %+v", stats)
+	}
+	rcv, n := rx.ReceiveFD(100, 0)
+	if uint64(n) != stats.Sent {
+		t.Fatalf("Expected %d frames received, got %d", stats.Sent, n)
+	}
+	for i, msg := range rcv[:n] {
+		if msg.Frame.GetFrameID() != 0x321 || msg.Frame.Data[0] != uint8(i) {
+			t.Fatalf("Unexpected frame %d: id 0x%x counter %d", i, msg.Frame.GetFrameID(), msg.Frame.Data[0])
+		}
+	}
+
+	// Nothing is sent while stopped.
+	time.Sleep(20 * time.Millisecond)
+	if after, _ := s.Stats(id); after.Sent != stats.Sent {
+		t.Fatalf("Expected no sends after Stop, got %d more", after.Sent-stats.Sent)
+	}
+}
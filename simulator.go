@@ -0,0 +1,572 @@
+package zlgcan
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// simRxCapacity is the number of frames a simulated channel buffers per frame type.
+const simRxCapacity = 100000
+
+// Simulator is an in-memory stand-in for the vendor library, for tests and development
+// without hardware. Every started channel of every simulated device sits on one shared bus:
+// frames sent on a channel are received by all other started channels, loopback channels
+// only receive their own frames. Merged data (ZCAN_TransmitData/ZCAN_ReceiveData) is not
+// simulated.
+//
+// Like the real library, the simulator must not be used concurrently for receiving on one
+// channel or for property access on one device; such overlapping calls are recorded and
+// reported by Violations.
+type Simulator struct {
+	mu         sync.Mutex
+	start      time.Time
+	next       int
+	devices    []*simDevice
+	handles    map[int]*simDevice
+	channels   map[int]*simChannel
+	ips        map[*ZCAN_IProperty]*simDevice
+	violations []string
+}
+
+type simDevice struct {
+	deviceType  int
+	deviceIndex int
+	serial      string
+	canNum      uint8
+	online      bool
+	handle      int
+	props       map[string]string
+	channels    map[uint]*simChannel
+	propBusy    atomic.Int32
+}
+
+type simChannel struct {
+	dev     *simDevice
+	index   uint
+	handle  int
+	canType uint32
+	mode    ChannelMode
+	started bool
+	busOff  bool
+	errInfo ZCAN_CHANNEL_ERR_INFO
+	rx      []ZCAN_Receive_Data
+	rxFD    []ZCAN_ReceiveFD_Data
+	notify  chan struct{} // closed and replaced when frames arrive or the channel is reset
+	rxBusy  atomic.Int32
+}
+
+// NewSimulator creates a simulator without devices.
+func NewSimulator() *Simulator {
+	return &Simulator{
+		start:    time.Now(),
+		handles:  make(map[int]*simDevice),
+		channels: make(map[int]*simChannel),
+		ips:      make(map[*ZCAN_IProperty]*simDevice),
+	}
+}
+
+// NewSimulatedZCAN returns a ZCAN backed by sim.
+func NewSimulatedZCAN(sim *Simulator, opts ...ZCANOption) *ZCAN {
+	return newZCAN(sim, opts)
+}
+
+// AddDevice plugs in a device. Its channel count is taken from the device spec.
+func (s *Simulator) AddDevice(deviceType int, deviceIndex int, serial string) error {
+	spec, ok := GetDeviceSpec(deviceType)
+	if !ok {
+		return fmt.Errorf("%w: 0x%x", ErrUnknownDevice, deviceType)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.find(deviceType, deviceIndex) != nil {
+		return fmt.Errorf("device type 0x%x index %d already added", deviceType, deviceIndex)
+	}
+	s.devices = append(s.devices, &simDevice{
+		deviceType:  deviceType,
+		deviceIndex: deviceIndex,
+		serial:      serial,
+		canNum:      spec.Channels,
+		online:      true,
+	})
+	return nil
+}
+
+func (s *Simulator) find(deviceType int, deviceIndex int) *simDevice {
+	for _, dev := range s.devices {
+		if dev.deviceType == deviceType && dev.deviceIndex == deviceIndex {
+			return dev
+		}
+	}
+	return nil
+}
+
+// SetOnline unplugs (false) or replugs (true) a device. Unplugging stops its channels; the
+// device handle stays allocated, reporting ZCAN_STATUS_OFFLINE, until it is closed.
+func (s *Simulator) SetOnline(deviceType int, deviceIndex int, online bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dev := s.find(deviceType, deviceIndex)
+	if dev == nil {
+		return
+	}
+	dev.online = online
+	if !online {
+		for _, c := range dev.channels {
+			s.resetChannel(c)
+		}
+	}
+}
+
+// SetBusOff puts channel canIndex of a device into bus-off until it is reset.
+func (s *Simulator) SetBusOff(deviceType int, deviceIndex int, canIndex uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if dev := s.find(deviceType, deviceIndex); dev != nil {
+		if c, ok := dev.channels[canIndex]; ok {
+			c.busOff = true
+			c.errInfo.ErrorCode |= uint32(ZCAN_ERROR_CAN_BUSOFF)
+		}
+	}
+}
+
+// Property returns the last value set for path on a device.
+func (s *Simulator) Property(deviceType int, deviceIndex int, path string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dev := s.find(deviceType, deviceIndex)
+	if dev == nil {
+		return "", false
+	}
+	value, ok := dev.props[path]
+	return value, ok
+}
+
+// Violations returns the overlapping calls the real library does not allow.
+func (s *Simulator) Violations() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.violations...)
+}
+
+// enter marks a call that must not overlap with others on the same object. Yielding
+// widens the window in which an overlapping call is noticed.
+func (s *Simulator) enter(busy *atomic.Int32, what string) func() {
+	if busy.Add(1) != 1 {
+		s.mu.Lock()
+		s.violations = append(s.violations, what)
+		s.mu.Unlock()
+	}
+	runtime.Gosched()
+	return func() { busy.Add(-1) }
+}
+
+func (s *Simulator) timestamp() uint64 {
+	return uint64(time.Since(s.start) / time.Microsecond)
+}
+
+func (s *Simulator) channel(channelHandle int) *simChannel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.channels[channelHandle]
+	if !ok || !c.dev.online || c.dev.handle == INVALID_DEVICE_HANDLE {
+		return nil
+	}
+	return c
+}
+
+func (s *Simulator) wake(c *simChannel) {
+	close(c.notify)
+	c.notify = make(chan struct{})
+}
+
+func (s *Simulator) resetChannel(c *simChannel) {
+	c.started = false
+	c.busOff = false
+	c.rx, c.rxFD = nil, nil
+	s.wake(c)
+}
+
+func (s *Simulator) OpenDevice(deviceType int, deviceIndex int, reserved int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dev := s.find(deviceType, deviceIndex)
+	if dev == nil || !dev.online || dev.handle != INVALID_DEVICE_HANDLE {
+		return INVALID_DEVICE_HANDLE
+	}
+	s.next++
+	dev.handle = s.next
+	dev.props = make(map[string]string)
+	dev.channels = make(map[uint]*simChannel)
+	s.handles[dev.handle] = dev
+	return dev.handle
+}
+
+func (s *Simulator) CloseDevice(deviceHandle int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dev, ok := s.handles[deviceHandle]
+	if !ok {
+		return ZCAN_STATUS_ERR
+	}
+	for _, c := range dev.channels {
+		s.resetChannel(c)
+		delete(s.channels, c.handle)
+	}
+	delete(s.handles, deviceHandle)
+	dev.handle = INVALID_DEVICE_HANDLE
+	return ZCAN_STATUS_OK
+}
+
+func (s *Simulator) GetDeviceInf(deviceHandle int, info *ZCAN_DEVICE_INFO) uint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dev, ok := s.handles[deviceHandle]
+	if !ok || !dev.online {
+		return ZCAN_STATUS_ERR
+	}
+	*info = ZCAN_DEVICE_INFO{hw_Version: 0x100, fw_Version: 0x100, dr_Version: 0x100, in_Version: 0x100, can_Num: dev.canNum}
+	copy(info.str_Serial_Num[:len(info.str_Serial_Num)-1], dev.serial)
+	if spec, ok := GetDeviceSpec(dev.deviceType); ok {
+		copy(info.str_hw_Type[:len(info.str_hw_Type)-1], spec.Name)
+	}
+	return ZCAN_STATUS_OK
+}
+
+func (s *Simulator) IsDeviceOnLine(deviceHandle int) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dev, ok := s.handles[deviceHandle]
+	switch {
+	case !ok:
+		return ZCAN_STATUS_ERR
+	case !dev.online:
+		return ZCAN_STATUS_OFFLINE
+	}
+	return ZCAN_STATUS_ONLINE
+}
+
+func (s *Simulator) initChannel(deviceHandle int, canIndex uint, canType uint32, mode ChannelMode) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dev, ok := s.handles[deviceHandle]
+	if !ok || !dev.online || canIndex >= uint(dev.canNum) {
+		return INVALID_CHANNEL_HANDLE
+	}
+	c, ok := dev.channels[canIndex]
+	if !ok {
+		s.next++
+		c = &simChannel{dev: dev, index: canIndex, handle: s.next, notify: make(chan struct{})}
+		dev.channels[canIndex] = c
+		s.channels[c.handle] = c
+	}
+	s.resetChannel(c)
+	c.canType, c.mode = canType, mode
+	return c.handle
+}
+
+func (s *Simulator) InitCAN(deviceHandle int, canIndex uint, initConfig *ZCAN_NORMAL_CHANNEL_INIT_CONFIG) int {
+	return s.initChannel(deviceHandle, canIndex, initConfig.CanType, initConfig.Config.Mode)
+}
+
+func (s *Simulator) InitCANFD(deviceHandle int, canIndex uint, initConfig *ZCAN_CANFD_CHANNEL_INIT_CONFIG) int {
+	return s.initChannel(deviceHandle, canIndex, initConfig.CanType, initConfig.Config.Mode)
+}
+
+func (s *Simulator) StartCAN(channelHandle int) uint {
+	c := s.channel(channelHandle)
+	if c == nil {
+		return ZCAN_STATUS_ERR
+	}
+	s.mu.Lock()
+	c.started = true
+	s.mu.Unlock()
+	return ZCAN_STATUS_OK
+}
+
+func (s *Simulator) ResetCAN(channelHandle int) uint {
+	c := s.channel(channelHandle)
+	if c == nil {
+		return ZCAN_STATUS_ERR
+	}
+	defer s.enter(&c.rxBusy, fmt.Sprintf("ResetCAN overlaps receive on channel %d", channelHandle))()
+	s.mu.Lock()
+	s.resetChannel(c)
+	s.mu.Unlock()
+	return ZCAN_STATUS_OK
+}
+
+func (s *Simulator) ClearBuffer(channelHandle int) uint {
+	c := s.channel(channelHandle)
+	if c == nil {
+		return ZCAN_STATUS_ERR
+	}
+	defer s.enter(&c.rxBusy, fmt.Sprintf("ClearBuffer overlaps receive on channel %d", channelHandle))()
+	s.mu.Lock()
+	c.rx, c.rxFD = nil, nil
+	s.mu.Unlock()
+	return ZCAN_STATUS_OK
+}
+
+func (s *Simulator) ReadChannelErrInfo(channelHandle int, errInfo *ZCAN_CHANNEL_ERR_INFO) uint {
+	c := s.channel(channelHandle)
+	if c == nil {
+		return ZCAN_STATUS_ERR
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*errInfo = c.errInfo
+	c.errInfo = ZCAN_CHANNEL_ERR_INFO{}
+	return ZCAN_STATUS_OK
+}
+
+func (s *Simulator) ReadChannelStatus(channelHandle int, status *ZCAN_CHANNEL_STATUS) uint {
+	c := s.channel(channelHandle)
+	if c == nil {
+		return ZCAN_STATUS_ERR
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*status = ZCAN_CHANNEL_STATUS{RegEWLimit: sja1000DefaultEWL}
+	if c.mode == ZCAN_MODE_LISTEN_ONLY {
+		status.RegMode |= sja1000ModeListenOnly
+	}
+	if c.busOff {
+		status.RegStatus = sja1000StatusBusOff | sja1000StatusError
+		status.RegTECounter = 127
+	}
+	return ZCAN_STATUS_OK
+}
+
+func (s *Simulator) GetReceiveNum(channelHandle int, canType uint) uint {
+	c := s.channel(channelHandle)
+	if c == nil {
+		return 0
+	}
+	defer s.enter(&c.rxBusy, fmt.Sprintf("GetReceiveNum overlaps receive on channel %d", channelHandle))()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if canType == ZCAN_TYPE_CANFD {
+		return uint(len(c.rxFD))
+	}
+	return uint(len(c.rx))
+}
+
+// receivers returns the channels a frame sent on c arrives at, except c itself.
+func (s *Simulator) receivers(c *simChannel, fd bool) []*simChannel {
+	if c.mode == ZCAN_MODE_LOOPBACK {
+		return nil
+	}
+	var peers []*simChannel
+	for _, p := range s.channels {
+		if p == c || !p.started || p.busOff || p.mode == ZCAN_MODE_LOOPBACK || !p.dev.online {
+			continue
+		}
+		if fd && p.canType != ZCAN_TYPE_CANFD {
+			continue
+		}
+		peers = append(peers, p)
+	}
+	return peers
+}
+
+func (s *Simulator) canSend(c *simChannel) bool {
+	return c.started && !c.busOff && c.mode != ZCAN_MODE_LISTEN_ONLY
+}
+
+func (s *Simulator) deliver(c *simChannel, frame ZCAN_CAN_FRAME, ts uint64) {
+	if len(c.rx) >= simRxCapacity {
+		c.errInfo.ErrorCode |= uint32(ZCAN_ERROR_CAN_BUFFER_OVERFLOW)
+		return
+	}
+	c.rx = append(c.rx, ZCAN_Receive_Data{Frame: frame, Timestamp: ts})
+}
+
+func (s *Simulator) deliverFD(c *simChannel, frame ZCAN_CANFD_FRAME, ts uint64) {
+	if len(c.rxFD) >= simRxCapacity {
+		c.errInfo.ErrorCode |= uint32(ZCAN_ERROR_CAN_BUFFER_OVERFLOW)
+		return
+	}
+	c.rxFD = append(c.rxFD, ZCAN_ReceiveFD_Data{Frame: frame, Timestamp: ts})
+}
+
+func (s *Simulator) Transmit(channelHandle int, msgs []ZCAN_Transmit_Data, n uint) uint {
+	c := s.channel(channelHandle)
+	if c == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.canSend(c) {
+		return 0
+	}
+	msgs = msgs[:min(n, uint(len(msgs)))]
+	peers := s.receivers(c, false)
+	for _, msg := range msgs {
+		ts := s.timestamp()
+		for _, p := range peers {
+			s.deliver(p, msg.Frame, ts)
+		}
+		if c.mode == ZCAN_MODE_LOOPBACK || msg.Type == ZCAN_TX_SELF_RX || msg.Type == ZCAN_TX_SINGLE_SHOT_SELF_RX {
+			s.deliver(c, msg.Frame, ts)
+		}
+	}
+	for _, p := range append(peers, c) {
+		s.wake(p)
+	}
+	return uint(len(msgs))
+}
+
+func (s *Simulator) TransmitFD(channelHandle int, msgs []ZCAN_TransmitFD_Data, n uint) uint {
+	c := s.channel(channelHandle)
+	if c == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.canSend(c) || c.canType != ZCAN_TYPE_CANFD {
+		return 0
+	}
+	msgs = msgs[:min(n, uint(len(msgs)))]
+	peers := s.receivers(c, true)
+	for _, msg := range msgs {
+		ts := s.timestamp()
+		for _, p := range peers {
+			s.deliverFD(p, msg.Frame, ts)
+		}
+		if c.mode == ZCAN_MODE_LOOPBACK || msg.Type == ZCAN_TX_SELF_RX || msg.Type == ZCAN_TX_SINGLE_SHOT_SELF_RX {
+			s.deliverFD(c, msg.Frame, ts)
+		}
+	}
+	for _, p := range append(peers, c) {
+		s.wake(p)
+	}
+	return uint(len(msgs))
+}
+
+// waitFrames blocks until pending returns true, the channel is reset or waitTime (ms,
+// negative waits forever) has passed.
+func (s *Simulator) waitFrames(c *simChannel, waitTime int, pending func() bool) {
+	var timeout <-chan time.Time
+	if waitTime > 0 {
+		timer := time.NewTimer(time.Duration(waitTime) * time.Millisecond)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for {
+		s.mu.Lock()
+		ready, started, notify := pending(), c.started, c.notify
+		s.mu.Unlock()
+		if ready || !started || waitTime == 0 {
+			return
+		}
+		select {
+		case <-notify:
+		case <-timeout:
+			return
+		}
+	}
+}
+
+func (s *Simulator) Receive(channelHandle int, msgs []ZCAN_Receive_Data, waitTime int) uint {
+	c := s.channel(channelHandle)
+	if c == nil || len(msgs) == 0 {
+		return 0
+	}
+	defer s.enter(&c.rxBusy, fmt.Sprintf("concurrent receive on channel %d", channelHandle))()
+	s.waitFrames(c, waitTime, func() bool { return len(c.rx) > 0 })
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := copy(msgs, c.rx)
+	c.rx = c.rx[n:]
+	return uint(n)
+}
+
+func (s *Simulator) ReceiveFD(channelHandle int, msgs []ZCAN_ReceiveFD_Data, waitTime int) uint {
+	c := s.channel(channelHandle)
+	if c == nil || len(msgs) == 0 {
+		return 0
+	}
+	defer s.enter(&c.rxBusy, fmt.Sprintf("concurrent receive on channel %d", channelHandle))()
+	s.waitFrames(c, waitTime, func() bool { return len(c.rxFD) > 0 })
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := copy(msgs, c.rxFD)
+	c.rxFD = c.rxFD[n:]
+	return uint(n)
+}
+
+func (s *Simulator) TransmitData(deviceHandle int, objs []ZCAN_DATA_OBJ, n uint) uint {
+	return 0
+}
+
+func (s *Simulator) ReceiveData(deviceHandle int, objs []ZCAN_DATA_OBJ, waitTime int) uint {
+	return 0
+}
+
+func (s *Simulator) GetIProperty(deviceHandle int) (*ZCAN_IProperty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dev, ok := s.handles[deviceHandle]
+	if !ok || !dev.online {
+		return nil, fmt.Errorf("error calling GetIProperty: invalid device handle %d", deviceHandle)
+	}
+	ip := &ZCAN_IProperty{}
+	s.ips[ip] = dev
+	return ip, nil
+}
+
+func (s *Simulator) property(iproperty *ZCAN_IProperty) *simDevice {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ips[iproperty]
+}
+
+func (s *Simulator) setValue(iproperty *ZCAN_IProperty, path, value string) uint {
+	dev := s.property(iproperty)
+	if dev == nil {
+		return ZCAN_STATUS_ERR
+	}
+	defer s.enter(&dev.propBusy, fmt.Sprintf("concurrent property access on device %d", dev.handle))()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dev.props[path] = value
+	return ZCAN_STATUS_OK
+}
+
+func (s *Simulator) SetValue(iproperty *ZCAN_IProperty, path, value string) uint {
+	return s.setValue(iproperty, path, value)
+}
+
+// SetValuePtr records that a struct was set; its contents are not interpreted.
+func (s *Simulator) SetValuePtr(iproperty *ZCAN_IProperty, path string, value unsafe.Pointer) uint {
+	return s.setValue(iproperty, path, fmt.Sprintf("%p", value))
+}
+
+func (s *Simulator) GetValue(iproperty *ZCAN_IProperty, path string) string {
+	dev := s.property(iproperty)
+	if dev == nil {
+		return ""
+	}
+	defer s.enter(&dev.propBusy, fmt.Sprintf("concurrent property access on device %d", dev.handle))()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return dev.props[path]
+}
+
+func (s *Simulator) ReleaseIProperty(iproperty *ZCAN_IProperty) uint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.ips[iproperty]; !ok {
+		return ZCAN_STATUS_ERR
+	}
+	delete(s.ips, iproperty)
+	return ZCAN_STATUS_OK
+}
+
+func (s *Simulator) Close() error {
+	return nil
+}
@@ -0,0 +1,334 @@
+package zlgcan
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func newSimulatedDevice(t *testing.T, sim *Simulator, deviceType int, deviceIndex int) (*ZCAN, int) {
+	t.Helper()
+	if err := sim.AddDevice(deviceType, deviceIndex, fmt.Sprintf("SIM%04d", deviceIndex)); err != nil {
+		t.Fatalf("AddDevice failed: %v", err)
+	}
+	zc := NewSimulatedZCAN(sim)
+	handle := zc.OpenDevice(deviceType, deviceIndex, 0)
+	if handle == INVALID_DEVICE_HANDLE {
+		t.Fatalf("Open Device failed")
+	}
+	return zc, handle
+}
+
+func newFDTransmit(id uint32, txType TransmitType) ZCAN_TransmitFD_Data {
+	msg := ZCAN_TransmitFD_Data{Type: txType}
+	msg.Frame.GenerateID(id, 0, 0, 0)
+	msg.Frame.Len = 8
+	return msg
+}
+
+// Test frames travelling between simulated channels
+func TestSimulatorBus(t *testing.T) {
+	sim := NewSimulator()
+	zc, handle := newSimulatedDevice(t, sim, ZCAN_USBCANFD_200U, 0)
+	ch0, err := zc.OpenChannel(handle, 0)
+	if err != nil {
+		t.Fatalf("OpenChannel 0 failed: %v", err)
+	}
+	ch1, err := zc.OpenChannel(handle, 1, WithListenOnly())
+	if err != nil {
+		t.Fatalf("OpenChannel 1 failed: %v", err)
+	}
+	if v, _ := sim.Property(ZCAN_USBCANFD_200U, 0, "1/clock"); v != "60000000" {
+		t.Fatalf("Expected the clock property to be set, got %q", v)
+	}
+
+	msgs := []ZCAN_TransmitFD_Data{newFDTransmit(0x100, ZCAN_TX_NORMAL), newFDTransmit(0x101, ZCAN_TX_SELF_RX)}
+	if sent := zc.TransmitFD(ch0.Handle(), msgs, 2); sent != 2 {
+		t.Fatalf("Expected 2 frames sent, got %d", sent)
+	}
+	if num := zc.GetReceiveNum(ch1.Handle(), ZCAN_TYPE_CANFD); num != 2 {
+		t.Fatalf("Expected 2 frames on channel 1, got %d", num)
+	}
+	rcv, n := ch0.ReceiveFD(10, 10)
+	if n != 1 || rcv[0].Frame.GetFrameID() != 0x101 {
+		t.Fatalf("Expected the self-receive echo on channel 0, got %d frames", n)
+	}
+	// A listen-only channel does not transmit.
+	if sent := zc.TransmitFD(ch1.Handle(), msgs, 1); sent != 0 {
+		t.Fatalf("Listen-only channel sent %d frames", sent)
+	}
+
+	// Receive waits for frames up to the wait time.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		zc.TransmitFD(ch0.Handle(), msgs, 1)
+	}()
+	zc.ClearBuffer(ch1.Handle())
+	if _, n := ch1.ReceiveFD(10, 1000); n != 1 {
+		t.Fatalf("Expected a frame after waiting, got %d", n)
+	}
+}
+
+// Test that the simulator reports calls the vendor library does not allow concurrently
+func TestSimulatorViolations(t *testing.T) {
+	sim := NewSimulator()
+	zc, handle := newSimulatedDevice(t, sim, ZCAN_USBCANFD_200U, 0)
+	ch, err := zc.OpenChannel(handle, 0)
+	if err != nil {
+		t.Fatalf("OpenChannel failed: %v", err)
+	}
+
+	// Bypass the ZCAN locks and receive from two goroutines at once.
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sim.ReceiveFD(ch.Handle(), make([]ZCAN_ReceiveFD_Data, 1), 50)
+		}()
+	}
+	wg.Wait()
+	if len(sim.Violations()) == 0 {
+		t.Fatalf("Expected the overlapping receive to be reported")
+	}
+}
+
+// Test concurrent use of one device and its channels through ZCAN; run with -race
+func TestConcurrentStress(t *testing.T) {
+	const senders, frames = 4, 500
+	sim := NewSimulator()
+	zc, handle := newSimulatedDevice(t, sim, ZCAN_USBCANFD_200U, 0)
+	tx, err := zc.OpenChannel(handle, 0)
+	if err != nil {
+		t.Fatalf("OpenChannel 0 failed: %v", err)
+	}
+	rx, err := zc.OpenChannel(handle, 1)
+	if err != nil {
+		t.Fatalf("OpenChannel 1 failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := range senders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			msgs := []ZCAN_TransmitFD_Data{newFDTransmit(uint32(i), ZCAN_TX_NORMAL)}
+			for range frames {
+				zc.TransmitFD(tx.Handle(), msgs, 1)
+			}
+		}()
+	}
+
+	var rcvMu sync.Mutex
+	received := 0
+	var rxWg sync.WaitGroup
+	for range 3 {
+		rxWg.Add(1)
+		go func() {
+			defer rxWg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if zc.GetReceiveNum(rx.Handle(), ZCAN_TYPE_CANFD) == 0 {
+					continue
+				}
+				_, n := rx.ReceiveFD(50, 1)
+				rcvMu.Lock()
+				received += int(n)
+				rcvMu.Unlock()
+			}
+		}()
+	}
+
+	// Property access from several goroutines, on the device and through channels.
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 50 {
+				if err := zc.setProperties(handle, []property{{fmt.Sprintf("0/stress_%d", i), fmt.Sprint(j)}}); err != nil {
+					t.Errorf("setProperties failed: %v", err)
+					return
+				}
+				ip, err := zc.GetIProperty(handle)
+				if err != nil {
+					t.Errorf("GetIProperty failed: %v", err)
+					return
+				}
+				zc.GetValue(ip, "0/clock")
+				zc.ReleaseIProperty(ip)
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 50 {
+			tx.Handle()
+			zc.ReadChannelStatus(rx.Handle())
+			if err := rx.EnableBusUsage(100 * time.Millisecond); err != nil {
+				t.Errorf("EnableBusUsage failed: %v", err)
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rcvMu.Lock()
+		done := received == senders*frames
+		rcvMu.Unlock()
+		if done || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(stop)
+	rxWg.Wait()
+
+	if received != senders*frames {
+		t.Fatalf("Expected %d frames, received %d", senders*frames, received)
+	}
+	if v := sim.Violations(); len(v) > 0 {
+		t.Fatalf("Unsafe concurrent calls reached the library: %v", v[:min(len(v), 5)])
+	}
+}
+
+// Test that a released property interface does not reach the driver
+func TestIPropertyReleased(t *testing.T) {
+	sim := NewSimulator()
+	zc, handle := newSimulatedDevice(t, sim, ZCAN_USBCANFD_200U, 0)
+	ip, err := zc.GetIProperty(handle)
+	if err != nil {
+		t.Fatalf("GetIProperty failed: %v", err)
+	}
+	if ret := zc.SetValue(ip, "0/test", "1"); ret != ZCAN_STATUS_OK {
+		t.Fatalf("SetValue failed: %d", ret)
+	}
+	if ret := zc.ReleaseIProperty(ip); ret != ZCAN_STATUS_OK {
+		t.Fatalf("ReleaseIProperty failed: %d", ret)
+	}
+	if ret := zc.SetValue(ip, "0/test", "2"); ret != ZCAN_STATUS_ERR {
+		t.Fatalf("Expected SetValue on a released iproperty to fail, got %d", ret)
+	}
+	if got := zc.GetValue(ip, "0/test"); got != "" {
+		t.Fatalf("Expected no value from a released iproperty, got %q", got)
+	}
+	if ret := zc.ReleaseIProperty(ip); ret != ZCAN_STATUS_ERR {
+		t.Fatalf("Expected releasing twice to fail, got %d", ret)
+	}
+	if v, _ := sim.Property(ZCAN_USBCANFD_200U, 0, "0/test"); v != "1" {
+		t.Fatalf("Expected the property unchanged, got %q", v)
+	}
+}
+
+// sharedIProperty is a simulator whose GetIProperty hands out one interface for all
+// devices, bound to the device it was last obtained for until it is released, like the
+// stub library.
+type sharedIProperty struct {
+	*Simulator
+	mu       sync.Mutex
+	shared   ZCAN_IProperty
+	bound    *ZCAN_IProperty
+	releases int
+}
+
+func (d *sharedIProperty) GetIProperty(deviceHandle int) (*ZCAN_IProperty, error) {
+	ip, err := d.Simulator.GetIProperty(deviceHandle)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.bound != nil {
+		d.Simulator.ReleaseIProperty(d.bound)
+	}
+	d.bound = ip
+	return &d.shared, nil
+}
+
+func (d *sharedIProperty) target(iproperty *ZCAN_IProperty) *ZCAN_IProperty {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if iproperty != &d.shared {
+		return nil
+	}
+	return d.bound
+}
+
+func (d *sharedIProperty) SetValue(iproperty *ZCAN_IProperty, path, value string) uint {
+	return d.Simulator.SetValue(d.target(iproperty), path, value)
+}
+
+func (d *sharedIProperty) SetValuePtr(iproperty *ZCAN_IProperty, path string, value unsafe.Pointer) uint {
+	return d.Simulator.SetValuePtr(d.target(iproperty), path, value)
+}
+
+func (d *sharedIProperty) GetValue(iproperty *ZCAN_IProperty, path string) string {
+	return d.Simulator.GetValue(d.target(iproperty), path)
+}
+
+func (d *sharedIProperty) ReleaseIProperty(iproperty *ZCAN_IProperty) uint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if iproperty != &d.shared || d.bound == nil {
+		return ZCAN_STATUS_ERR
+	}
+	d.releases++
+	ret := d.Simulator.ReleaseIProperty(d.bound)
+	d.bound = nil
+	return ret
+}
+
+// Test that a held iproperty keeps working when the library hands the same interface to
+// OpenChannel and to a second holder
+func TestIPropertyShared(t *testing.T) {
+	sim := NewSimulator()
+	if err := sim.AddDevice(ZCAN_USBCANFD_200U, 0, "SIM0000"); err != nil {
+		t.Fatalf("AddDevice failed: %v", err)
+	}
+	drv := &sharedIProperty{Simulator: sim}
+	zc := newZCAN(drv, nil)
+	handle := zc.OpenDevice(ZCAN_USBCANFD_200U, 0, 0)
+	if handle == INVALID_DEVICE_HANDLE {
+		t.Fatalf("Open Device failed")
+	}
+
+	ip, err := zc.GetIProperty(handle)
+	if err != nil {
+		t.Fatalf("GetIProperty failed: %v", err)
+	}
+	if _, err := zc.OpenChannel(handle, 0); err != nil {
+		t.Fatalf("OpenChannel failed: %v", err)
+	}
+	if ret := zc.SetValue(ip, "0/test", "1"); ret != ZCAN_STATUS_OK {
+		t.Fatalf("SetValue after OpenChannel failed: %d", ret)
+	}
+
+	second, err := zc.GetIProperty(handle)
+	if err != nil || second != ip {
+		t.Fatalf("Expected the shared iproperty again, got %p, %v", second, err)
+	}
+	if ret := zc.ReleaseIProperty(second); ret != ZCAN_STATUS_OK {
+		t.Fatalf("ReleaseIProperty failed: %d", ret)
+	}
+	if got := zc.GetValue(ip, "0/test"); got != "1" {
+		t.Fatalf("Expected the first holder to keep the interface, got %q", got)
+	}
+	if drv.releases != 0 {
+		t.Fatalf("Expected no release in the driver while a holder is left, got %d", drv.releases)
+	}
+	if ret := zc.ReleaseIProperty(ip); ret != ZCAN_STATUS_OK || drv.releases != 1 {
+		t.Fatalf("Expected the last release to reach the driver, got %d after %d release(s)", ret, drv.releases)
+	}
+	if ret := zc.SetValue(ip, "0/test", "2"); ret != ZCAN_STATUS_ERR {
+		t.Fatalf("Expected SetValue on a released iproperty to fail, got %d", ret)
+	}
+}
@@ -0,0 +1,110 @@
+package zlgcan
+
+import "fmt"
+
+// ControllerState is the fault confinement state of a CAN controller.
+type ControllerState uint8
+
+const (
+	ControllerErrorActive ControllerState = iota
+	ControllerErrorWarning
+	ControllerErrorPassive
+	ControllerBusOff
+)
+
+func (s ControllerState) String() string {
+	switch s {
+	case ControllerErrorActive:
+		return "error active"
+	case ControllerErrorWarning:
+		return "error warning"
+	case ControllerErrorPassive:
+		return "error passive"
+	case ControllerBusOff:
+		return "bus off"
+	}
+	return fmt.Sprintf("state(%d)", uint8(s))
+}
+
+// SJA1000 mode and status register bits.
+const (
+	sja1000ModeReset      = 0x01
+	sja1000ModeListenOnly = 0x02
+	sja1000ModeSelfTest   = 0x04
+
+	sja1000StatusDataOverrun = 0x02
+	sja1000StatusReceiving   = 0x10
+	sja1000StatusTransmit    = 0x20
+	sja1000StatusError       = 0x40
+	sja1000StatusBusOff      = 0x80
+
+	// sja1000DefaultEWL is the error warning limit after a hardware reset, used when the
+	// device reports 0.
+	sja1000DefaultEWL = 96
+	errorPassiveLimit = 128
+)
+
+// ChannelStatus is the decoded form of ZCAN_CHANNEL_STATUS.
+type ChannelStatus struct {
+	State ControllerState
+
+	ResetMode    bool
+	ListenOnly   bool
+	SelfTest     bool
+	Receiving    bool
+	Transmitting bool
+	DataOverrun  bool
+
+	// Capture and ArbitrationLost hold the last captured bus error and arbitration loss.
+	Capture         BusErrorCapture
+	ArbitrationLost ArbitrationLostBit
+
+	ErrorWarningLimit uint8
+	RxErrors          uint8
+	TxErrors          uint8
+}
+
+// Decode interprets the controller registers of the status.
+func (s *ZCAN_CHANNEL_STATUS) Decode() ChannelStatus {
+	status := ChannelStatus{
+		ResetMode:         s.RegMode&sja1000ModeReset != 0,
+		ListenOnly:        s.RegMode&sja1000ModeListenOnly != 0,
+		SelfTest:          s.RegMode&sja1000ModeSelfTest != 0,
+		Receiving:         s.RegStatus&sja1000StatusReceiving != 0,
+		Transmitting:      s.RegStatus&sja1000StatusTransmit != 0,
+		DataOverrun:       s.RegStatus&sja1000StatusDataOverrun != 0,
+		Capture:           DecodeErrorCapture(s.RegECCapture),
+		ArbitrationLost:   DecodeArbitrationLost(s.RegALCapture),
+		ErrorWarningLimit: s.RegEWLimit,
+		RxErrors:          s.RegRECounter,
+		TxErrors:          s.RegTECounter,
+	}
+	ewl := s.RegEWLimit
+	if ewl == 0 {
+		ewl = sja1000DefaultEWL
+	}
+	switch {
+	case s.RegStatus&sja1000StatusBusOff != 0:
+		status.State = ControllerBusOff
+	case s.RegRECounter >= errorPassiveLimit || s.RegTECounter >= errorPassiveLimit:
+		status.State = ControllerErrorPassive
+	case s.RegStatus&sja1000StatusError != 0 || s.RegRECounter >= ewl || s.RegTECounter >= ewl:
+		status.State = ControllerErrorWarning
+	}
+	return status
+}
+
+func (s *ZCAN_CHANNEL_STATUS) String() string {
+	return s.Decode().String()
+}
+
+func (s ChannelStatus) String() string {
+	str := fmt.Sprintf("%s, rx errors %d, tx errors %d (warning limit %d)", s.State, s.RxErrors, s.TxErrors, s.ErrorWarningLimit)
+	if s.DataOverrun {
+		str += ", data overrun"
+	}
+	if s.State != ControllerErrorActive {
+		str += fmt.Sprintf("; last %s", s.Capture)
+	}
+	return str
+}
@@ -0,0 +1,46 @@
+package zlgcan
+
+import "testing"
+
+// Test for the controller state derived from ZCAN_CHANNEL_STATUS
+func TestChannelStatusState(t *testing.T) {
+	cases := []struct {
+		status ZCAN_CHANNEL_STATUS
+		want   ControllerState
+	}{
+		{ZCAN_CHANNEL_STATUS{RegEWLimit: 96, RegRECounter: 10, RegTECounter: 20}, ControllerErrorActive},
+		{ZCAN_CHANNEL_STATUS{RegEWLimit: 96, RegTECounter: 96}, ControllerErrorWarning},
+		{ZCAN_CHANNEL_STATUS{RegTECounter: 100}, ControllerErrorWarning}, // default warning limit
+		{ZCAN_CHANNEL_STATUS{RegEWLimit: 120, RegStatus: sja1000StatusError, RegTECounter: 50}, ControllerErrorWarning},
+		{ZCAN_CHANNEL_STATUS{RegEWLimit: 96, RegRECounter: 128}, ControllerErrorPassive},
+		{ZCAN_CHANNEL_STATUS{RegEWLimit: 96, RegStatus: sja1000StatusBusOff | sja1000StatusError, RegTECounter: 127}, ControllerBusOff},
+	}
+	for i, c := range cases {
+		if got := c.status.Decode().State; got != c.want {
+			t.Fatalf("Case %d: expected %s, got %s", i, c.want, got)
+		}
+	}
+}
+
+// Test for ZCAN_CHANNEL_STATUS register decoding
+func TestChannelStatusDecode(t *testing.T) {
+	raw := ZCAN_CHANNEL_STATUS{
+		RegMode:      sja1000ModeListenOnly,
+		RegStatus:    sja1000StatusReceiving | sja1000StatusDataOverrun,
+		RegECCapture: 0x28, // bit error while receiving, CRC sequence
+		RegALCapture: 12,
+		RegEWLimit:   96,
+		RegRECounter: 130,
+	}
+	status := raw.Decode()
+	if !status.ListenOnly || status.ResetMode || !status.Receiving || status.Transmitting || !status.DataOverrun {
+		t.Fatalf("Unexpected flags: %+v", status)
+	}
+	if status.ArbitrationLost.String() != "IDE bit" {
+		t.Fatalf("Unexpected arbitration lost bit: %s", status.ArbitrationLost)
+	}
+	want := "error passive, rx errors 130, tx errors 0 (warning limit 96), data overrun; last bit error during rx in CRC sequence"
+	if got := raw.String(); got != want {
+		t.Fatalf("Expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,213 @@
+/*
+ * Subset of the vendor zlgcan.h used by the stub library and the layout checks in
+ * layout_test.go: the types and functions the Go package uses, transcribed by hand with the
+ * vendor's field order and widths. It has not been compared with a vendor release.
+ */
+#ifndef ZLGCAN_STUB_H
+#define ZLGCAN_STUB_H
+
+#include <stdint.h>
+
+typedef uint8_t BYTE;
+typedef uint8_t UCHAR;
+typedef uint16_t USHORT;
+typedef uint32_t UINT;
+typedef int32_t INT;
+typedef uint64_t UINT64;
+typedef void *DEVICE_HANDLE;
+typedef void *CHANNEL_HANDLE;
+
+#define INVALID_DEVICE_HANDLE 0
+#define INVALID_CHANNEL_HANDLE 0
+
+#define STATUS_ERR 0
+#define STATUS_OK 1
+#define STATUS_ONLINE 2
+#define STATUS_OFFLINE 3
+
+#define TYPE_CAN 0
+#define TYPE_CANFD 1
+
+typedef struct tagZCAN_DEVICE_INFO {
+    USHORT hw_Version;
+    USHORT fw_Version;
+    USHORT dr_Version;
+    USHORT in_Version;
+    USHORT irq_Num;
+    BYTE can_Num;
+    UCHAR str_Serial_Num[20];
+    UCHAR str_hw_Type[40];
+    USHORT reserved[4];
+} ZCAN_DEVICE_INFO;
+
+typedef struct tagZCAN_CHANNEL_INIT_CONFIG {
+    UINT can_type;
+    union {
+        struct {
+            UINT acc_code;
+            UINT acc_mask;
+            UINT reserved;
+            BYTE filter;
+            BYTE timing0;
+            BYTE timing1;
+            BYTE mode;
+        } can;
+        struct {
+            UINT acc_code;
+            UINT acc_mask;
+            UINT abit_timing;
+            UINT dbit_timing;
+            UINT brp;
+            BYTE filter;
+            BYTE mode;
+            USHORT pad;
+            UINT reserved;
+        } canfd;
+    };
+} ZCAN_CHANNEL_INIT_CONFIG;
+
+typedef struct tagZCAN_CHANNEL_ERR_INFO {
+    UINT error_code;
+    BYTE passive_ErrData[3];
+    BYTE arLost_ErrData;
+} ZCAN_CHANNEL_ERR_INFO;
+
+typedef struct tagZCAN_CHANNEL_STATUS {
+    BYTE errInterrupt;
+    BYTE regMode;
+    BYTE regStatus;
+    BYTE regALCapture;
+    BYTE regECCapture;
+    BYTE regEWLimit;
+    BYTE regRECounter;
+    BYTE regTECounter;
+    UINT Reserved;
+} ZCAN_CHANNEL_STATUS;
+
+typedef UINT canid_t;
+
+typedef struct can_frame {
+    canid_t can_id;
+    BYTE can_dlc;
+    BYTE __pad;
+    BYTE __res0;
+    BYTE __res1;
+    BYTE data[8];
+} can_frame;
+
+typedef struct canfd_frame {
+    canid_t can_id;
+    BYTE len;
+    BYTE flags;
+    BYTE __res0;
+    BYTE __res1;
+    BYTE data[64];
+} canfd_frame;
+
+typedef struct tagZCAN_Transmit_Data {
+    can_frame frame;
+    UINT transmit_type;
+} ZCAN_Transmit_Data;
+
+typedef struct tagZCAN_Receive_Data {
+    can_frame frame;
+    UINT64 timestamp;
+} ZCAN_Receive_Data;
+
+typedef struct tagZCAN_TransmitFD_Data {
+    canfd_frame frame;
+    UINT transmit_type;
+} ZCAN_TransmitFD_Data;
+
+typedef struct tagZCAN_ReceiveFD_Data {
+    canfd_frame frame;
+    UINT64 timestamp;
+} ZCAN_ReceiveFD_Data;
+
+typedef struct tagZCAN_AUTO_TRANSMIT_OBJ {
+    USHORT enable;
+    USHORT index;
+    UINT interval;
+    ZCAN_Transmit_Data obj;
+} ZCAN_AUTO_TRANSMIT_OBJ;
+
+typedef struct tagZCANFD_AUTO_TRANSMIT_OBJ {
+    USHORT enable;
+    USHORT index;
+    UINT interval;
+    ZCAN_TransmitFD_Data obj;
+} ZCANFD_AUTO_TRANSMIT_OBJ;
+
+typedef struct tagZCANCANFDData {
+    UINT64 timeStamp;
+    UINT flag;
+    BYTE extraData[4];
+    canfd_frame frame;
+} ZCANCANFDData;
+
+typedef struct tagZCANErrorData {
+    UINT64 timeStamp;
+    BYTE errType;
+    BYTE errSubType;
+    BYTE nodeState;
+    BYTE rxErrCount;
+    BYTE txErrCount;
+    BYTE errData;
+    BYTE reserved[2];
+} ZCANErrorData;
+
+typedef struct tagBusUsage {
+    UINT64 nTimeStampBegin;
+    UINT64 nTimeStampEnd;
+    BYTE nChnl;
+    BYTE nReserved;
+    USHORT nBusUsage;
+    UINT nFrameCount;
+} BusUsage;
+
+typedef struct tagZCANDataObj {
+    BYTE dataType;
+    BYTE chnl;
+    USHORT flag;
+    BYTE extraData[4];
+    union {
+        ZCANCANFDData zcanCANFDData;
+        ZCANErrorData zcanErrData;
+        BusUsage busUsage;
+        BYTE raw[92];
+    } data;
+} ZCANDataObj;
+
+typedef UINT (*SetValueFunc)(const char *path, const void *value);
+typedef const char *(*GetValueFunc)(const char *path);
+typedef void *(*GetPropertysFunc)(const char *path, const char *value);
+
+typedef struct tagIProperty {
+    SetValueFunc SetValue;
+    GetValueFunc GetValue;
+    GetPropertysFunc GetPropertys;
+} IProperty;
+
+DEVICE_HANDLE ZCAN_OpenDevice(UINT device_type, UINT device_index, UINT reserved);
+UINT ZCAN_CloseDevice(DEVICE_HANDLE device_handle);
+UINT ZCAN_GetDeviceInf(DEVICE_HANDLE device_handle, ZCAN_DEVICE_INFO *pInfo);
+UINT ZCAN_IsDeviceOnLine(DEVICE_HANDLE device_handle);
+
+CHANNEL_HANDLE ZCAN_InitCAN(DEVICE_HANDLE device_handle, UINT can_index, ZCAN_CHANNEL_INIT_CONFIG *pInitConfig);
+UINT ZCAN_StartCAN(CHANNEL_HANDLE channel_handle);
+UINT ZCAN_ResetCAN(CHANNEL_HANDLE channel_handle);
+UINT ZCAN_ClearBuffer(CHANNEL_HANDLE channel_handle);
+UINT ZCAN_ReadChannelErrInfo(CHANNEL_HANDLE channel_handle, ZCAN_CHANNEL_ERR_INFO *pErrInfo);
+UINT ZCAN_ReadChannelStatus(CHANNEL_HANDLE channel_handle, ZCAN_CHANNEL_STATUS *pCANStatus);
+UINT ZCAN_GetReceiveNum(CHANNEL_HANDLE channel_handle, BYTE type);
+UINT ZCAN_Transmit(CHANNEL_HANDLE channel_handle, ZCAN_Transmit_Data *pTransmit, UINT len);
+UINT ZCAN_Receive(CHANNEL_HANDLE channel_handle, ZCAN_Receive_Data *pReceive, UINT len, INT wait_time);
+UINT ZCAN_TransmitFD(CHANNEL_HANDLE channel_handle, ZCAN_TransmitFD_Data *pTransmit, UINT len);
+UINT ZCAN_ReceiveFD(CHANNEL_HANDLE channel_handle, ZCAN_ReceiveFD_Data *pReceive, UINT len, INT wait_time);
+UINT ZCAN_TransmitData(DEVICE_HANDLE device_handle, ZCANDataObj *pTransmit, UINT len);
+UINT ZCAN_ReceiveData(DEVICE_HANDLE device_handle, ZCANDataObj *pReceive, UINT len, INT wait_time);
+
+IProperty *GetIProperty(DEVICE_HANDLE device_handle);
+UINT ReleaseIProperty(IProperty *pIProperty);
+
+#endif
@@ -0,0 +1,403 @@
+/*
+ * In-memory stand-in for the vendor library, loaded by the native integration tests.
+ * Every channel loops its transmitted frames back into its own receive buffer, merged data
+ * is looped back per device. Properties are kept as strings; GetValue also answers
+ * diagnostic paths describing what the stub received:
+ *
+ *   stub/init/<channel>   the last init config of the channel, as the stub decoded it
+ *   <channel>/auto_send   the last auto-send entry, likewise for auto_send_canfd
+ *
+ * Build: cc -shared -fPIC -o libzlgcan_stub.so zlgcan_stub.c
+ */
+#include <stdio.h>
+#include <string.h>
+
+#include "zlgcan.h"
+
+#define MAX_DEVICES 8
+#define MAX_CHANNELS 4
+#define QUEUE_LEN 256
+#define MAX_PROPS 128
+
+#define DEVICE_BASE ((uintptr_t)0x5a5a00000000)
+#define CHANNEL_BASE ((uintptr_t)0x5b5b00000000)
+
+struct channel {
+    int inited;
+    int started;
+    ZCAN_CHANNEL_INIT_CONFIG cfg;
+    ZCAN_Receive_Data rx[QUEUE_LEN];
+    UINT rx_num;
+    ZCAN_ReceiveFD_Data rx_fd[QUEUE_LEN];
+    UINT rx_fd_num;
+};
+
+struct device {
+    int open;
+    UINT type;
+    UINT index;
+    struct channel channels[MAX_CHANNELS];
+    ZCANDataObj data[QUEUE_LEN];
+    UINT data_num;
+};
+
+struct property {
+    char key[96];
+    char value[256];
+};
+
+static struct device devices[MAX_DEVICES];
+static struct property props[MAX_PROPS];
+static int prop_num;
+static int prop_device = -1;
+static UINT64 clock_ticks;
+static char value_buf[256];
+
+static struct device *find_device(DEVICE_HANDLE handle)
+{
+    uintptr_t slot = (uintptr_t)handle - DEVICE_BASE;
+    if (slot >= MAX_DEVICES || !devices[slot].open) {
+        return NULL;
+    }
+    return &devices[slot];
+}
+
+static struct channel *find_channel(CHANNEL_HANDLE handle)
+{
+    uintptr_t id = (uintptr_t)handle - CHANNEL_BASE;
+    uintptr_t slot = id >> 8, index = id & 0xff;
+    if (slot >= MAX_DEVICES || index >= MAX_CHANNELS || !devices[slot].open) {
+        return NULL;
+    }
+    if (!devices[slot].channels[index].inited) {
+        return NULL;
+    }
+    return &devices[slot].channels[index];
+}
+
+static struct property *find_property(const char *path, int create)
+{
+    char key[96];
+    snprintf(key, sizeof(key), "%d/%s", prop_device, path);
+    for (int i = 0; i < prop_num; i++) {
+        if (strcmp(props[i].key, key) == 0) {
+            return &props[i];
+        }
+    }
+    if (!create || prop_num == MAX_PROPS) {
+        return NULL;
+    }
+    struct property *p = &props[prop_num++];
+    snprintf(p->key, sizeof(p->key), "%s", key);
+    p->value[0] = 0;
+    return p;
+}
+
+static int has_suffix(const char *s, const char *suffix)
+{
+    size_t n = strlen(s), m = strlen(suffix);
+    return n >= m && strcmp(s + n - m, suffix) == 0;
+}
+
+DEVICE_HANDLE ZCAN_OpenDevice(UINT device_type, UINT device_index, UINT reserved)
+{
+    (void)reserved;
+    for (int slot = 0; slot < MAX_DEVICES; slot++) {
+        struct device *d = &devices[slot];
+        if (d->open && d->type == device_type && d->index == device_index) {
+            return INVALID_DEVICE_HANDLE;
+        }
+    }
+    for (int slot = 0; slot < MAX_DEVICES; slot++) {
+        struct device *d = &devices[slot];
+        if (!d->open) {
+            memset(d, 0, sizeof(*d));
+            d->open = 1;
+            d->type = device_type;
+            d->index = device_index;
+            return (DEVICE_HANDLE)(DEVICE_BASE + slot);
+        }
+    }
+    return INVALID_DEVICE_HANDLE;
+}
+
+UINT ZCAN_CloseDevice(DEVICE_HANDLE device_handle)
+{
+    struct device *d = find_device(device_handle);
+    if (d == NULL) {
+        return STATUS_ERR;
+    }
+    d->open = 0;
+    return STATUS_OK;
+}
+
+UINT ZCAN_GetDeviceInf(DEVICE_HANDLE device_handle, ZCAN_DEVICE_INFO *pInfo)
+{
+    struct device *d = find_device(device_handle);
+    if (d == NULL) {
+        return STATUS_ERR;
+    }
+    memset(pInfo, 0, sizeof(*pInfo));
+    pInfo->hw_Version = 0x0102;
+    pInfo->fw_Version = 0x0203;
+    pInfo->dr_Version = 0x0304;
+    pInfo->in_Version = 0x0405;
+    pInfo->irq_Num = 7;
+    pInfo->can_Num = 2;
+    snprintf((char *)pInfo->str_Serial_Num, sizeof(pInfo->str_Serial_Num), "STUB%04u", d->index);
+    snprintf((char *)pInfo->str_hw_Type, sizeof(pInfo->str_hw_Type), "USBCANFD-200U");
+    for (int i = 0; i < 4; i++) {
+        pInfo->reserved[i] = 0xFFFF;
+    }
+    return STATUS_OK;
+}
+
+UINT ZCAN_IsDeviceOnLine(DEVICE_HANDLE device_handle)
+{
+    return find_device(device_handle) != NULL ? STATUS_ONLINE : STATUS_OFFLINE;
+}
+
+CHANNEL_HANDLE ZCAN_InitCAN(DEVICE_HANDLE device_handle, UINT can_index, ZCAN_CHANNEL_INIT_CONFIG *pInitConfig)
+{
+    struct device *d = find_device(device_handle);
+    if (d == NULL || can_index >= MAX_CHANNELS || pInitConfig == NULL) {
+        return INVALID_CHANNEL_HANDLE;
+    }
+    struct channel *c = &d->channels[can_index];
+    memset(c, 0, sizeof(*c));
+    c->inited = 1;
+    c->cfg = *pInitConfig;
+
+    char path[32];
+    int saved = prop_device;
+    prop_device = (int)(d - devices);
+    snprintf(path, sizeof(path), "stub/init/%u", can_index);
+    struct property *p = find_property(path, 1);
+    prop_device = saved;
+    if (p != NULL && pInitConfig->can_type == TYPE_CANFD) {
+        snprintf(p->value, sizeof(p->value), "can_type=%u acc_code=0x%x acc_mask=0x%x abit=0x%x dbit=0x%x mode=%u",
+                 pInitConfig->can_type, pInitConfig->canfd.acc_code, pInitConfig->canfd.acc_mask,
+                 pInitConfig->canfd.abit_timing, pInitConfig->canfd.dbit_timing, pInitConfig->canfd.mode);
+    } else if (p != NULL) {
+        snprintf(p->value, sizeof(p->value), "can_type=%u acc_code=0x%x acc_mask=0x%x timing0=0x%x timing1=0x%x mode=%u",
+                 pInitConfig->can_type, pInitConfig->can.acc_code, pInitConfig->can.acc_mask,
+                 pInitConfig->can.timing0, pInitConfig->can.timing1, pInitConfig->can.mode);
+    }
+    return (CHANNEL_HANDLE)(CHANNEL_BASE + ((uintptr_t)(d - devices) << 8) + can_index);
+}
+
+UINT ZCAN_StartCAN(CHANNEL_HANDLE channel_handle)
+{
+    struct channel *c = find_channel(channel_handle);
+    if (c == NULL) {
+        return STATUS_ERR;
+    }
+    c->started = 1;
+    return STATUS_OK;
+}
+
+UINT ZCAN_ResetCAN(CHANNEL_HANDLE channel_handle)
+{
+    struct channel *c = find_channel(channel_handle);
+    if (c == NULL) {
+        return STATUS_ERR;
+    }
+    c->started = 0;
+    c->rx_num = 0;
+    c->rx_fd_num = 0;
+    return STATUS_OK;
+}
+
+UINT ZCAN_ClearBuffer(CHANNEL_HANDLE channel_handle)
+{
+    struct channel *c = find_channel(channel_handle);
+    if (c == NULL) {
+        return STATUS_ERR;
+    }
+    c->rx_num = 0;
+    c->rx_fd_num = 0;
+    return STATUS_OK;
+}
+
+UINT ZCAN_ReadChannelErrInfo(CHANNEL_HANDLE channel_handle, ZCAN_CHANNEL_ERR_INFO *pErrInfo)
+{
+    if (find_channel(channel_handle) == NULL) {
+        return STATUS_ERR;
+    }
+    pErrInfo->error_code = 0x21;
+    pErrInfo->passive_ErrData[0] = 0x11;
+    pErrInfo->passive_ErrData[1] = 0x22;
+    pErrInfo->passive_ErrData[2] = 0x33;
+    pErrInfo->arLost_ErrData = 0x44;
+    return STATUS_OK;
+}
+
+UINT ZCAN_ReadChannelStatus(CHANNEL_HANDLE channel_handle, ZCAN_CHANNEL_STATUS *pCANStatus)
+{
+    if (find_channel(channel_handle) == NULL) {
+        return STATUS_ERR;
+    }
+    pCANStatus->errInterrupt = 1;
+    pCANStatus->regMode = 2;
+    pCANStatus->regStatus = 3;
+    pCANStatus->regALCapture = 4;
+    pCANStatus->regECCapture = 5;
+    pCANStatus->regEWLimit = 6;
+    pCANStatus->regRECounter = 7;
+    pCANStatus->regTECounter = 8;
+    pCANStatus->Reserved = 0xdeadbeef;
+    return STATUS_OK;
+}
+
+UINT ZCAN_GetReceiveNum(CHANNEL_HANDLE channel_handle, BYTE type)
+{
+    struct channel *c = find_channel(channel_handle);
+    if (c == NULL) {
+        struct device *d = find_device(channel_handle);
+        return d != NULL && type == 2 ? d->data_num : 0;
+    }
+    return type == TYPE_CANFD ? c->rx_fd_num : c->rx_num;
+}
+
+UINT ZCAN_Transmit(CHANNEL_HANDLE channel_handle, ZCAN_Transmit_Data *pTransmit, UINT len)
+{
+    struct channel *c = find_channel(channel_handle);
+    if (c == NULL || !c->started) {
+        return 0;
+    }
+    UINT n = 0;
+    for (; n < len && c->rx_num < QUEUE_LEN; n++) {
+        ZCAN_Receive_Data *r = &c->rx[c->rx_num++];
+        r->frame = pTransmit[n].frame;
+        r->timestamp = ++clock_ticks * 100;
+    }
+    return n;
+}
+
+UINT ZCAN_Receive(CHANNEL_HANDLE channel_handle, ZCAN_Receive_Data *pReceive, UINT len, INT wait_time)
+{
+    (void)wait_time;
+    struct channel *c = find_channel(channel_handle);
+    if (c == NULL) {
+        return 0;
+    }
+    UINT n = len < c->rx_num ? len : c->rx_num;
+    memcpy(pReceive, c->rx, n * sizeof(*pReceive));
+    memmove(c->rx, c->rx + n, (c->rx_num - n) * sizeof(*pReceive));
+    c->rx_num -= n;
+    return n;
+}
+
+UINT ZCAN_TransmitFD(CHANNEL_HANDLE channel_handle, ZCAN_TransmitFD_Data *pTransmit, UINT len)
+{
+    struct channel *c = find_channel(channel_handle);
+    if (c == NULL || !c->started) {
+        return 0;
+    }
+    UINT n = 0;
+    for (; n < len && c->rx_fd_num < QUEUE_LEN; n++) {
+        ZCAN_ReceiveFD_Data *r = &c->rx_fd[c->rx_fd_num++];
+        r->frame = pTransmit[n].frame;
+        r->timestamp = ++clock_ticks * 100;
+    }
+    return n;
+}
+
+UINT ZCAN_ReceiveFD(CHANNEL_HANDLE channel_handle, ZCAN_ReceiveFD_Data *pReceive, UINT len, INT wait_time)
+{
+    (void)wait_time;
+    struct channel *c = find_channel(channel_handle);
+    if (c == NULL) {
+        return 0;
+    }
+    UINT n = len < c->rx_fd_num ? len : c->rx_fd_num;
+    memcpy(pReceive, c->rx_fd, n * sizeof(*pReceive));
+    memmove(c->rx_fd, c->rx_fd + n, (c->rx_fd_num - n) * sizeof(*pReceive));
+    c->rx_fd_num -= n;
+    return n;
+}
+
+UINT ZCAN_TransmitData(DEVICE_HANDLE device_handle, ZCANDataObj *pTransmit, UINT len)
+{
+    struct device *d = find_device(device_handle);
+    if (d == NULL) {
+        return 0;
+    }
+    UINT n = 0;
+    for (; n < len && d->data_num < QUEUE_LEN; n++) {
+        ZCANDataObj *obj = &d->data[d->data_num++];
+        *obj = pTransmit[n];
+        obj->data.zcanCANFDData.timeStamp = ++clock_ticks * 100;
+    }
+    return n;
+}
+
+UINT ZCAN_ReceiveData(DEVICE_HANDLE device_handle, ZCANDataObj *pReceive, UINT len, INT wait_time)
+{
+    (void)wait_time;
+    struct device *d = find_device(device_handle);
+    if (d == NULL) {
+        return 0;
+    }
+    UINT n = len < d->data_num ? len : d->data_num;
+    memcpy(pReceive, d->data, n * sizeof(*pReceive));
+    memmove(d->data, d->data + n, (d->data_num - n) * sizeof(*pReceive));
+    d->data_num -= n;
+    return n;
+}
+
+static UINT set_value(const char *path, const void *value)
+{
+    if (prop_device < 0 || path == NULL || value == NULL) {
+        return STATUS_ERR;
+    }
+    struct property *p = find_property(path, 1);
+    if (p == NULL) {
+        return STATUS_ERR;
+    }
+    if (has_suffix(path, "/auto_send")) {
+        const ZCAN_AUTO_TRANSMIT_OBJ *obj = value;
+        snprintf(p->value, sizeof(p->value), "enable=%u index=%u interval=%u id=0x%x len=%u type=%u data0=0x%x data7=0x%x",
+                 obj->enable, obj->index, obj->interval, obj->obj.frame.can_id, obj->obj.frame.can_dlc,
+                 obj->obj.transmit_type, obj->obj.frame.data[0], obj->obj.frame.data[7]);
+    } else if (has_suffix(path, "/auto_send_canfd")) {
+        const ZCANFD_AUTO_TRANSMIT_OBJ *obj = value;
+        snprintf(p->value, sizeof(p->value), "enable=%u index=%u interval=%u id=0x%x len=%u type=%u data0=0x%x data63=0x%x",
+                 obj->enable, obj->index, obj->interval, obj->obj.frame.can_id, obj->obj.frame.len,
+                 obj->obj.transmit_type, obj->obj.frame.data[0], obj->obj.frame.data[63]);
+    } else {
+        snprintf(p->value, sizeof(p->value), "%s", (const char *)value);
+    }
+    return STATUS_OK;
+}
+
+static const char *get_value(const char *path)
+{
+    struct property *p;
+    if (prop_device < 0 || path == NULL || (p = find_property(path, 0)) == NULL) {
+        return NULL;
+    }
+    snprintf(value_buf, sizeof(value_buf), "%s", p->value);
+    return value_buf;
+}
+
+static IProperty iproperty = {set_value, get_value, NULL};
+
+IProperty *GetIProperty(DEVICE_HANDLE device_handle)
+{
+    struct device *d = find_device(device_handle);
+    if (d == NULL) {
+        return NULL;
+    }
+    prop_device = (int)(d - devices);
+    return &iproperty;
+}
+
+UINT ReleaseIProperty(IProperty *pIProperty)
+{
+    if (pIProperty != &iproperty) {
+        return STATUS_ERR;
+    }
+    prop_device = -1;
+    return STATUS_OK;
+}
@@ -0,0 +1,99 @@
+package zlgcan
+
+import (
+	"fmt"
+	"math"
+)
+
+// Clock of the SJA1000 compatible controllers (16 MHz oscillator, prescaled by 2).
+const sja1000Clock = 8000000
+
+type timingLimits struct {
+	brpMax   uint32
+	tseg1Max uint32
+	tseg2Max uint32
+}
+
+var (
+	sja1000Limits      = timingLimits{brpMax: 64, tseg1Max: 16, tseg2Max: 8}
+	canfdNominalLimits = timingLimits{brpMax: 1024, tseg1Max: 256, tseg2Max: 128}
+	canfdDataLimits    = timingLimits{brpMax: 32, tseg1Max: 32, tseg2Max: 16}
+)
+
+// bitTiming is a bit time split into time quanta. All fields hold real values, not register encodings.
+type bitTiming struct {
+	brp   uint32
+	tseg1 uint32 // prop + phase1 segment
+	tseg2 uint32 // phase2 segment
+	sjw   uint32
+}
+
+func (bt bitTiming) quanta() uint32 {
+	return 1 + bt.tseg1 + bt.tseg2
+}
+
+func (bt bitTiming) bitrate(clock uint32) uint32 {
+	return clock / (bt.brp * bt.quanta())
+}
+
+func (bt bitTiming) samplePoint() float64 {
+	return float64(1+bt.tseg1) / float64(bt.quanta())
+}
+
+// calcBitTiming finds the prescaler and segment split that hits bitrate exactly with the
+// sample point closest to samplePoint (a fraction, e.g. 0.8).
+func calcBitTiming(clock, bitrate uint32, samplePoint float64, lim timingLimits) (bitTiming, error) {
+	if bitrate == 0 || clock == 0 {
+		return bitTiming{}, fmt.Errorf("invalid bitrate %d or clock %d", bitrate, clock)
+	}
+	if samplePoint < 0.5 || samplePoint >= 1 {
+		return bitTiming{}, fmt.Errorf("sample point %.3f out of range [0.5, 1)", samplePoint)
+	}
+
+	best := bitTiming{}
+	bestErr := math.Inf(1)
+	for brp := uint32(1); brp <= lim.brpMax; brp++ {
+		if clock%(brp*bitrate) != 0 {
+			continue
+		}
+		tq := clock / (brp * bitrate)
+		if tq < 4 || tq > 1+lim.tseg1Max+lim.tseg2Max {
+			continue
+		}
+		split := uint32(math.Round(samplePoint * float64(tq)))
+		tseg1 := split - 1
+		tseg2 := tq - split
+		if tseg2 > lim.tseg2Max {
+			tseg2 = lim.tseg2Max
+			tseg1 = tq - 1 - tseg2
+		}
+		if tseg1 > lim.tseg1Max {
+			tseg1 = lim.tseg1Max
+			tseg2 = tq - 1 - tseg1
+		}
+		if tseg1 < 1 || tseg2 < 1 || tseg2 > lim.tseg2Max {
+			continue
+		}
+		bt := bitTiming{brp: brp, tseg1: tseg1, tseg2: tseg2, sjw: min(tseg2, 4)}
+		if spErr := math.Abs(bt.samplePoint() - samplePoint); spErr < bestErr-1e-9 {
+			best, bestErr = bt, spErr
+		}
+	}
+	if best.brp == 0 {
+		return bitTiming{}, fmt.Errorf("no bit timing for %d bps from a %d Hz clock", bitrate, clock)
+	}
+	return best, nil
+}
+
+// canfdTimingWord encodes bt in the AbitTiming/DbitTiming layout of the ZLG CANFD devices:
+// tseg1 [7:0], tseg2 [14:8], sjw [21:15], brp [31:22], each stored minus one.
+func canfdTimingWord(bt bitTiming) uint32 {
+	return (bt.tseg1 - 1) | (bt.tseg2-1)<<8 | (bt.sjw-1)<<15 | (bt.brp-1)<<22
+}
+
+// sja1000Timing encodes bt into the BTR0/BTR1 registers used as Timing0/Timing1.
+func sja1000Timing(bt bitTiming) (timing0, timing1 uint8) {
+	timing0 = uint8((bt.sjw-1)<<6 | (bt.brp - 1))
+	timing1 = uint8((bt.tseg2-1)<<4 | (bt.tseg1 - 1))
+	return timing0, timing1
+}
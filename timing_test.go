@@ -0,0 +1,73 @@
+package zlgcan
+
+import (
+	"math"
+	"testing"
+)
+
+// Test that the timing words match the ones used with the USBCANFD-200U at 60 MHz
+func TestCANFDTimingWord(t *testing.T) {
+	bt, err := calcBitTiming(60000000, 1000000, 0.8, canfdNominalLimits)
+	if err != nil {
+		t.Fatalf("calcBitTiming failed: %v", err)
+	}
+	if word := canfdTimingWord(bt); word != 101166 {
+		t.Fatalf("1Mbps nominal timing word: expected 101166, got %d (%+v)", word, bt)
+	}
+
+	bt, err = calcBitTiming(60000000, 500000, 0.8, canfdNominalLimits)
+	if err != nil {
+		t.Fatalf("calcBitTiming failed: %v", err)
+	}
+	if word := canfdTimingWord(bt); word != 104286 {
+		t.Fatalf("500kbps nominal timing word: expected 104286, got %d (%+v)", word, bt)
+	}
+}
+
+// Test that computed timings hit the requested bitrate and sample point
+func TestCalcBitTiming(t *testing.T) {
+	cases := []struct {
+		clock   uint32
+		bitrate uint32
+		sp      float64
+		lim     timingLimits
+	}{
+		{60000000, 2000000, 0.75, canfdDataLimits},
+		{60000000, 5000000, 0.75, canfdDataLimits},
+		{80000000, 4000000, 0.8, canfdDataLimits},
+		{60000000, 250000, 0.875, canfdNominalLimits},
+		{sja1000Clock, 500000, 0.875, sja1000Limits},
+		{sja1000Clock, 125000, 0.875, sja1000Limits},
+	}
+	for _, c := range cases {
+		bt, err := calcBitTiming(c.clock, c.bitrate, c.sp, c.lim)
+		if err != nil {
+			t.Fatalf("%d bps @ %d Hz: %v", c.bitrate, c.clock, err)
+		}
+		if bt.bitrate(c.clock) != c.bitrate || c.clock%(bt.brp*bt.quanta()) != 0 {
+			t.Fatalf("%d bps @ %d Hz: got %d bps (%+v)", c.bitrate, c.clock, bt.bitrate(c.clock), bt)
+		}
+		if math.Abs(bt.samplePoint()-c.sp) > 0.05 {
+			t.Fatalf("%d bps @ %d Hz: sample point %.3f, wanted %.3f", c.bitrate, c.clock, bt.samplePoint(), c.sp)
+		}
+		if bt.tseg1 > c.lim.tseg1Max || bt.tseg2 > c.lim.tseg2Max || bt.brp > c.lim.brpMax {
+			t.Fatalf("%d bps @ %d Hz: timing out of limits: %+v", c.bitrate, c.clock, bt)
+		}
+	}
+
+	if _, err := calcBitTiming(sja1000Clock, 3000000, 0.8, sja1000Limits); err == nil {
+		t.Fatalf("3Mbps should not be reachable on SJA1000")
+	}
+}
+
+// Test the SJA1000 BTR0/BTR1 encoding against the well known values
+func TestSJA1000Timing(t *testing.T) {
+	bt, err := calcBitTiming(sja1000Clock, 500000, 0.875, sja1000Limits)
+	if err != nil {
+		t.Fatalf("calcBitTiming failed: %v", err)
+	}
+	bt.sjw = 1
+	if t0, t1 := sja1000Timing(bt); t0 != 0x00 || t1 != 0x1C {
+		t.Fatalf("500kbps: expected 0x00/0x1C, got 0x%02x/0x%02x", t0, t1)
+	}
+}
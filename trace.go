@@ -0,0 +1,364 @@
+package zlgcan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// TraceCall is one library call in a trace written by WithTrace. Traces are JSON lines,
+// one call per line in the order the calls returned.
+type TraceCall struct {
+	Seq      uint64          `json:"seq"`
+	Start    time.Time       `json:"start"`
+	Duration time.Duration   `json:"duration_ns"`
+	Function string          `json:"function"`
+	Args     json.RawMessage `json:"args,omitempty"`
+	Result   json.RawMessage `json:"result,omitempty"`
+}
+
+// traceArgs holds the input arguments of a call by name.
+type traceArgs map[string]any
+
+// traceResult holds the return value and the output arguments of a call.
+type traceResult struct {
+	Ret       int64                  `json:"ret"`
+	Info      *traceDeviceInfo       `json:"info,omitempty"`
+	ErrInfo   *ZCAN_CHANNEL_ERR_INFO `json:"err_info,omitempty"`
+	Status    *ZCAN_CHANNEL_STATUS   `json:"status,omitempty"`
+	Msgs      []ZCAN_Receive_Data    `json:"msgs,omitempty"`
+	MsgsFD    []ZCAN_ReceiveFD_Data  `json:"msgs_fd,omitempty"`
+	Objs      []ZCAN_DATA_OBJ        `json:"objs,omitempty"`
+	IProperty int                    `json:"iproperty,omitempty"`
+	Value     string                 `json:"value,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+}
+
+// traceDeviceInfo is ZCAN_DEVICE_INFO with exported fields for encoding.
+type traceDeviceInfo struct {
+	HwVersion uint16    `json:"hw_version"`
+	FwVersion uint16    `json:"fw_version"`
+	DrVersion uint16    `json:"dr_version"`
+	InVersion uint16    `json:"in_version"`
+	IrqNum    uint16    `json:"irq_num"`
+	CanNum    uint8     `json:"can_num"`
+	SerialNum [20]uint8 `json:"serial_num"`
+	HwType    [40]uint8 `json:"hw_type"`
+	Reserved  [4]uint16 `json:"reserved"`
+}
+
+func newTraceDeviceInfo(info *ZCAN_DEVICE_INFO) *traceDeviceInfo {
+	return &traceDeviceInfo{
+		HwVersion: info.hw_Version,
+		FwVersion: info.fw_Version,
+		DrVersion: info.dr_Version,
+		InVersion: info.in_Version,
+		IrqNum:    info.irq_Num,
+		CanNum:    info.can_Num,
+		SerialNum: info.str_Serial_Num,
+		HwType:    info.str_hw_Type,
+		Reserved:  info.reserved,
+	}
+}
+
+func (t *traceDeviceInfo) deviceInfo() ZCAN_DEVICE_INFO {
+	return ZCAN_DEVICE_INFO{
+		hw_Version:     t.HwVersion,
+		fw_Version:     t.FwVersion,
+		dr_Version:     t.DrVersion,
+		in_Version:     t.InVersion,
+		irq_Num:        t.IrqNum,
+		can_Num:        t.CanNum,
+		str_Serial_Num: t.SerialNum,
+		str_hw_Type:    t.HwType,
+		reserved:       t.Reserved,
+	}
+}
+
+// ptrValue returns the struct behind a SetValuePtr value for the properties known to take
+// one, nil otherwise.
+func ptrValue(path string, value unsafe.Pointer) any {
+	switch {
+	case value == nil:
+		return nil
+	case strings.HasSuffix(path, "/auto_send_canfd"):
+		return (*ZCANFD_AUTO_TRANSMIT_OBJ)(value)
+	case strings.HasSuffix(path, "/auto_send"):
+		return (*ZCAN_AUTO_TRANSMIT_OBJ)(value)
+	}
+	return nil
+}
+
+// tracingDriver records every call to drv as a TraceCall.
+type tracingDriver struct {
+	drv    driver
+	logger *slog.Logger
+
+	mu          sync.Mutex
+	enc         *json.Encoder
+	seq         uint64
+	failed      bool
+	nextIP      int
+	iproperties map[*ZCAN_IProperty]int
+}
+
+func newTracingDriver(drv driver, w io.Writer, logger *slog.Logger) *tracingDriver {
+	return &tracingDriver{drv: drv, logger: logger, enc: json.NewEncoder(w), iproperties: make(map[*ZCAN_IProperty]int)}
+}
+
+// iproperty returns the number standing for iproperty in the trace.
+func (t *tracingDriver) iproperty(iproperty *ZCAN_IProperty) int {
+	if iproperty == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	id, ok := t.iproperties[iproperty]
+	if !ok {
+		t.nextIP++
+		id = t.nextIP
+		t.iproperties[iproperty] = id
+	}
+	return id
+}
+
+func (t *tracingDriver) record(function string, start time.Time, args traceArgs, result traceResult) {
+	call := TraceCall{Start: start, Duration: time.Since(start), Function: function}
+	var err error
+	if call.Args, err = json.Marshal(args); err == nil {
+		call.Result, err = json.Marshal(result)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.failed {
+		return
+	}
+	t.seq++
+	call.Seq = t.seq
+	if err == nil {
+		err = t.enc.Encode(call)
+	}
+	if err != nil {
+		// A broken trace is not worth failing the calls for; stop tracing.
+		t.failed = true
+		if t.logger != nil {
+			t.logger.Error("writing trace failed", slog.String("function", function), slog.Any("error", err))
+		}
+	}
+}
+
+func (t *tracingDriver) OpenDevice(deviceType int, deviceIndex int, reserved int) int {
+	start := time.Now()
+	ret := t.drv.OpenDevice(deviceType, deviceIndex, reserved)
+	t.record("ZCAN_OpenDevice", start, traceArgs{"device_type": deviceType, "device_index": deviceIndex, "reserved": reserved}, traceResult{Ret: int64(ret)})
+	return ret
+}
+
+func (t *tracingDriver) CloseDevice(deviceHandle int) int {
+	start := time.Now()
+	ret := t.drv.CloseDevice(deviceHandle)
+	t.record("ZCAN_CloseDevice", start, traceArgs{"handle": deviceHandle}, traceResult{Ret: int64(ret)})
+	return ret
+}
+
+func (t *tracingDriver) GetDeviceInf(deviceHandle int, info *ZCAN_DEVICE_INFO) uint {
+	start := time.Now()
+	ret := t.drv.GetDeviceInf(deviceHandle, info)
+	t.record("ZCAN_GetDeviceInf", start, traceArgs{"handle": deviceHandle}, traceResult{Ret: int64(ret), Info: newTraceDeviceInfo(info)})
+	return ret
+}
+
+func (t *tracingDriver) IsDeviceOnLine(deviceHandle int) int {
+	start := time.Now()
+	ret := t.drv.IsDeviceOnLine(deviceHandle)
+	t.record("ZCAN_IsDeviceOnLine", start, traceArgs{"handle": deviceHandle}, traceResult{Ret: int64(ret)})
+	return ret
+}
+
+func (t *tracingDriver) InitCAN(deviceHandle int, canIndex uint, initConfig *ZCAN_NORMAL_CHANNEL_INIT_CONFIG) int {
+	start := time.Now()
+	ret := t.drv.InitCAN(deviceHandle, canIndex, initConfig)
+	t.record("ZCAN_InitCAN", start, traceArgs{"handle": deviceHandle, "can_index": canIndex, "config": initConfig}, traceResult{Ret: int64(ret)})
+	return ret
+}
+
+func (t *tracingDriver) InitCANFD(deviceHandle int, canIndex uint, initConfig *ZCAN_CANFD_CHANNEL_INIT_CONFIG) int {
+	start := time.Now()
+	ret := t.drv.InitCANFD(deviceHandle, canIndex, initConfig)
+	t.record("ZCAN_InitCANFD", start, traceArgs{"handle": deviceHandle, "can_index": canIndex, "config_fd": initConfig}, traceResult{Ret: int64(ret)})
+	return ret
+}
+
+func (t *tracingDriver) StartCAN(channelHandle int) uint {
+	start := time.Now()
+	ret := t.drv.StartCAN(channelHandle)
+	t.record("ZCAN_StartCAN", start, traceArgs{"handle": channelHandle}, traceResult{Ret: int64(ret)})
+	return ret
+}
+
+func (t *tracingDriver) ResetCAN(channelHandle int) uint {
+	start := time.Now()
+	ret := t.drv.ResetCAN(channelHandle)
+	t.record("ZCAN_ResetCAN", start, traceArgs{"handle": channelHandle}, traceResult{Ret: int64(ret)})
+	return ret
+}
+
+func (t *tracingDriver) ClearBuffer(channelHandle int) uint {
+	start := time.Now()
+	ret := t.drv.ClearBuffer(channelHandle)
+	t.record("ZCAN_ClearBuffer", start, traceArgs{"handle": channelHandle}, traceResult{Ret: int64(ret)})
+	return ret
+}
+
+func (t *tracingDriver) ReadChannelErrInfo(channelHandle int, errInfo *ZCAN_CHANNEL_ERR_INFO) uint {
+	start := time.Now()
+	ret := t.drv.ReadChannelErrInfo(channelHandle, errInfo)
+	t.record("ZCAN_ReadChannelErrInfo", start, traceArgs{"handle": channelHandle}, traceResult{Ret: int64(ret), ErrInfo: errInfo})
+	return ret
+}
+
+func (t *tracingDriver) ReadChannelStatus(channelHandle int, status *ZCAN_CHANNEL_STATUS) uint {
+	start := time.Now()
+	ret := t.drv.ReadChannelStatus(channelHandle, status)
+	t.record("ZCAN_ReadChannelStatus", start, traceArgs{"handle": channelHandle}, traceResult{Ret: int64(ret), Status: status})
+	return ret
+}
+
+func (t *tracingDriver) GetReceiveNum(channelHandle int, canType uint) uint {
+	start := time.Now()
+	ret := t.drv.GetReceiveNum(channelHandle, canType)
+	t.record("ZCAN_GetReceiveNum", start, traceArgs{"handle": channelHandle, "can_type": canType}, traceResult{Ret: int64(ret)})
+	return ret
+}
+
+func (t *tracingDriver) Transmit(channelHandle int, msgs []ZCAN_Transmit_Data, n uint) uint {
+	start := time.Now()
+	ret := t.drv.Transmit(channelHandle, msgs, n)
+	t.record("ZCAN_Transmit", start, traceArgs{"handle": channelHandle, "msgs": msgs[:min(int(n), len(msgs))]}, traceResult{Ret: int64(ret)})
+	return ret
+}
+
+func (t *tracingDriver) Receive(channelHandle int, msgs []ZCAN_Receive_Data, waitTime int) uint {
+	start := time.Now()
+	ret := t.drv.Receive(channelHandle, msgs, waitTime)
+	t.record("ZCAN_Receive", start, traceArgs{"handle": channelHandle, "len": len(msgs), "wait_time": waitTime},
+		traceResult{Ret: int64(ret), Msgs: msgs[:min(int(ret), len(msgs))]})
+	return ret
+}
+
+func (t *tracingDriver) TransmitFD(channelHandle int, msgs []ZCAN_TransmitFD_Data, n uint) uint {
+	start := time.Now()
+	ret := t.drv.TransmitFD(channelHandle, msgs, n)
+	t.record("ZCAN_TransmitFD", start, traceArgs{"handle": channelHandle, "msgs_fd": msgs[:min(int(n), len(msgs))]}, traceResult{Ret: int64(ret)})
+	return ret
+}
+
+func (t *tracingDriver) ReceiveFD(channelHandle int, msgs []ZCAN_ReceiveFD_Data, waitTime int) uint {
+	start := time.Now()
+	ret := t.drv.ReceiveFD(channelHandle, msgs, waitTime)
+	t.record("ZCAN_ReceiveFD", start, traceArgs{"handle": channelHandle, "len": len(msgs), "wait_time": waitTime},
+		traceResult{Ret: int64(ret), MsgsFD: msgs[:min(int(ret), len(msgs))]})
+	return ret
+}
+
+func (t *tracingDriver) TransmitData(deviceHandle int, objs []ZCAN_DATA_OBJ, n uint) uint {
+	start := time.Now()
+	ret := t.drv.TransmitData(deviceHandle, objs, n)
+	t.record("ZCAN_TransmitData", start, traceArgs{"handle": deviceHandle, "objs": objs[:min(int(n), len(objs))]}, traceResult{Ret: int64(ret)})
+	return ret
+}
+
+func (t *tracingDriver) ReceiveData(deviceHandle int, objs []ZCAN_DATA_OBJ, waitTime int) uint {
+	start := time.Now()
+	ret := t.drv.ReceiveData(deviceHandle, objs, waitTime)
+	t.record("ZCAN_ReceiveData", start, traceArgs{"handle": deviceHandle, "len": len(objs), "wait_time": waitTime},
+		traceResult{Ret: int64(ret), Objs: objs[:min(int(ret), len(objs))]})
+	return ret
+}
+
+func (t *tracingDriver) GetIProperty(deviceHandle int) (*ZCAN_IProperty, error) {
+	start := time.Now()
+	ip, err := t.drv.GetIProperty(deviceHandle)
+	result := traceResult{IProperty: t.iproperty(ip)}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	t.record("GetIProperty", start, traceArgs{"handle": deviceHandle}, result)
+	return ip, err
+}
+
+func (t *tracingDriver) SetValue(iproperty *ZCAN_IProperty, path, value string) uint {
+	start := time.Now()
+	ret := t.drv.SetValue(iproperty, path, value)
+	t.record("SetValue", start, traceArgs{"iproperty": t.iproperty(iproperty), "path": path, "value": value}, traceResult{Ret: int64(ret)})
+	return ret
+}
+
+func (t *tracingDriver) SetValuePtr(iproperty *ZCAN_IProperty, path string, value unsafe.Pointer) uint {
+	start := time.Now()
+	ret := t.drv.SetValuePtr(iproperty, path, value)
+	t.record("SetValuePtr", start, traceArgs{"iproperty": t.iproperty(iproperty), "path": path, "value": ptrValue(path, value)}, traceResult{Ret: int64(ret)})
+	return ret
+}
+
+func (t *tracingDriver) GetValue(iproperty *ZCAN_IProperty, path string) string {
+	start := time.Now()
+	value := t.drv.GetValue(iproperty, path)
+	t.record("GetValue", start, traceArgs{"iproperty": t.iproperty(iproperty), "path": path}, traceResult{Value: value})
+	return value
+}
+
+// ReleaseIProperty forgets the number of iproperty once the driver released it. ZCAN only
+// releases an iproperty in the driver with its last reference, so every holder of a shared
+// interface is traced under the same number until then.
+func (t *tracingDriver) ReleaseIProperty(iproperty *ZCAN_IProperty) uint {
+	start := time.Now()
+	id := t.iproperty(iproperty)
+	ret := t.drv.ReleaseIProperty(iproperty)
+	if ret == ZCAN_STATUS_OK {
+		t.mu.Lock()
+		delete(t.iproperties, iproperty)
+		t.mu.Unlock()
+	}
+	t.record("ReleaseIProperty", start, traceArgs{"iproperty": id}, traceResult{Ret: int64(ret)})
+	return ret
+}
+
+func (t *tracingDriver) Close() error {
+	start := time.Now()
+	err := t.drv.Close()
+	var result traceResult
+	if err != nil {
+		result.Error = err.Error()
+	}
+	t.record("Close", start, nil, result)
+	return err
+}
+
+// WithTrace writes every library call made through the ZCAN to w as a TraceCall, for
+// replaying with NewReplay. Calls are written as they return; w is not closed.
+func WithTrace(w io.Writer) ZCANOption {
+	return func(o *zcanOptions) {
+		o.trace = w
+	}
+}
+
+// ReadTrace reads the calls of a trace written by WithTrace.
+func ReadTrace(r io.Reader) ([]TraceCall, error) {
+	var calls []TraceCall
+	dec := json.NewDecoder(r)
+	for {
+		var call TraceCall
+		if err := dec.Decode(&call); err == io.EOF {
+			return calls, nil
+		} else if err != nil {
+			return calls, fmt.Errorf("reading trace call %d: %w", len(calls)+1, err)
+		}
+		calls = append(calls, call)
+	}
+}
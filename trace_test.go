@@ -0,0 +1,136 @@
+package zlgcan
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// traceSession drives a short session through zc and returns what it observed.
+func traceSession(t *testing.T, zc *ZCAN) []string {
+	t.Helper()
+	var seen []string
+	handle := zc.OpenDevice(ZCAN_USBCANFD_200U, 0, 0)
+	if handle == INVALID_DEVICE_HANDLE {
+		t.Fatalf("Open Device failed")
+	}
+	info := zc.GetDeviceInf(handle)
+	if info == nil {
+		t.Fatalf("GetDeviceInf failed")
+	}
+	seen = append(seen, info.Serial())
+	tx, err := zc.OpenChannel(handle, 0)
+	if err != nil {
+		t.Fatalf("OpenChannel 0 failed: %v", err)
+	}
+	rx, err := zc.OpenChannel(handle, 1, WithTermination(true))
+	if err != nil {
+		t.Fatalf("OpenChannel 1 failed: %v", err)
+	}
+	auto := ZCANFD_AUTO_TRANSMIT_OBJ{Enable: 1, Index: 0, Interval: 100, Obj: newFDTransmit(0x7FF, ZCAN_TX_NORMAL)}
+	if err := tx.SetAutoSendFD(auto); err != nil {
+		t.Fatalf("SetAutoSendFD failed: %v", err)
+	}
+	msgs := []ZCAN_TransmitFD_Data{newFDTransmit(0x123, ZCAN_TX_NORMAL), newFDTransmit(0x124, ZCAN_TX_NORMAL)}
+	if sent := zc.TransmitFD(tx.Handle(), msgs, 2); sent != 2 {
+		t.Fatalf("Expected 2 frames sent, got %d", sent)
+	}
+	num := zc.GetReceiveNum(rx.Handle(), ZCAN_TYPE_CANFD)
+	rcv, n := rx.ReceiveFD(num, 0)
+	for _, r := range rcv[:n] {
+		seen = append(seen, fmt.Sprintf("%X %X", r.Frame.GetFrameID(), r.Frame.Data[:r.Frame.Len]))
+	}
+	if _, err := zc.ReadChannelErrInfo(rx.Handle()); err != nil {
+		t.Fatalf("ReadChannelErrInfo failed: %v", err)
+	}
+	zc.CloseDevice(handle)
+	return seen
+}
+
+func recordSession(t *testing.T) ([]byte, []string) {
+	t.Helper()
+	var trace bytes.Buffer
+	sim := NewSimulator()
+	if err := sim.AddDevice(ZCAN_USBCANFD_200U, 0, "TRACE0001"); err != nil {
+		t.Fatalf("AddDevice failed: %v", err)
+	}
+	zc := NewSimulatedZCAN(sim, WithTrace(&trace))
+	seen := traceSession(t, zc)
+	zc.Close()
+	return trace.Bytes(), seen
+}
+
+// Test the recorded calls, arguments and results
+func TestTrace(t *testing.T) {
+	trace, _ := recordSession(t)
+	calls, err := ReadTrace(bytes.NewReader(trace))
+	if err != nil {
+		t.Fatalf("ReadTrace failed: %v", err)
+	}
+	byFunction := make(map[string][]TraceCall)
+	for i, call := range calls {
+		if call.Seq != uint64(i+1) || call.Start.IsZero() {
+			t.Fatalf("Unexpected sequence or start in call %d: %+v", i, call)
+		}
+		byFunction[call.Function] = append(byFunction[call.Function], call)
+	}
+	if calls[len(calls)-1].Function != "Close" {
+		t.Fatalf("Expected Close last, got %s", calls[len(calls)-1].Function)
+	}
+
+	var result traceResult
+	json.Unmarshal(byFunction["ZCAN_GetDeviceInf"][0].Result, &result)
+	if info := result.Info.deviceInfo(); info.Serial() != "TRACE0001" {
+		t.Fatalf("Expected the device info in the result, got %q", info.Serial())
+	}
+	var args struct {
+		Handle int                    `json:"handle"`
+		MsgsFD []ZCAN_TransmitFD_Data `json:"msgs_fd"`
+	}
+	json.Unmarshal(byFunction["ZCAN_TransmitFD"][0].Args, &args)
+	if len(args.MsgsFD) != 2 || args.MsgsFD[1].Frame.GetFrameID() != 0x124 {
+		t.Fatalf("Expected the sent frames in the arguments, got %s", byFunction["ZCAN_TransmitFD"][0].Args)
+	}
+	var setArgs struct {
+		Path  string                   `json:"path"`
+		Value ZCANFD_AUTO_TRANSMIT_OBJ `json:"value"`
+	}
+	found := false
+	for _, call := range byFunction["SetValuePtr"] {
+		json.Unmarshal(call.Args, &setArgs)
+		if setArgs.Path == "0/auto_send_canfd" {
+			found = setArgs.Value.Interval == 100 && setArgs.Value.Obj.Frame.GetFrameID() == 0x7FF
+		}
+	}
+	if !found {
+		t.Fatalf("Expected the auto-send entry in the SetValuePtr arguments")
+	}
+	if len(byFunction["ZCAN_InitCANFD"]) != 2 || len(byFunction["ZCAN_InitCAN"]) != 0 {
+		t.Fatalf("Expected both CANFD channels recorded as ZCAN_InitCANFD, got %d and %d",
+			len(byFunction["ZCAN_InitCANFD"]), len(byFunction["ZCAN_InitCAN"]))
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+// Test that a failing trace does not break the calls
+func TestTraceWriteFailure(t *testing.T) {
+	sim := NewSimulator()
+	if err := sim.AddDevice(ZCAN_USBCANFD_200U, 0, "SIM0000"); err != nil {
+		t.Fatalf("AddDevice failed: %v", err)
+	}
+	zc := NewSimulatedZCAN(sim, WithTrace(failingWriter{}))
+	handle := zc.OpenDevice(ZCAN_USBCANFD_200U, 0, 0)
+	if handle == INVALID_DEVICE_HANDLE {
+		t.Fatalf("Open Device failed")
+	}
+	if info := zc.GetDeviceInf(handle); info == nil || info.Serial() != "SIM0000" {
+		t.Fatalf("GetDeviceInf failed after the trace broke")
+	}
+}
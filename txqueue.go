@@ -0,0 +1,293 @@
+package zlgcan
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrTxQueueFull   = errors.New("transmit queue full")
+	ErrTxQueueClosed = errors.New("transmit queue closed")
+	ErrTxFailed      = errors.New("frame not accepted by the driver")
+	ErrTxInvalid     = errors.New("exactly one of TxFrame.CAN and TxFrame.FD must be set")
+)
+
+// TxPriority orders frames in a TxQueue. Lower values are sent first.
+type TxPriority int
+
+const (
+	TxPriorityHigh TxPriority = iota
+	TxPriorityNormal
+	TxPriorityLow
+	txPriorityCount
+)
+
+// TxFrame is a frame queued for transmission. Exactly one of CAN and FD must be set.
+type TxFrame struct {
+	CAN      *ZCAN_Transmit_Data
+	FD       *ZCAN_TransmitFD_Data
+	Priority TxPriority
+	Result   func(TxResult) // optional, called once the frame is sent or given up
+}
+
+// TxResult reports the outcome of a queued frame.
+type TxResult struct {
+	Frame    TxFrame
+	Attempts int
+	Err      error
+}
+
+type txQueueOptions struct {
+	capacity      int
+	batchSize     int
+	maxRetries    int
+	retryInterval time.Duration
+	results       chan<- TxResult
+}
+
+// TxQueueOption configures a TxQueue.
+type TxQueueOption func(*txQueueOptions)
+
+// WithTxCapacity sets how many frames may wait in the queue before Submit blocks.
+func WithTxCapacity(n int) TxQueueOption {
+	return func(o *txQueueOptions) {
+		o.capacity = n
+	}
+}
+
+// WithTxBatchSize limits the number of frames passed to one Transmit call.
+func WithTxBatchSize(n int) TxQueueOption {
+	return func(o *txQueueOptions) {
+		o.batchSize = n
+	}
+}
+
+// WithTxRetries sets how often an unaccepted frame is retried and the pause between retries.
+func WithTxRetries(n int, interval time.Duration) TxQueueOption {
+	return func(o *txQueueOptions) {
+		o.maxRetries = n
+		o.retryInterval = interval
+	}
+}
+
+// WithTxResults delivers every TxResult to results. The channel must be drained.
+func WithTxResults(results chan<- TxResult) TxQueueOption {
+	return func(o *txQueueOptions) {
+		o.results = results
+	}
+}
+
+type txItem struct {
+	frame    TxFrame
+	attempts int
+}
+
+// TxQueue sends frames on a channel from a background goroutine. Frames the driver does
+// not accept are retried from the head of the queue, producers block while the queue is full.
+type TxQueue struct {
+	ch    *Channel
+	opts  txQueueOptions
+	slots chan struct{}
+	wake  chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+
+	mu     sync.Mutex
+	queues [txPriorityCount][]*txItem
+	closed bool
+}
+
+// NewTxQueue starts a transmit queue for ch.
+func NewTxQueue(ch *Channel, opts ...TxQueueOption) *TxQueue {
+	o := txQueueOptions{
+		capacity:      1024,
+		batchSize:     64,
+		maxRetries:    100,
+		retryInterval: time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.capacity = max(o.capacity, 1)
+	o.batchSize = max(o.batchSize, 1)
+
+	q := &TxQueue{
+		ch:    ch,
+		opts:  o,
+		slots: make(chan struct{}, o.capacity),
+		wake:  make(chan struct{}, 1),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// Submit queues f, blocking while the queue is full until ctx is done.
+func (q *TxQueue) Submit(ctx context.Context, f TxFrame) error {
+	if (f.CAN == nil) == (f.FD == nil) {
+		return ErrTxInvalid
+	}
+	select {
+	case q.slots <- struct{}{}:
+	case <-q.stop:
+		return ErrTxQueueClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return q.push(f)
+}
+
+// TrySubmit queues f or returns ErrTxQueueFull without blocking.
+func (q *TxQueue) TrySubmit(f TxFrame) error {
+	if (f.CAN == nil) == (f.FD == nil) {
+		return ErrTxInvalid
+	}
+	select {
+	case q.slots <- struct{}{}:
+	default:
+		return ErrTxQueueFull
+	}
+	return q.push(f)
+}
+
+func (q *TxQueue) push(f TxFrame) error {
+	prio := min(max(f.Priority, TxPriorityHigh), TxPriorityLow)
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		<-q.slots
+		return ErrTxQueueClosed
+	}
+	q.queues[prio] = append(q.queues[prio], &txItem{frame: f})
+	q.mu.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Len returns the number of frames waiting to be sent or in flight.
+func (q *TxQueue) Len() int {
+	return len(q.slots)
+}
+
+// Close stops the queue. Frames still waiting are reported with ErrTxQueueClosed.
+func (q *TxQueue) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		<-q.done
+		return
+	}
+	q.closed = true
+	close(q.stop)
+	q.mu.Unlock()
+	<-q.done
+
+	q.mu.Lock()
+	var left []*txItem
+	for prio := range q.queues {
+		left = append(left, q.queues[prio]...)
+		q.queues[prio] = nil
+	}
+	q.mu.Unlock()
+	for _, item := range left {
+		q.finish(item, ErrTxQueueClosed)
+	}
+}
+
+// batch returns the next frames to send: the head of the highest non-empty priority,
+// as long as they are of the same kind.
+func (q *TxQueue) batch() (TxPriority, []*txItem) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for prio := range q.queues {
+		items := q.queues[prio]
+		if len(items) == 0 {
+			continue
+		}
+		fd := items[0].frame.FD != nil
+		n := 0
+		for n < len(items) && n < q.opts.batchSize && (items[n].frame.FD != nil) == fd {
+			n++
+		}
+		return TxPriority(prio), items[:n]
+	}
+	return 0, nil
+}
+
+func (q *TxQueue) transmit(items []*txItem) int {
+	if items[0].frame.FD != nil {
+		msgs := make([]ZCAN_TransmitFD_Data, len(items))
+		for i, item := range items {
+			msgs[i] = *item.frame.FD
+		}
+		return int(q.ch.zc.TransmitFD(q.ch.Handle(), msgs, uint(len(msgs))))
+	}
+	msgs := make([]ZCAN_Transmit_Data, len(items))
+	for i, item := range items {
+		msgs[i] = *item.frame.CAN
+	}
+	return int(q.ch.zc.Transmit(q.ch.Handle(), msgs, uint(len(msgs))))
+}
+
+func (q *TxQueue) run() {
+	defer close(q.done)
+	for {
+		prio, items := q.batch()
+		if len(items) == 0 {
+			select {
+			case <-q.wake:
+				continue
+			case <-q.stop:
+				return
+			}
+		}
+
+		sent := min(max(q.transmit(items), 0), len(items))
+		var failed []*txItem
+		q.mu.Lock()
+		// Sent frames leave the head; the unsent tail stays in place and is retried.
+		q.queues[prio] = q.queues[prio][sent:]
+		// Only the frame the driver refused used up an attempt; the rest were never tried.
+		if sent < len(items) {
+			items[sent].attempts++
+			if items[sent].attempts > q.opts.maxRetries {
+				failed = append(failed, items[sent])
+				q.queues[prio] = q.queues[prio][1:]
+			}
+		}
+		q.mu.Unlock()
+
+		for _, item := range items[:sent] {
+			item.attempts++
+			q.finish(item, nil)
+		}
+		for _, item := range failed {
+			q.finish(item, ErrTxFailed)
+		}
+
+		if sent < len(items) {
+			select {
+			case <-time.After(q.opts.retryInterval):
+			case <-q.stop:
+				return
+			}
+		}
+	}
+}
+
+func (q *TxQueue) finish(item *txItem, err error) {
+	<-q.slots
+	result := TxResult{Frame: item.frame, Attempts: item.attempts, Err: err}
+	if item.frame.Result != nil {
+		item.frame.Result(result)
+	}
+	if q.opts.results != nil {
+		q.opts.results <- result
+	}
+}
@@ -0,0 +1,296 @@
+package zlgcan
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedDriver is a simulator whose Transmit accepts at most limit frames per call, all of
+// them while limit is negative.
+type gatedDriver struct {
+	*Simulator
+	mu    sync.Mutex
+	limit int
+	calls int
+	sent  []uint32
+}
+
+func (d *gatedDriver) setLimit(limit int) {
+	d.mu.Lock()
+	d.limit = limit
+	d.mu.Unlock()
+}
+
+// waitCalls waits until n more Transmit calls than seen were made.
+func (d *gatedDriver) waitCalls(t *testing.T, n int) {
+	t.Helper()
+	d.mu.Lock()
+	want := d.calls + n
+	d.mu.Unlock()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		d.mu.Lock()
+		calls := d.calls
+		d.mu.Unlock()
+		if calls >= want {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Expected %d transmit calls", want)
+}
+
+func (d *gatedDriver) sentIDs() []uint32 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]uint32(nil), d.sent...)
+}
+
+func (d *gatedDriver) Transmit(channelHandle int, msgs []ZCAN_Transmit_Data, n uint) uint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls++
+	if d.limit >= 0 {
+		n = min(n, uint(d.limit))
+	}
+	sent := d.Simulator.Transmit(channelHandle, msgs, n)
+	for _, msg := range msgs[:sent] {
+		d.sent = append(d.sent, msg.Frame.GetFrameID())
+	}
+	return sent
+}
+
+func (d *gatedDriver) TransmitFD(channelHandle int, msgs []ZCAN_TransmitFD_Data, n uint) uint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.calls++
+	if d.limit >= 0 {
+		n = min(n, uint(d.limit))
+	}
+	sent := d.Simulator.TransmitFD(channelHandle, msgs, n)
+	for _, msg := range msgs[:sent] {
+		d.sent = append(d.sent, msg.Frame.GetFrameID())
+	}
+	return sent
+}
+
+// newGatedChannel opens channel 0 of a simulated device behind a gatedDriver.
+func newGatedChannel(t *testing.T, limit int) (*gatedDriver, *Channel) {
+	t.Helper()
+	sim := NewSimulator()
+	if err := sim.AddDevice(ZCAN_USBCANFD_200U, 0, "SIM0000"); err != nil {
+		t.Fatalf("AddDevice failed: %v", err)
+	}
+	drv := &gatedDriver{Simulator: sim, limit: limit}
+	zc := newZCAN(drv, nil)
+	handle := zc.OpenDevice(ZCAN_USBCANFD_200U, 0, 0)
+	if handle == INVALID_DEVICE_HANDLE {
+		t.Fatalf("Open Device failed")
+	}
+	ch, err := zc.OpenChannel(handle, 0)
+	if err != nil {
+		t.Fatalf("OpenChannel failed: %v", err)
+	}
+	return drv, ch
+}
+
+func newTxCAN(id uint32) *ZCAN_Transmit_Data {
+	msg := &ZCAN_Transmit_Data{}
+	msg.Frame.GenerateID(id, 0, 0, 0)
+	msg.Frame.Dlc = 8
+	return msg
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func waitTxResults(t *testing.T, results <-chan TxResult, n int) []TxResult {
+	t.Helper()
+	var got []TxResult
+	for len(got) < n {
+		select {
+		case r := <-results:
+			got = append(got, r)
+		case <-time.After(time.Second):
+			t.Fatalf("Expected %d results, got %d", n, len(got))
+		}
+	}
+	return got
+}
+
+// Test that waiting frames go out by priority once the driver accepts them
+func TestTxQueuePriority(t *testing.T) {
+	drv, ch := newGatedChannel(t, 0)
+	results := make(chan TxResult, 8)
+	q := NewTxQueue(ch, WithTxRetries(1000, time.Millisecond), WithTxResults(results))
+	defer q.Close()
+
+	for _, f := range []TxFrame{
+		{CAN: newTxCAN(0x300), Priority: TxPriorityLow},
+		{CAN: newTxCAN(0x200), Priority: TxPriorityNormal},
+		{FD: ptr(newFDTransmit(0x101, ZCAN_TX_NORMAL)), Priority: TxPriorityHigh},
+		{CAN: newTxCAN(0x100), Priority: TxPriorityHigh},
+	} {
+		if err := q.Submit(context.Background(), f); err != nil {
+			t.Fatalf("Submit failed: %v", err)
+		}
+	}
+	// The second call picked its batch after all frames were queued.
+	drv.waitCalls(t, 2)
+	drv.setLimit(-1)
+
+	got := waitTxResults(t, results, 4)
+	for _, r := range got {
+		if r.Err != nil {
+			t.Fatalf("Unexpected result %+v", r)
+		}
+	}
+	ids := drv.sentIDs()
+	want := []uint32{0x101, 0x100, 0x200, 0x300}
+	if len(ids) != len(want) {
+		t.Fatalf("Expected %x sent, got %x", want, ids)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("Expected %x sent, got %x", want, ids)
+		}
+	}
+	if q.Len() != 0 {
+		t.Fatalf("Expected an empty queue, got %d", q.Len())
+	}
+}
+
+// Test that a partially accepted batch is retried from the first unsent frame
+func TestTxQueuePartialSend(t *testing.T) {
+	drv, ch := newGatedChannel(t, 2)
+	results := make(chan TxResult, 8)
+	q := NewTxQueue(ch, WithTxBatchSize(5), WithTxResults(results))
+	defer q.Close()
+
+	var callbacks sync.WaitGroup
+	callbacks.Add(5)
+	for i := 0; i < 5; i++ {
+		f := TxFrame{CAN: newTxCAN(uint32(0x10 + i)), Result: func(TxResult) { callbacks.Done() }}
+		if err := q.TrySubmit(f); err != nil {
+			t.Fatalf("TrySubmit failed: %v", err)
+		}
+	}
+	got := waitTxResults(t, results, 5)
+	callbacks.Wait()
+	for i, r := range got {
+		if r.Err != nil || r.Frame.CAN.Frame.GetFrameID() != uint32(0x10+i) || r.Attempts < 1 {
+			t.Fatalf("Unexpected result %d: %+v", i, r)
+		}
+	}
+	ids := drv.sentIDs()
+	for i, id := range ids {
+		if id != uint32(0x10+i) {
+			t.Fatalf("Frames sent out of order: %x", ids)
+		}
+	}
+}
+
+// Test that partial sends only count an attempt for the frame the driver refused
+func TestTxQueuePartialSendRetries(t *testing.T) {
+	drv, ch := newGatedChannel(t, 0)
+	results := make(chan TxResult, 8)
+	q := NewTxQueue(ch, WithTxBatchSize(6), WithTxRetries(1, 50*time.Millisecond), WithTxResults(results))
+	defer q.Close()
+
+	for i := 0; i < 6; i++ {
+		if err := q.TrySubmit(TxFrame{CAN: newTxCAN(uint32(0x10 + i))}); err != nil {
+			t.Fatalf("TrySubmit failed: %v", err)
+		}
+	}
+	// The first call refuses the whole batch, after that every call takes two frames and
+	// refuses the third.
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		drv.mu.Lock()
+		calls := drv.calls
+		if calls > 0 {
+			drv.limit = 2
+		}
+		drv.mu.Unlock()
+		if calls > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected a transmit call")
+		}
+	}
+	got := waitTxResults(t, results, 6)
+	for i, r := range got {
+		want := 1
+		if i%2 == 0 {
+			want = 2
+		}
+		if r.Err != nil || r.Frame.CAN.Frame.GetFrameID() != uint32(0x10+i) || r.Attempts != want {
+			t.Fatalf("Unexpected result %d: %+v, want %d attempt(s)", i, r, want)
+		}
+	}
+}
+
+// Test that a frame the driver keeps refusing is given up after the retries
+func TestTxQueueRetries(t *testing.T) {
+	_, ch := newGatedChannel(t, 0)
+	results := make(chan TxResult, 2)
+	q := NewTxQueue(ch, WithTxRetries(3, time.Millisecond), WithTxResults(results))
+	defer q.Close()
+
+	if err := q.TrySubmit(TxFrame{CAN: newTxCAN(0x1)}); err != nil {
+		t.Fatalf("TrySubmit failed: %v", err)
+	}
+	r := waitTxResults(t, results, 1)[0]
+	if !errors.Is(r.Err, ErrTxFailed) || r.Attempts != 4 {
+		t.Fatalf("Expected ErrTxFailed after 4 attempts, got %+v", r)
+	}
+	if err := q.TrySubmit(TxFrame{}); !errors.Is(err, ErrTxInvalid) {
+		t.Fatalf("Expected ErrTxInvalid, got %v", err)
+	}
+}
+
+// Test that producers are held back while the queue is full and released by Close
+func TestTxQueueBackpressure(t *testing.T) {
+	_, ch := newGatedChannel(t, 0)
+	results := make(chan TxResult, 4)
+	q := NewTxQueue(ch, WithTxCapacity(2), WithTxRetries(1000, time.Millisecond), WithTxResults(results))
+
+	for i := 0; i < 2; i++ {
+		if err := q.TrySubmit(TxFrame{CAN: newTxCAN(uint32(i))}); err != nil {
+			t.Fatalf("TrySubmit failed: %v", err)
+		}
+	}
+	if err := q.TrySubmit(TxFrame{CAN: newTxCAN(2)}); !errors.Is(err, ErrTxQueueFull) {
+		t.Fatalf("Expected ErrTxQueueFull, got %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.Submit(ctx, TxFrame{CAN: newTxCAN(2)}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the deadline to end Submit, got %v", err)
+	}
+	if q.Len() != 2 {
+		t.Fatalf("Expected 2 queued frames, got %d", q.Len())
+	}
+
+	blocked := make(chan error, 1)
+	go func() {
+		blocked <- q.Submit(context.Background(), TxFrame{CAN: newTxCAN(3)})
+	}()
+	q.Close()
+	if err := <-blocked; !errors.Is(err, ErrTxQueueClosed) {
+		t.Fatalf("Expected the blocked producer to see ErrTxQueueClosed, got %v", err)
+	}
+	for _, r := range waitTxResults(t, results, 2) {
+		if !errors.Is(r.Err, ErrTxQueueClosed) {
+			t.Fatalf("Expected waiting frames reported closed, got %+v", r)
+		}
+	}
+	if err := q.TrySubmit(TxFrame{CAN: newTxCAN(4)}); !errors.Is(err, ErrTxQueueClosed) {
+		t.Fatalf("Expected ErrTxQueueClosed after Close, got %v", err)
+	}
+	q.Close()
+}
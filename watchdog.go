@@ -0,0 +1,266 @@
+package zlgcan
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// WatchdogEventType tells what a WatchdogEvent reports.
+type WatchdogEventType uint8
+
+const (
+	DeviceDisconnected WatchdogEventType = iota
+	DeviceReconnected
+	DeviceReconnectFailed // the device is back but replaying its setup failed
+)
+
+func (t WatchdogEventType) String() string {
+	switch t {
+	case DeviceDisconnected:
+		return "disconnected"
+	case DeviceReconnected:
+		return "reconnected"
+	case DeviceReconnectFailed:
+		return "reconnect failed"
+	}
+	return fmt.Sprintf("event(%d)", uint8(t))
+}
+
+// WatchdogEvent reports a disconnect or reconnect of a watched device.
+type WatchdogEvent struct {
+	Type   WatchdogEventType
+	Time   time.Time
+	Handle int // device handle after the event, INVALID_DEVICE_HANDLE while disconnected
+	Err    error
+}
+
+type watchdogOptions struct {
+	interval time.Duration
+	events   chan<- WatchdogEvent
+}
+
+// WatchdogOption configures a Watchdog.
+type WatchdogOption func(*watchdogOptions)
+
+// WithWatchdogInterval sets how often the device is checked and, while it is gone, how
+// often reopening it is tried.
+func WithWatchdogInterval(d time.Duration) WatchdogOption {
+	return func(o *watchdogOptions) {
+		o.interval = d
+	}
+}
+
+// WithWatchdogEvents delivers every WatchdogEvent to events. The channel must be drained.
+func WithWatchdogEvents(events chan<- WatchdogEvent) WatchdogOption {
+	return func(o *watchdogOptions) {
+		o.events = events
+	}
+}
+
+// Watchdog detects when a USB device is unplugged and, once it is back, reopens it by
+// serial and replays its setup: properties set through the watchdog, then every watched
+// channel with its init options, filters and auto-send table. Channels keep their identity,
+// so queues, schedulers and supervisors using them carry on with the new handles.
+type Watchdog struct {
+	zc          *ZCAN
+	deviceType  int
+	deviceIndex int
+	serial      string
+	opts        watchdogOptions
+
+	mu       sync.Mutex
+	handle   int
+	props    []property
+	channels []*Channel
+	running  bool
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewWatchdog creates a stopped watchdog for the open device deviceHandle.
+func NewWatchdog(zc *ZCAN, deviceHandle int, opts ...WatchdogOption) (*Watchdog, error) {
+	entry, ok := zc.device(deviceHandle)
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownDevice, deviceHandle)
+	}
+	o := watchdogOptions{interval: 500 * time.Millisecond}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.interval = max(o.interval, time.Millisecond)
+
+	w := &Watchdog{zc: zc, deviceType: entry.deviceType, deviceIndex: entry.deviceIndex, opts: o, handle: deviceHandle}
+	if info := zc.deviceInfo(entry, deviceHandle); info != nil {
+		w.serial = info.Serial()
+	}
+	return w, nil
+}
+
+// Handle returns the current device handle, INVALID_DEVICE_HANDLE while disconnected.
+func (w *Watchdog) Handle() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.handle
+}
+
+// Watch adds ch to the channels restored after a reconnect.
+func (w *Watchdog) Watch(ch *Channel) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if ch.Device() != w.handle {
+		return fmt.Errorf("channel %d belongs to device %d, not %d", ch.index, ch.Device(), w.handle)
+	}
+	for _, c := range w.channels {
+		if c == ch {
+			return nil
+		}
+	}
+	w.channels = append(w.channels, ch)
+	return nil
+}
+
+// recordProperty replaces the value of an already recorded path in place, so the replay
+// keeps the order in which paths were first set.
+func recordProperty(props []property, p property) []property {
+	for i := range props {
+		if props[i].path == p.path {
+			props[i].value = p.value
+			return props
+		}
+	}
+	return append(props, p)
+}
+
+// SetProperty sets a device property and records it for replay after a reconnect.
+// Properties are replayed before the channels are restored.
+func (w *Watchdog) SetProperty(path, value string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.zc.setProperties(w.handle, []property{{path, value}}); err != nil {
+		return err
+	}
+	w.props = recordProperty(w.props, property{path, value})
+	return nil
+}
+
+// Start begins watching the device.
+func (w *Watchdog) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.running {
+		return
+	}
+	w.running = true
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	go w.run(w.stop, w.done)
+}
+
+// Stop halts the watchdog and waits for a running reconnect to finish.
+func (w *Watchdog) Stop() {
+	w.mu.Lock()
+	if !w.running {
+		w.mu.Unlock()
+		return
+	}
+	w.running = false
+	close(w.stop)
+	done := w.done
+	w.mu.Unlock()
+	<-done
+}
+
+func (w *Watchdog) emit(e WatchdogEvent) {
+	e.Time = time.Now()
+	if w.opts.events != nil {
+		w.opts.events <- e
+	}
+}
+
+// onlineState interprets ZCAN_IsDeviceOnLine. Devices that cannot tell report neither.
+func onlineState(ret int) (online, offline bool) {
+	return ret == ZCAN_STATUS_ONLINE, ret == ZCAN_STATUS_OFFLINE
+}
+
+func (w *Watchdog) reopen() (int, error) {
+	if w.serial != "" {
+		return w.zc.OpenBySerial(w.deviceType, w.serial)
+	}
+	return w.zc.OpenConfigured(DeviceConfig{DeviceType: w.deviceType, DeviceIndex: w.deviceIndex})
+}
+
+// replay restores the recorded setup on the reopened device. Every step is tried, the
+// errors are joined.
+func (w *Watchdog) replay(handle int) error {
+	var errs []error
+	if err := w.zc.setProperties(handle, w.props); err != nil {
+		errs = append(errs, err)
+	}
+	for _, ch := range w.channels {
+		if err := ch.moveTo(handle); err != nil {
+			errs = append(errs, fmt.Errorf("channel %d: %w", ch.index, err))
+			continue
+		}
+		ch.mu.Lock()
+		entries := make(map[uint16]autoSendEntry, len(ch.autoSend))
+		for index, e := range ch.autoSend {
+			entries[index] = e
+		}
+		started := ch.autoSendStarted
+		ch.mu.Unlock()
+		for _, index := range ch.AutoSendIndexes() {
+			if err := ch.writeAutoSend(entries[index]); err != nil {
+				errs = append(errs, fmt.Errorf("channel %d auto-send %d: %w", ch.index, index, err))
+			}
+		}
+		if started {
+			if err := ch.StartAutoSend(); err != nil {
+				errs = append(errs, fmt.Errorf("channel %d: %w", ch.index, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// check polls the device once and handles a disconnect or reconnect. The returned event
+// is emitted by the caller once the lock is released.
+func (w *Watchdog) check() (WatchdogEvent, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.handle != INVALID_DEVICE_HANDLE {
+		if _, offline := onlineState(w.zc.IsDeviceOnLine(w.handle)); !offline {
+			return WatchdogEvent{}, false
+		}
+		w.zc.CloseDevice(w.handle)
+		w.handle = INVALID_DEVICE_HANDLE
+		return WatchdogEvent{Type: DeviceDisconnected, Handle: INVALID_DEVICE_HANDLE}, true
+	}
+
+	handle, err := w.reopen()
+	if err != nil {
+		return WatchdogEvent{}, false // still unplugged
+	}
+	w.handle = handle
+	if err := w.replay(handle); err != nil {
+		return WatchdogEvent{Type: DeviceReconnectFailed, Handle: handle, Err: err}, true
+	}
+	return WatchdogEvent{Type: DeviceReconnected, Handle: handle}, true
+}
+
+func (w *Watchdog) run(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(w.opts.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if e, ok := w.check(); ok {
+				w.emit(e)
+			}
+		}
+	}
+}
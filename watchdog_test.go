@@ -0,0 +1,120 @@
+package zlgcan
+
+import (
+	"testing"
+	"time"
+)
+
+// Test for the replay order of recorded properties
+func TestRecordProperty(t *testing.T) {
+	var props []property
+	props = recordProperty(props, property{"0/set_device_recv_merge", "1"})
+	props = recordProperty(props, property{"0/set_bus_usage_period", "200"})
+	props = recordProperty(props, property{"0/set_device_recv_merge", "0"})
+	if len(props) != 2 || props[0] != (property{"0/set_device_recv_merge", "0"}) || props[1].path != "0/set_bus_usage_period" {
+		t.Fatalf("Unexpected properties: %v", props)
+	}
+}
+
+// Test for ZCAN_IsDeviceOnLine interpretation
+func TestOnlineState(t *testing.T) {
+	cases := []struct {
+		ret             int
+		online, offline bool
+	}{
+		{ZCAN_STATUS_ONLINE, true, false},
+		{ZCAN_STATUS_OFFLINE, false, true},
+		{ZCAN_STATUS_UNSUPPORTED, false, false},
+		{ZCAN_STATUS_ERR, false, false},
+	}
+	for _, c := range cases {
+		if online, offline := onlineState(c.ret); online != c.online || offline != c.offline {
+			t.Fatalf("Unexpected state for %d: online %v offline %v", c.ret, online, offline)
+		}
+	}
+}
+
+// Test that a watchdog needs a tracked device
+func TestNewWatchdogUnknownDevice(t *testing.T) {
+	zc := newTestZCAN(1, ZCAN_USBCANFD_200U, 2)
+	if _, err := NewWatchdog(zc, 2); err == nil {
+		t.Fatalf("Expected an error for an unknown device")
+	}
+}
+
+func nextWatchdogEvent(t *testing.T, events <-chan WatchdogEvent) WatchdogEvent {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(time.Second):
+		t.Fatalf("Expected a watchdog event")
+	}
+	return WatchdogEvent{}
+}
+
+// Test unplugging and replugging a simulated device
+func TestWatchdogSimulated(t *testing.T) {
+	sim := NewSimulator()
+	zc, handle := newSimulatedDevice(t, sim, ZCAN_USBCANFD_200U, 0)
+	ch, err := zc.OpenChannel(handle, 1)
+	if err != nil {
+		t.Fatalf("OpenChannel failed: %v", err)
+	}
+	oldHandle := ch.Handle()
+	obj, _ := NewAutoSendFD(1, 100*time.Millisecond, newFDTransmit(0x7FF, ZCAN_TX_NORMAL))
+	if err := ch.SetAutoSendFD(obj); err != nil {
+		t.Fatalf("SetAutoSendFD failed: %v", err)
+	}
+	if err := ch.StartAutoSend(); err != nil {
+		t.Fatalf("StartAutoSend failed: %v", err)
+	}
+
+	events := make(chan WatchdogEvent, 4)
+	w, err := NewWatchdog(zc, handle, WithWatchdogInterval(time.Millisecond), WithWatchdogEvents(events))
+	if err != nil {
+		t.Fatalf("NewWatchdog failed: %v", err)
+	}
+	if err := w.SetProperty("1/set_bus_usage_period", "200"); err != nil {
+		t.Fatalf("SetProperty failed: %v", err)
+	}
+	if err := w.Watch(ch); err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	w.Start()
+	defer w.Stop()
+
+	sim.SetOnline(ZCAN_USBCANFD_200U, 0, false)
+	if e := nextWatchdogEvent(t, events); e.Type != DeviceDisconnected || e.Handle != INVALID_DEVICE_HANDLE {
+		t.Fatalf("Expected a disconnect, got %+v", e)
+	}
+	if w.Handle() != INVALID_DEVICE_HANDLE {
+		t.Fatalf("Expected no device handle while disconnected, got %d", w.Handle())
+	}
+
+	sim.SetOnline(ZCAN_USBCANFD_200U, 0, true)
+	e := nextWatchdogEvent(t, events)
+	if e.Type != DeviceReconnected || e.Err != nil || e.Handle == INVALID_DEVICE_HANDLE || e.Handle == handle {
+		t.Fatalf("Expected a reconnect with a new handle, got %+v", e)
+	}
+	if w.Handle() != e.Handle || ch.Device() != e.Handle || ch.Handle() == oldHandle {
+		t.Fatalf("Expected the channel moved to device %d, got device %d channel %d", e.Handle, ch.Device(), ch.Handle())
+	}
+
+	// The reopened device starts without properties, so these were replayed.
+	for _, path := range []string{"1/set_bus_usage_period", "1/auto_send_canfd", "1/apply_auto_send"} {
+		if _, ok := sim.Property(ZCAN_USBCANFD_200U, 0, path); !ok {
+			t.Fatalf("Expected %s replayed", path)
+		}
+	}
+	if v, _ := sim.Property(ZCAN_USBCANFD_200U, 0, "1/set_bus_usage_period"); v != "200" {
+		t.Fatalf("Expected the bus usage period 200, got %q", v)
+	}
+	if got := ch.AutoSendIndexes(); len(got) != 1 || got[0] != 1 {
+		t.Fatalf("Expected auto-send slot 1 kept, got %v", got)
+	}
+	msgs := []ZCAN_TransmitFD_Data{newFDTransmit(0x100, ZCAN_TX_NORMAL)}
+	if sent := zc.TransmitFD(ch.Handle(), msgs, 1); sent != 1 {
+		t.Fatalf("Expected the restored channel to send, got %d", sent)
+	}
+}
@@ -1,13 +1,10 @@
 package zlgcan
 
-/*
-#include <stdlib.h>
-*/
-import "C"
 import (
+	"errors"
 	"fmt"
-	"runtime"
-	"syscall"
+	"log/slog"
+	"sync"
 	"unsafe"
 )
 
@@ -96,10 +93,32 @@ const (
 )
 
 const (
-	ZCAN_TYPE_CAN   = 0x0
-	ZCAN_TYPE_CANFD = 0x1
+	ZCAN_TYPE_CAN      = 0x0
+	ZCAN_TYPE_CANFD    = 0x1
+	ZCAN_TYPE_ALL_DATA = 0x2
 )
 
+// ChannelMode is the working mode of a channel, set in the Mode field of the init configs.
+type ChannelMode uint8
+
+const (
+	ZCAN_MODE_NORMAL      ChannelMode = 0
+	ZCAN_MODE_LISTEN_ONLY ChannelMode = 1 // receive without acknowledging or transmitting
+	ZCAN_MODE_LOOPBACK    ChannelMode = 2 // self test, frames are looped back inside the controller
+)
+
+func (m ChannelMode) String() string {
+	switch m {
+	case ZCAN_MODE_NORMAL:
+		return "normal"
+	case ZCAN_MODE_LISTEN_ONLY:
+		return "listen-only"
+	case ZCAN_MODE_LOOPBACK:
+		return "loopback"
+	}
+	return fmt.Sprintf("mode(%d)", uint8(m))
+}
+
 const (
 	INVALID_DEVICE_HANDLE  = 0
 	INVALID_CHANNEL_HANDLE = 0
@@ -114,7 +133,7 @@ type ZCAN_DEVICE_INFO struct {
 	can_Num        uint8
 	str_Serial_Num [20]uint8
 	str_hw_Type    [40]uint8
-	reserved       [8]uint16
+	reserved       [4]uint16
 }
 
 func (info *ZCAN_DEVICE_INFO) _version(version uint16) string {
@@ -179,7 +198,8 @@ type _ZCAN_CHANNEL_CAN_INIT_CONFIG struct {
 	Filter   uint8
 	Timing0  uint8
 	Timing1  uint8
-	Mode     uint8
+	Mode     ChannelMode
+	_        [12]uint8 // the C union is as large as the CANFD variant
 }
 type _ZCAN_CHANNEL_CANFD_INIT_CONFIG struct {
 	AccCode    uint32
@@ -188,7 +208,7 @@ type _ZCAN_CHANNEL_CANFD_INIT_CONFIG struct {
 	DbitTiming uint32
 	Brp        uint32
 	Filter     uint8
-	Mode       uint8
+	Mode       ChannelMode
 	Pad        uint16
 	Reserved   uint32
 }
@@ -282,26 +302,69 @@ func (f *ZCAN_CANFD_FRAME) GetFrameEFF() uint8 {
 	return uint8(f.Id & 0x01)
 }
 
+// Bits of ZCAN_CANFD_FRAME.Flags as laid out in the vendor header. The bits above
+// CANFD_ESI are returned by GetFrameRES, TX_DELAY_SEND_FLAG is one of them.
+const (
+	CANFD_BRS = 0x01 // bit rate switch, the data phase uses the data bitrate
+	CANFD_ESI = 0x02 // error state indicator of the transmitting node
+)
+
+// GenerateFlags sets Flags to brs at CANFD_BRS, esi at CANFD_ESI and res in the bits above.
 func (f *ZCAN_CANFD_FRAME) GenerateFlags(brs, esi, res uint8) {
-	flags := ((brs << 7) | (esi << 6) | res)
+	flags := ((res << 2) | (esi << 1) | brs)
 	f.Flags = flags
 }
 
 func (f *ZCAN_CANFD_FRAME) GetFrameBRS() uint8 {
-	return uint8(f.Flags&0x80) >> 7
+	return f.Flags & CANFD_BRS
 }
 
 func (f *ZCAN_CANFD_FRAME) GetFrameESI() uint8 {
-	return uint8(f.Flags&0x40) >> 6
+	return (f.Flags & CANFD_ESI) >> 1
 }
 
 func (f *ZCAN_CANFD_FRAME) GetFrameRES() uint8 {
-	return uint8(f.Flags & 0x3F)
+	return f.Flags >> 2
+}
+
+// TransmitType selects how a frame is sent, set in the Type field of the transmit data.
+type TransmitType uint32
+
+const (
+	ZCAN_TX_NORMAL              TransmitType = 0 // retransmit until acknowledged
+	ZCAN_TX_SINGLE_SHOT         TransmitType = 1 // no retransmission on error or lost arbitration
+	ZCAN_TX_SELF_RX             TransmitType = 2 // normal send, the frame is echoed into the receive stream
+	ZCAN_TX_SINGLE_SHOT_SELF_RX TransmitType = 3 // single shot send with echo
+)
+
+func (t TransmitType) String() string {
+	switch t {
+	case ZCAN_TX_NORMAL:
+		return "normal"
+	case ZCAN_TX_SINGLE_SHOT:
+		return "single-shot"
+	case ZCAN_TX_SELF_RX:
+		return "self-rx"
+	case ZCAN_TX_SINGLE_SHOT_SELF_RX:
+		return "single-shot-self-rx"
+	}
+	return fmt.Sprintf("type(%d)", uint32(t))
+}
+
+// SelfRx returns the echoing variant of t.
+func (t TransmitType) SelfRx() TransmitType {
+	if t == ZCAN_TX_SINGLE_SHOT {
+		return ZCAN_TX_SINGLE_SHOT_SELF_RX
+	}
+	if t == ZCAN_TX_NORMAL {
+		return ZCAN_TX_SELF_RX
+	}
+	return t
 }
 
 type ZCAN_Transmit_Data struct {
 	Frame ZCAN_CAN_FRAME
-	Type  uint32
+	Type  TransmitType
 }
 type ZCAN_Receive_Data struct {
 	Frame     ZCAN_CAN_FRAME
@@ -309,7 +372,7 @@ type ZCAN_Receive_Data struct {
 }
 type ZCAN_TransmitFD_Data struct {
 	Frame ZCAN_CANFD_FRAME
-	Type  uint32
+	Type  TransmitType
 }
 type ZCAN_ReceiveFD_Data struct {
 	Frame     ZCAN_CANFD_FRAME
@@ -333,135 +396,197 @@ type ZCAN_IProperty struct {
 	GetPropertys *[0]byte
 }
 
+// ZCAN wraps the vendor library. All methods may be called from several goroutines: calls
+// that the library does not allow concurrently are serialized per channel (receiving and
+// clearing) and across devices (IProperty access), sending stays independent of receiving.
 type ZCAN struct {
-	dll syscall.Handle
+	drv    driver
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	devices map[int]*deviceEntry
+	chLocks map[int]*channelLocks
+
+	// propMu serializes IProperty use. The library may hand out one interface shared by
+	// all devices, so the lock cannot be per device.
+	propMu sync.Mutex
+	// iproperties counts the references to each iproperty handed out by GetIProperty. The
+	// library may hand out the same pointer again before it is released; it is released
+	// in the driver with the last reference.
+	iproperties map[*ZCAN_IProperty]int
+}
+
+var errNoIProperty = errors.New("error calling GetIProperty: no property interface")
+
+func NewZCAN(dllPath string, opts ...ZCANOption) (*ZCAN, error) {
+	drv, err := loadDriver(dllPath)
+	zc := newZCAN(drv, opts)
+	if err != nil {
+		zc.log(slog.LevelError, "loading library failed", slog.String("function", "NewZCAN"), slog.String("path", dllPath), slog.Any("error", err))
+		return nil, err
+	}
+	return zc, nil
 }
 
-func NewZCAN(dllPath string) (*ZCAN, error) {
-	var dll syscall.Handle
-	if runtime.GOOS == "windows" {
-		dll, _ = syscall.LoadLibrary(".\\zlgcan_x64\\zlgcan.dll")
-	} else {
-		fmt.Println("No support now!")
-		return nil, fmt.Errorf("unsupported OS")
+// Close unloads the library. Devices should be closed first.
+func (zc *ZCAN) Close() error {
+	if zc.drv == nil {
+		return nil
 	}
-	return &ZCAN{dll: dll}, nil
+	return zc.drv.Close()
 }
 
 func (zc *ZCAN) OpenDevice(deviceType int, deviceIndex int, reserved int) int {
-	if zc.dll == 0 {
+	return zc.openDevice(deviceType, deviceIndex, reserved, slog.LevelError)
+}
+
+// openDevice is OpenDevice logging a failure at level.
+func (zc *ZCAN) openDevice(deviceType int, deviceIndex int, reserved int, level slog.Level) int {
+	if zc.drv == nil {
 		return -1
 	}
-	openDevice, _ := syscall.GetProcAddress(zc.dll, "ZCAN_OpenDevice")
-	ret, _, _ := syscall.SyscallN(
-		openDevice,
-		uintptr(deviceType),
-		uintptr(deviceIndex),
-		uintptr(reserved))
-	return int(ret)
+	ret := zc.drv.OpenDevice(deviceType, deviceIndex, reserved)
+	if ret == INVALID_DEVICE_HANDLE {
+		zc.log(level, "library call failed", slog.String("function", "ZCAN_OpenDevice"),
+			slog.String("device_type", deviceTypeName(deviceType)), slog.Int("device_index", deviceIndex))
+		return ret
+	}
+	zc.trackDevice(ret, deviceType, deviceIndex)
+	return ret
 }
 
 func (zc *ZCAN) CloseDevice(deviceHandle int) int {
-	if zc.dll == 0 {
+	if zc.drv == nil {
 		return -1
 	}
-	closeDevice, _ := syscall.GetProcAddress(zc.dll, "ZCAN_CloseDevice")
-	ret, _, _ := syscall.SyscallN(closeDevice, uintptr(deviceHandle))
-	return int(ret)
+	ret := zc.drv.CloseDevice(deviceHandle)
+	zc.untrackDevice(deviceHandle)
+	return ret
 }
 
 func (zc *ZCAN) GetDeviceInf(deviceHandle int) *ZCAN_DEVICE_INFO {
 	info := ZCAN_DEVICE_INFO{}
-	getDeviceInf, _ := syscall.GetProcAddress(zc.dll, "ZCAN_GetDeviceInf")
-	ret, _, _ := syscall.SyscallN(getDeviceInf, uintptr(deviceHandle), uintptr(unsafe.Pointer(&info)))
+	ret := zc.drv.GetDeviceInf(deviceHandle, &info)
 	if ret == ZCAN_STATUS_OK {
-		return (*ZCAN_DEVICE_INFO)(&info)
+		return &info
 	}
-	fmt.Println("error calling ZCAN_GetDeviceInf:", ret)
+	zc.logStatus("ZCAN_GetDeviceInf", ret, zc.deviceAttrs(deviceHandle)...)
 	return nil
 }
 
 func (zc *ZCAN) IsDeviceOnLine(deviceHandle int) int {
-	isDeviceOnline, _ := syscall.GetProcAddress(zc.dll, "ZCAN_IsDeviceOnLine")
-	ret, _, _ := syscall.SyscallN(isDeviceOnline, uintptr(deviceHandle))
-	return int(ret)
+	return zc.drv.IsDeviceOnLine(deviceHandle)
 }
 
 func (zc *ZCAN) InitCAN(deviceHandle int, canIndex uint, initConfig *ZCAN_NORMAL_CHANNEL_INIT_CONFIG) int {
-	initCAN, _ := syscall.GetProcAddress(zc.dll, "ZCAN_InitCAN")
-	ret, _, _ := syscall.SyscallN(initCAN, uintptr(deviceHandle), uintptr(canIndex), uintptr(unsafe.Pointer(initConfig)))
-	return int(ret)
+	handle, err := zc.InitCANChecked(deviceHandle, canIndex, initConfig)
+	if err != nil {
+		zc.log(slog.LevelError, "initializing channel failed", append(zc.deviceAttrs(deviceHandle),
+			slog.String("function", "ZCAN_InitCAN"), slog.Uint64("channel", uint64(canIndex)), slog.Any("error", err))...)
+	}
+	return handle
 }
 
 func (zc *ZCAN) InitCANFD(deviceHandle int, canIndex uint, initConfig *ZCAN_CANFD_CHANNEL_INIT_CONFIG) int {
-	initCAN, _ := syscall.GetProcAddress(zc.dll, "ZCAN_InitCAN")
-	ret, _, _ := syscall.SyscallN(initCAN, uintptr(deviceHandle), uintptr(canIndex), uintptr(unsafe.Pointer(initConfig)))
-	return int(ret)
+	handle, err := zc.InitCANFDChecked(deviceHandle, canIndex, initConfig)
+	if err != nil {
+		zc.log(slog.LevelError, "initializing channel failed", append(zc.deviceAttrs(deviceHandle),
+			slog.String("function", "ZCAN_InitCANFD"), slog.Uint64("channel", uint64(canIndex)), slog.Any("error", err))...)
+	}
+	return handle
+}
+
+// InitCANChecked is InitCAN returning why the channel could not be initialized: the error
+// of ValidateCANConfig, or a failed ZCAN_InitCAN call.
+func (zc *ZCAN) InitCANChecked(deviceHandle int, canIndex uint, initConfig *ZCAN_NORMAL_CHANNEL_INIT_CONFIG) (int, error) {
+	if err := zc.ValidateCANConfig(deviceHandle, canIndex, initConfig); err != nil {
+		return INVALID_CHANNEL_HANDLE, err
+	}
+	handle := zc.drv.InitCAN(deviceHandle, canIndex, initConfig)
+	if handle == INVALID_CHANNEL_HANDLE {
+		return handle, fmt.Errorf("error calling ZCAN_InitCAN on channel %d", canIndex)
+	}
+	return handle, nil
+}
+
+// InitCANFDChecked is InitCANFD returning why the channel could not be initialized: the
+// error of ValidateCANFDConfig, or a failed ZCAN_InitCAN call.
+func (zc *ZCAN) InitCANFDChecked(deviceHandle int, canIndex uint, initConfig *ZCAN_CANFD_CHANNEL_INIT_CONFIG) (int, error) {
+	if err := zc.ValidateCANFDConfig(deviceHandle, canIndex, initConfig); err != nil {
+		return INVALID_CHANNEL_HANDLE, err
+	}
+	handle := zc.drv.InitCANFD(deviceHandle, canIndex, initConfig)
+	if handle == INVALID_CHANNEL_HANDLE {
+		return handle, fmt.Errorf("error calling ZCAN_InitCAN on channel %d", canIndex)
+	}
+	return handle, nil
 }
 
 func (zc *ZCAN) StartCAN(channelHandle int) uint {
-	startCAN, _ := syscall.GetProcAddress(zc.dll, "ZCAN_StartCAN")
-	ret, _, _ := syscall.SyscallN(startCAN, uintptr(channelHandle))
-	return uint(ret)
+	return zc.drv.StartCAN(channelHandle)
 }
 
 func (zc *ZCAN) ResetCAN(channelHandle int) uint {
-	resetCAN, _ := syscall.GetProcAddress(zc.dll, "ZCAN_ResetCAN")
-	ret, _, _ := syscall.SyscallN(resetCAN, uintptr(channelHandle))
-	return uint(ret)
+	locks := zc.channelLocks(channelHandle)
+	locks.rx.Lock()
+	defer locks.rx.Unlock()
+	return zc.drv.ResetCAN(channelHandle)
 }
 
 func (zc *ZCAN) ClearBuffer(channelHandle int) uint {
-	clearBuffer, _ := syscall.GetProcAddress(zc.dll, "ZCAN_ClearBuffer")
-	ret, _, _ := syscall.SyscallN(clearBuffer, uintptr(channelHandle))
-	return uint(ret)
+	locks := zc.channelLocks(channelHandle)
+	locks.rx.Lock()
+	defer locks.rx.Unlock()
+	return zc.drv.ClearBuffer(channelHandle)
 }
 
 func (zc *ZCAN) ReadChannelErrInfo(channelHandle int) (*ZCAN_CHANNEL_ERR_INFO, error) {
 	errInfo := ZCAN_CHANNEL_ERR_INFO{}
-	readChannelErrInfo, _ := syscall.GetProcAddress(zc.dll, "ZCAN_ReadChannelErrInfo")
-	ret, _, _ := syscall.SyscallN(readChannelErrInfo, uintptr(channelHandle), uintptr(unsafe.Pointer(&errInfo)))
+	ret := zc.drv.ReadChannelErrInfo(channelHandle, &errInfo)
 	if ret == ZCAN_STATUS_OK {
-		return (*ZCAN_CHANNEL_ERR_INFO)(&errInfo), nil
+		return &errInfo, nil
 	}
-	fmt.Println("error calling ZCAN_ReadChannelErrInfo:", ret)
+	zc.logStatus("ZCAN_ReadChannelErrInfo", ret, slog.Int("handle", channelHandle))
 	return nil, fmt.Errorf("error calling ZCAN_ReadChannelErrInfo")
 }
 
 func (zc *ZCAN) ReadChannelStatus(channelHandle int) (*ZCAN_CHANNEL_STATUS, error) {
 	status := ZCAN_CHANNEL_STATUS{}
-	readChannelStatus, _ := syscall.GetProcAddress(zc.dll, "ZCAN_ReadChannelStatus")
-	ret, _, _ := syscall.SyscallN(readChannelStatus, uintptr(channelHandle), uintptr(unsafe.Pointer(&status)))
+	ret := zc.drv.ReadChannelStatus(channelHandle, &status)
 	if ret == ZCAN_STATUS_OK {
-		return (*ZCAN_CHANNEL_STATUS)(&status), nil
+		return &status, nil
 	}
-	fmt.Println("error calling ZCAN_ReadChannelStatus:", ret)
+	zc.logStatus("ZCAN_ReadChannelStatus", ret, slog.Int("handle", channelHandle))
 	return nil, fmt.Errorf("error calling ZCAN_ReadChannelStatus")
 }
 
 func (zc *ZCAN) GetReceiveNum(channelHandle int, canType uint) uint {
-	getReceiveNum, _ := syscall.GetProcAddress(zc.dll, "ZCAN_GetReceiveNum")
-	ret, _, _ := syscall.SyscallN(getReceiveNum, uintptr(channelHandle), uintptr(canType))
-	return uint(ret)
+	locks := zc.channelLocks(channelHandle)
+	locks.rx.Lock()
+	defer locks.rx.Unlock()
+	return zc.drv.GetReceiveNum(channelHandle, canType)
 }
 
 func (zc *ZCAN) Transmit(channelHandle int, stdMsg []ZCAN_Transmit_Data, len uint) uint {
-	transmit, _ := syscall.GetProcAddress(zc.dll, "ZCAN_Transmit")
-	ret, _, _ := syscall.SyscallN(transmit, uintptr(channelHandle), uintptr(unsafe.Pointer(&stdMsg[0])), uintptr(len))
-	return uint(ret)
+	locks := zc.channelLocks(channelHandle)
+	locks.tx.Lock()
+	defer locks.tx.Unlock()
+	return zc.drv.Transmit(channelHandle, stdMsg, len)
 }
 
 func (zc *ZCAN) Receive(channelHandle int, rcvNum uint, waitTime int) ([]ZCAN_Receive_Data, uint) {
 	msgs := make([]ZCAN_Receive_Data, rcvNum)
-	receive, _ := syscall.GetProcAddress(zc.dll, "ZCAN_Receive")
-	ret, _, _ := syscall.SyscallN(receive, uintptr(channelHandle), uintptr(unsafe.Pointer(&msgs[0])), uintptr(rcvNum), uintptr(waitTime))
-	return msgs, uint(ret)
+	locks := zc.channelLocks(channelHandle)
+	locks.rx.Lock()
+	defer locks.rx.Unlock()
+	return msgs, zc.drv.Receive(channelHandle, msgs, waitTime)
 }
 
 func (zc *ZCAN) TransmitFD(channelHandle int, fdMsg []ZCAN_TransmitFD_Data, len uint) uint {
-	transmitFD, _ := syscall.GetProcAddress(zc.dll, "ZCAN_TransmitFD")
-	ret, _, _ := syscall.SyscallN(transmitFD, uintptr(channelHandle), uintptr(unsafe.Pointer(&fdMsg[0])), uintptr(len))
-	return uint(ret)
+	locks := zc.channelLocks(channelHandle)
+	locks.tx.Lock()
+	defer locks.tx.Unlock()
+	return zc.drv.TransmitFD(channelHandle, fdMsg, len)
 }
 
 func (zc *ZCAN) ReceiveFD(channelHandle int, rcvNum uint, waitTime int) ([]ZCAN_ReceiveFD_Data, uint) {
@@ -469,70 +594,104 @@ func (zc *ZCAN) ReceiveFD(channelHandle int, rcvNum uint, waitTime int) ([]ZCAN_
 		waitTime = -1
 	}
 	msgs := make([]ZCAN_ReceiveFD_Data, rcvNum)
-	receiveFD, _ := syscall.GetProcAddress(zc.dll, "ZCAN_ReceiveFD")
-	ret, _, _ := syscall.SyscallN(receiveFD, uintptr(channelHandle), uintptr(unsafe.Pointer(&msgs[0])), uintptr(rcvNum), uintptr(waitTime))
-	return msgs, uint(ret)
+	locks := zc.channelLocks(channelHandle)
+	locks.rx.Lock()
+	defer locks.rx.Unlock()
+	return msgs, zc.drv.ReceiveFD(channelHandle, msgs, waitTime)
 }
 
+func (zc *ZCAN) TransmitData(deviceHandle int, objs []ZCAN_DATA_OBJ, len uint) uint {
+	return zc.drv.TransmitData(deviceHandle, objs, len)
+}
+
+// ReceiveData reads up to rcvNum merged data objects of deviceHandle and decodes them.
+func (zc *ZCAN) ReceiveData(deviceHandle int, rcvNum uint, waitTime int) ([]DataEvent, uint) {
+	if rcvNum == 0 {
+		return nil, 0
+	}
+	objs := make([]ZCAN_DATA_OBJ, rcvNum)
+	ret := zc.drv.ReceiveData(deviceHandle, objs, waitTime)
+	events := make([]DataEvent, 0, min(ret, rcvNum))
+	for i := range objs[:min(ret, rcvNum)] {
+		events = append(events, objs[i].Decode())
+	}
+	return events, ret
+}
+
+// GetIProperty returns the property interface of deviceHandle. Each call on it is
+// serialized with other property access; the device is not locked between calls. The
+// library may return the same interface to several callers, it is released in the driver
+// once every caller released it. After ReleaseIProperty the calls fail without reaching
+// the driver.
 func (zc *ZCAN) GetIProperty(deviceHandle int) (*ZCAN_IProperty, error) {
-	getIProperty, _ := syscall.GetProcAddress(zc.dll, "GetIProperty")
-	ret, _, callErr := syscall.SyscallN(getIProperty, uintptr(deviceHandle))
-	if callErr != 0 {
-		return nil, fmt.Errorf("error calling GetIProperty: %w", callErr)
+	zc.propMu.Lock()
+	defer zc.propMu.Unlock()
+	return zc.getIProperty(deviceHandle)
+}
+
+func (zc *ZCAN) getIProperty(deviceHandle int) (*ZCAN_IProperty, error) {
+	iproperty, err := zc.drv.GetIProperty(deviceHandle)
+	if err != nil || iproperty == nil {
+		return iproperty, err
+	}
+	zc.mu.Lock()
+	if zc.iproperties == nil {
+		zc.iproperties = make(map[*ZCAN_IProperty]int)
 	}
-	// transform the ret to a pointer for ZCAN_IProperty
-	iproperty := (*ZCAN_IProperty)(unsafe.Pointer(ret))
+	zc.iproperties[iproperty]++
+	zc.mu.Unlock()
 	return iproperty, nil
 }
 
 func (zc *ZCAN) SetValue(iproperty *ZCAN_IProperty, path, value string) uint {
-	setValue := iproperty.SetValue
-	cPath := C.CString(path)
-	cValue := C.CString(value)
-	defer C.free(unsafe.Pointer(cPath))
-	defer C.free(unsafe.Pointer(cValue))
-	ret, _, _ := syscall.SyscallN(uintptr(unsafe.Pointer(setValue)), uintptr(unsafe.Pointer(cPath)), uintptr(unsafe.Pointer(cValue)))
-	return uint(ret)
+	zc.propMu.Lock()
+	defer zc.propMu.Unlock()
+	if !zc.knownIProperty("SetValue", iproperty) {
+		return ZCAN_STATUS_ERR
+	}
+	return zc.drv.SetValue(iproperty, path, value)
 }
 
-func (zc *ZCAN) GetValue(iproperty *ZCAN_IProperty, path string) string {
-	getValue := iproperty.GetValue
-	cPath := C.CString(path)
-	defer C.free(unsafe.Pointer(cPath))
-	ret, _, _ := syscall.SyscallN(uintptr(unsafe.Pointer(getValue)), uintptr(unsafe.Pointer(cPath)))
-	return C.GoString((*C.char)(unsafe.Pointer(ret)))
+// setValuePtr passes value unconverted, for properties such as auto_send that take a struct.
+func (zc *ZCAN) setValuePtr(iproperty *ZCAN_IProperty, path string, value unsafe.Pointer) uint {
+	zc.propMu.Lock()
+	defer zc.propMu.Unlock()
+	if !zc.knownIProperty("SetValuePtr", iproperty) {
+		return ZCAN_STATUS_ERR
+	}
+	return zc.drv.SetValuePtr(iproperty, path, value)
 }
 
-func (zc *ZCAN) ReleaseIProperty(iproperty *ZCAN_IProperty) uint {
-	proc, _ := syscall.GetProcAddress(zc.dll, "ReleaseIProperty")
-	ret, _, _ := syscall.SyscallN(proc, uintptr(unsafe.Pointer(iproperty)))
-	return uint(ret)
+func (zc *ZCAN) GetValue(iproperty *ZCAN_IProperty, path string) string {
+	zc.propMu.Lock()
+	defer zc.propMu.Unlock()
+	if !zc.knownIProperty("GetValue", iproperty) {
+		return ""
+	}
+	return zc.drv.GetValue(iproperty, path)
 }
 
-func can_start(zcanlib *ZCAN, handle int, channel int) int {
-	ip, _ := zcanlib.GetIProperty(handle)
-	ret := zcanlib.SetValue(ip, "/initenal_resistance", "1")
-	if ret != ZCAN_STATUS_OK {
-		fmt.Println("Set resistance failed")
-	}
-	ret = zcanlib.SetValue(ip, fmt.Sprintf("%d/clock", channel), "60000000")
-	if ret != ZCAN_STATUS_OK {
-		fmt.Println("Set clock failed")
+func (zc *ZCAN) ReleaseIProperty(iproperty *ZCAN_IProperty) uint {
+	zc.propMu.Lock()
+	defer zc.propMu.Unlock()
+	if !zc.knownIProperty("ReleaseIProperty", iproperty) {
+		return ZCAN_STATUS_ERR
 	}
-	zcanlib.ReleaseIProperty(ip)
+	return zc.releaseIProperty(iproperty)
+}
 
-	initCfg := ZCAN_CANFD_CHANNEL_INIT_CONFIG{
-		CanType: ZCAN_TYPE_CANFD,
+// releaseIProperty drops one reference to iproperty, releasing it in the driver with the
+// last one.
+func (zc *ZCAN) releaseIProperty(iproperty *ZCAN_IProperty) uint {
+	zc.mu.Lock()
+	zc.iproperties[iproperty]--
+	refs := zc.iproperties[iproperty]
+	if refs == 0 {
+		delete(zc.iproperties, iproperty)
 	}
-	initCfg.Config.Mode = 0
-	initCfg.Config.AbitTiming = 101166  // 1Mbps
-	initCfg.Config.DbitTiming = 8487694 // 1Mbps
-
-	can_handle := zcanlib.InitCANFD(handle, 0, &initCfg)
-	if can_handle == INVALID_CHANNEL_HANDLE {
-		fmt.Println("Init CANFD failed")
+	zc.mu.Unlock()
+	if refs > 0 {
+		return ZCAN_STATUS_OK
 	}
-
-	_ = zcanlib.StartCAN(can_handle)
-	return can_handle
+	return zc.drv.ReleaseIProperty(iproperty)
 }
@@ -1,8 +1,11 @@
+//go:build windows
+
 package zlgcan
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"syscall"
 	"testing"
 )
 
@@ -13,7 +16,7 @@ func TestOpenAndCloseDevice(t *testing.T) {
 		t.Fatalf("Failed to load ZCAN DLL: %v", err)
 		return
 	}
-	defer syscall.FreeLibrary(zcanlib.dll)
+	defer zcanlib.Close()
 
 	handle := zcanlib.OpenDevice(ZCAN_USBCANFD_200U, 0, 0)
 	if handle == INVALID_DEVICE_HANDLE {
@@ -34,7 +37,7 @@ func TestGetDeviceInfo(t *testing.T) {
 		t.Fatalf("Failed to load ZCAN DLL: %v", err)
 		return
 	}
-	defer syscall.FreeLibrary(zcanlib.dll)
+	defer zcanlib.Close()
 
 	handle := zcanlib.OpenDevice(ZCAN_USBCANFD_200U, 0, 0)
 	if handle == INVALID_DEVICE_HANDLE {
@@ -67,7 +70,7 @@ func TestDeviceOnLine(t *testing.T) {
 		t.Fatalf("Failed to load ZCAN DLL: %v", err)
 		return
 	}
-	defer syscall.FreeLibrary(zcanlib.dll)
+	defer zcanlib.Close()
 
 	handle := zcanlib.OpenDevice(ZCAN_USBCANFD_200U, 0, 0)
 	if handle == INVALID_DEVICE_HANDLE {
@@ -96,7 +99,7 @@ func TestInitCAN(t *testing.T) {
 		t.Fatalf("Failed to load ZCAN DLL: %v", err)
 		return
 	}
-	defer syscall.FreeLibrary(zcanlib.dll)
+	defer zcanlib.Close()
 
 	handle := zcanlib.OpenDevice(ZCAN_USBCANFD_200U, 0, 0)
 	if handle == INVALID_DEVICE_HANDLE {
@@ -104,12 +107,12 @@ func TestInitCAN(t *testing.T) {
 		return
 	}
 
-	initCfg := ZCAN_CANFD_CHANNEL_INIT_CONFIG{
+	initCfg := ZCAN_NORMAL_CHANNEL_INIT_CONFIG{
 		CanType: ZCAN_TYPE_CAN, // use normal mode because the fd mode need to set the clock
 	}
 	initCfg.Config.Mode = 0
 
-	ret := zcanlib.InitCANFD(handle, 0, &initCfg)
+	ret := zcanlib.InitCAN(handle, 0, &initCfg)
 	if ret == INVALID_CHANNEL_HANDLE {
 		t.Fatalf("The result of initializing the Channel 0 is %d", ret)
 		return
@@ -123,7 +126,7 @@ func TestGetAndReleaseIProperty(t *testing.T) {
 		t.Fatalf("Failed to load ZCAN DLL: %v", err)
 		return
 	}
-	defer syscall.FreeLibrary(zcanlib.dll)
+	defer zcanlib.Close()
 
 	handle := zcanlib.OpenDevice(ZCAN_USBCANFD_200U, 0, 0)
 	if handle == INVALID_DEVICE_HANDLE {
@@ -147,7 +150,7 @@ func TestOpenAndCloseCAN(t *testing.T) {
 		t.Fatalf("Failed to load ZCAN DLL: %v", err)
 		return
 	}
-	defer syscall.FreeLibrary(zcanlib.dll)
+	defer zcanlib.Close()
 
 	handle := zcanlib.OpenDevice(ZCAN_USBCANFD_200U, 0, 0)
 	if handle == INVALID_DEVICE_HANDLE {
@@ -207,7 +210,7 @@ func TestTransmitAndReceive(t *testing.T) {
 		t.Fatalf("Failed to load ZCAN DLL: %v", err)
 		return
 	}
-	defer syscall.FreeLibrary(zcanlib.dll)
+	defer zcanlib.Close()
 	handle := zcanlib.OpenDevice(ZCAN_USBCANFD_200U, 0, 0)
 	if handle == INVALID_DEVICE_HANDLE {
 		fmt.Println("Open Device failed!")
@@ -222,14 +225,24 @@ func TestTransmitAndReceive(t *testing.T) {
 	}
 	t.Logf("Device Information:\n%+v\n", info)
 
-	chanHandle := can_start(zcanlib, handle, 0)
-	print("channel handle:", chanHandle, "\n")
+	channel, err := zcanlib.OpenChannel(handle, 0,
+		WithCanType(ZCAN_TYPE_CANFD),
+		WithBitrate(1000000, 0.8),
+		WithDataBitrate(1000000, 0.8),
+		WithTermination(true),
+	)
+	if err != nil {
+		t.Fatalf("OpenChannel failed: %v", err)
+		return
+	}
+	chanHandle := channel.Handle()
+	t.Logf("channel handle: %d", chanHandle)
 
 	// Send CAN Messages
 	transmitNum := 10
 	msgs := make([]ZCAN_Transmit_Data, transmitNum)
 	for msg_id := range msgs {
-		msgs[msg_id].Type = 2
+		msgs[msg_id].Type = ZCAN_TX_SELF_RX
 		// 生成 ID
 		msgs[msg_id].Frame.GenerateID(uint32(msg_id), 0, 0, 0)
 		msgs[msg_id].Frame.Dlc = 8
@@ -244,7 +257,7 @@ func TestTransmitAndReceive(t *testing.T) {
 	transmitNum = 10
 	msgs_fd := make([]ZCAN_TransmitFD_Data, transmitNum)
 	for msg_id := range msgs_fd {
-		msgs_fd[msg_id].Type = 2
+		msgs_fd[msg_id].Type = ZCAN_TX_SELF_RX
 		// 生成 ID
 		msgs_fd[msg_id].Frame.GenerateID(uint32(msg_id), 0, 0, 0)
 		msgs_fd[msg_id].Frame.GenerateFlags(1, 0, 0)
@@ -316,3 +329,105 @@ func TestTransmitAndReceive(t *testing.T) {
 	zcanlib.CloseDevice(handle)
 	t.Log("Close Device success!")
 }
+
+// Test for Enumerate
+func TestEnumerate(t *testing.T) {
+	zcanlib, err := NewZCAN(".\\zlgcan_x64\\zlgcan.dll")
+	if err != nil {
+		t.Fatalf("Failed to load ZCAN DLL: %v", err)
+		return
+	}
+	defer zcanlib.Close()
+
+	devices, err := zcanlib.Enumerate(ZCAN_USBCANFD_200U, ZCAN_USBCANFD_100U)
+	if err != nil {
+		t.Fatalf("Enumerate failed: %v", err)
+		return
+	}
+	if len(devices) == 0 {
+		t.Fatalf("No device found!")
+		return
+	}
+	for _, device := range devices {
+		t.Logf("Found: %s\n", device)
+	}
+}
+
+// Test for OpenBySerial
+func TestOpenBySerial(t *testing.T) {
+	zcanlib, err := NewZCAN(".\\zlgcan_x64\\zlgcan.dll")
+	if err != nil {
+		t.Fatalf("Failed to load ZCAN DLL: %v", err)
+		return
+	}
+	defer zcanlib.Close()
+
+	devices, err := zcanlib.Enumerate(ZCAN_USBCANFD_200U)
+	if err != nil || len(devices) == 0 {
+		t.Fatalf("No device found! err: %v", err)
+		return
+	}
+
+	handle, err := zcanlib.OpenBySerial(ZCAN_USBCANFD_200U, devices[0].Serial)
+	if err != nil {
+		t.Fatalf("OpenBySerial failed: %v", err)
+		return
+	}
+	if info := zcanlib.GetDeviceInf(handle); info == nil || info.Serial() != devices[0].Serial {
+		t.Fatalf("OpenBySerial opened the wrong device: %+v", info)
+	}
+	zcanlib.CloseDevice(handle)
+
+	_, err = zcanlib.OpenBySerial(ZCAN_USBCANFD_200U, "NO-SUCH-SERIAL")
+	if !errors.Is(err, ErrDeviceNotFound) {
+		t.Fatalf("Expected ErrDeviceNotFound, got %v", err)
+	}
+	t.Logf("Not found: %v\n", err)
+}
+
+// Test for TxQueue
+func TestTxQueue(t *testing.T) {
+	zcanlib, err := NewZCAN(".\\zlgcan_x64\\zlgcan.dll")
+	if err != nil {
+		t.Fatalf("Failed to load ZCAN DLL: %v", err)
+		return
+	}
+	defer zcanlib.Close()
+
+	handle := zcanlib.OpenDevice(ZCAN_USBCANFD_200U, 0, 0)
+	if handle == INVALID_DEVICE_HANDLE {
+		t.Fatalf("Open Device failed!")
+		return
+	}
+	defer zcanlib.CloseDevice(handle)
+
+	channel, err := zcanlib.OpenChannel(handle, 0, WithBitrate(1000000, 0.8), WithDataBitrate(5000000, 0.75), WithTermination(true))
+	if err != nil {
+		t.Fatalf("OpenChannel failed: %v", err)
+		return
+	}
+	defer channel.Close()
+
+	transmitNum := 2000
+	results := make(chan TxResult, transmitNum)
+	queue := NewTxQueue(channel, WithTxCapacity(256), WithTxResults(results))
+	for i := 0; i < transmitNum; i++ {
+		msg := &ZCAN_Transmit_Data{}
+		msg.Frame.GenerateID(uint32(i&0x7FF), 0, 0, 0)
+		msg.Frame.Dlc = 8
+		prio := TxPriorityNormal
+		if i%10 == 0 {
+			prio = TxPriorityHigh
+		}
+		if err := queue.Submit(context.Background(), TxFrame{CAN: msg, Priority: prio}); err != nil {
+			t.Fatalf("Submit failed: %v", err)
+			return
+		}
+	}
+	for i := 0; i < transmitNum; i++ {
+		if result := <-results; result.Err != nil {
+			t.Fatalf("Frame failed after %d attempts: %v", result.Attempts, result.Err)
+		}
+	}
+	queue.Close()
+}